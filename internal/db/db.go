@@ -60,6 +60,7 @@ func InitDB(dbType, dsn string, debug bool) error {
 		&model.LLMInfo{},
 		&model.APIKey{},
 		&model.Setting{},
+		&model.ReasoningRule{},
 		&model.StatsTotal{},
 		&model.StatsDaily{},
 		&model.StatsHourly{},
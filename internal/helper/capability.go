@@ -0,0 +1,167 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/bestruirui/octopus/internal/model"
+	"github.com/bestruirui/octopus/internal/op"
+	"github.com/bestruirui/octopus/internal/price"
+	transformer "github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/bestruirui/octopus/internal/transformer/outbound"
+	"github.com/bestruirui/octopus/internal/utils/log"
+	"github.com/samber/lo"
+)
+
+// probeImage 1x1 像素的 PNG 图片，用于探测图片输入
+const probeImage = "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAAAAC0lEQVR42mNkYAAAAAYAAjCB0C8AAAAASUVORK5CYII="
+
+// ProbeTarget 探测模型能力使用的渠道和渠道中的原始模型名
+type ProbeTarget struct {
+	Channel   *model.Channel
+	ModelName string
+}
+
+// LLMCapabilitiesProbeToDB 为能力未知的模型发送探测请求并记录结果，modelChannels 为小写模型名到提供该模型的渠道的映射
+func LLMCapabilitiesProbeToDB(modelChannels map[string]ProbeTarget, ctx context.Context) {
+	for modelName, target := range modelChannels {
+		known := price.GetLLMCapabilities(modelName)
+		if known.Tools != nil && known.Vision != nil {
+			continue
+		}
+		// 部分上游的模型名区分大小写，探测请求使用渠道中的原始模型名
+		probed := probeLLMCapabilities(ctx, target.Channel, target.ModelName, known)
+		if probed.Tools == nil && probed.Vision == nil {
+			continue
+		}
+		if err := op.LLMUpdateCapabilities(modelName, probed, ctx); err != nil {
+			log.Warnf("failed to update capabilities for model %s: %v", modelName, err)
+		}
+	}
+}
+
+// probeLLMCapabilities 探测 known 中未知的函数调用和图片输入能力
+// 上游拒绝请求（400、422）且错误信息与工具或图片有关时视为不支持，其他错误（如模型不存在、参数不支持）无法判断，保持未知
+func probeLLMCapabilities(ctx context.Context, channel *model.Channel, modelName string, known model.LLMCapabilities) model.LLMCapabilities {
+	var probed model.LLMCapabilities
+	if known.Tools == nil {
+		resp, err := probeRequest(ctx, channel, &transformer.InternalLLMRequest{
+			Model:     modelName,
+			Messages:  []transformer.Message{{Role: "user", Content: transformer.MessageContent{Content: lo.ToPtr("Call the ping tool.")}}},
+			MaxTokens: lo.ToPtr(int64(64)),
+			Tools: []transformer.Tool{{
+				Type:     "function",
+				Function: transformer.Function{Name: "ping", Description: "Ping the server.", Parameters: []byte(`{"type":"object","properties":{}}`)},
+			}},
+			ToolChoice: &transformer.ToolChoice{ToolChoice: lo.ToPtr("required")},
+		})
+		var rejected *probeRejectedError
+		switch {
+		case errors.As(err, &rejected) && rejected.mentions("tool", "function"):
+			probed.Tools = lo.ToPtr(false)
+		case err != nil:
+			log.Debugf("probe tools for model %s on channel %s: %v", modelName, channel.Name, err)
+		default:
+			// 忽略工具的上游同样返回成功，以是否实际调用工具为准
+			probed.Tools = lo.ToPtr(len(resp.Choices) > 0 && resp.Choices[0].Message != nil && len(resp.Choices[0].Message.ToolCalls) > 0)
+		}
+	}
+	if known.Vision == nil {
+		_, err := probeRequest(ctx, channel, &transformer.InternalLLMRequest{
+			Model: modelName,
+			Messages: []transformer.Message{{Role: "user", Content: transformer.MessageContent{MultipleContent: []transformer.MessageContentPart{
+				{Type: "text", Text: lo.ToPtr("Reply with the color of this image in one word.")},
+				{Type: "image_url", ImageURL: &transformer.ImageURL{URL: probeImage}},
+			}}}},
+			MaxTokens: lo.ToPtr(int64(16)),
+		})
+		var rejected *probeRejectedError
+		switch {
+		case errors.As(err, &rejected) && rejected.mentions("image", "vision", "multimodal", "modalit"):
+			probed.Vision = lo.ToPtr(false)
+		case err != nil:
+			log.Debugf("probe vision for model %s on channel %s: %v", modelName, channel.Name, err)
+		default:
+			probed.Vision = lo.ToPtr(true)
+		}
+	}
+	if probed.Tools != nil || probed.Vision != nil {
+		log.Infof("probed capabilities for model %s on channel %s: tools=%v vision=%v", modelName, channel.Name, lo.FromPtr(probed.Tools), lo.FromPtr(probed.Vision))
+	}
+	return probed
+}
+
+// probeRejectedError 上游拒绝探测请求（400、422）
+type probeRejectedError struct {
+	statusCode int
+	body       string
+}
+
+func (e *probeRejectedError) Error() string {
+	return fmt.Sprintf("upstream rejected probe: %d: %s", e.statusCode, e.body)
+}
+
+// mentions 判断错误信息是否包含任一关键词，不区分大小写
+func (e *probeRejectedError) mentions(keywords ...string) bool {
+	body := strings.ToLower(e.body)
+	for _, keyword := range keywords {
+		if strings.Contains(body, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// probeRequest 发送非流式的探测请求，上游拒绝请求时返回 *probeRejectedError
+func probeRequest(ctx context.Context, channel *model.Channel, request *transformer.InternalLLMRequest) (*transformer.InternalLLMResponse, error) {
+	outAdapter := outbound.Get(channel.Type)
+	if outAdapter == nil {
+		return nil, fmt.Errorf("unsupported channel type: %d", channel.Type)
+	}
+	if configurable, ok := outAdapter.(transformer.OutboundConfigurable); ok {
+		configurable.SetChannelOptions(channel.Options)
+	}
+	if clientSetter, ok := outAdapter.(transformer.OutboundHTTPClient); ok {
+		httpClient, err := ChannelHttpClient(channel)
+		if err != nil {
+			return nil, err
+		}
+		clientSetter.SetHTTPClient(httpClient)
+	}
+	request.Stream = lo.ToPtr(false)
+	req, err := outAdapter.TransformRequest(ctx, request, channel.GetBaseUrl(), channel.GetChannelKey().ChannelKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range channel.CustomHeader {
+		req.Header.Set(header.HeaderKey, header.HeaderValue)
+	}
+
+	var resp *http.Response
+	if transport, ok := outAdapter.(transformer.OutboundTransport); ok {
+		resp, err = transport.RoundTrip(req)
+	} else {
+		var client *http.Client
+		client, err = ChannelHttpClient(channel)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = client.Do(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnprocessableEntity {
+			return nil, &probeRejectedError{statusCode: resp.StatusCode, body: string(body)}
+		}
+		return nil, fmt.Errorf("upstream error: %d: %s", resp.StatusCode, string(body))
+	}
+	return outAdapter.TransformResponse(ctx, resp)
+}
@@ -3,11 +3,15 @@ package helper
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
 
 	"github.com/bestruirui/octopus/internal/model"
 	"github.com/bestruirui/octopus/internal/transformer/outbound"
+	"github.com/bestruirui/octopus/internal/transformer/outbound/azure"
 	"github.com/dlclark/regexp2"
 )
 
@@ -20,8 +24,14 @@ func FetchModels(ctx context.Context, request model.Channel) ([]string, error) {
 	switch request.Type {
 	case outbound.OutboundTypeAnthropic:
 		fetchModel, err = fetchAnthropicModels(client, ctx, request)
-	case outbound.OutboundTypeGemini:
+	case outbound.OutboundTypeGemini, outbound.OutboundTypeGeminiEmbedding:
 		fetchModel, err = fetchGeminiModels(client, ctx, request)
+	case outbound.OutboundTypeAzureOpenAI:
+		fetchModel, err = fetchAzureDeployments(client, ctx, request)
+	case outbound.OutboundTypeOllama:
+		fetchModel, err = fetchOllamaModels(client, ctx, request)
+	case outbound.OutboundTypeMock:
+		// 模拟渠道接受任意模型，无可获取的模型列表
 	default:
 		fetchModel, err = fetchOpenAIModels(client, ctx, request)
 	}
@@ -173,3 +183,89 @@ func fetchAnthropicModels(client *http.Client, ctx context.Context, request mode
 	}
 	return allModels, nil
 }
+
+// refer: https://learn.microsoft.com/en-us/rest/api/azureopenai/deployments/list
+// 返回部署映射中配置的模型名和资源下的全部部署名，无法列出部署时仅返回部署映射中的模型名
+func fetchAzureDeployments(client *http.Client, ctx context.Context, request model.Channel) ([]string, error) {
+	var allModels []string
+	if request.Options != nil {
+		for name := range request.Options.Deployments {
+			allModels = append(allModels, name)
+		}
+		slices.Sort(allModels)
+	}
+
+	apiVersion := azure.DefaultAPIVersion
+	if request.Options != nil && request.Options.APIVersion != "" {
+		apiVersion = request.Options.APIVersion
+	}
+	endpoint := strings.TrimSuffix(strings.TrimSuffix(request.GetBaseUrl(), "/"), "/openai")
+	req, _ := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		endpoint+"/openai/deployments?api-version="+url.QueryEscape(apiVersion),
+		nil,
+	)
+	req.Header.Set("api-key", request.GetChannelKey().ChannelKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if len(allModels) > 0 {
+			return allModels, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if len(allModels) > 0 {
+			return allModels, nil
+		}
+		return nil, fmt.Errorf("failed to list azure deployments: status %d", resp.StatusCode)
+	}
+
+	var result model.AzureDeploymentList
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		if len(allModels) > 0 {
+			return allModels, nil
+		}
+		return nil, err
+	}
+
+	for _, d := range result.Data {
+		if !slices.Contains(allModels, d.ID) {
+			allModels = append(allModels, d.ID)
+		}
+	}
+	return allModels, nil
+}
+
+// refer: https://github.com/ollama/ollama/blob/main/docs/api.md#list-local-models
+func fetchOllamaModels(client *http.Client, ctx context.Context, request model.Channel) ([]string, error) {
+	req, _ := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		strings.TrimSuffix(request.GetBaseUrl(), "/")+"/api/tags",
+		nil,
+	)
+	if key := request.GetChannelKey().ChannelKey; key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result model.OllamaModelList
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	models := make([]string, 0, len(result.Models))
+	for _, m := range result.Models {
+		models = append(models, m.Name)
+	}
+	return models, nil
+}
@@ -15,15 +15,15 @@ func LLMPriceAddToDB(modelNames []string, ctx context.Context) error {
 		if modelName == "" {
 			continue
 		}
-		modelPrice := price.GetLLMPrice(modelName)
-		if modelPrice != nil {
-			newLLMInfos = append(newLLMInfos, model.LLMInfo{
-				Name:     modelName,
-				LLMPrice: *modelPrice,
-			})
-		} else {
-			newLLMInfos = append(newLLMInfos, model.LLMInfo{Name: modelName})
+		llmInfo := model.LLMInfo{Name: modelName}
+		if modelPrice := price.GetLLMPrice(modelName); modelPrice != nil {
+			llmInfo.LLMPrice = *modelPrice
 		}
+		if modelLimit := price.GetLLMLimit(modelName); modelLimit != nil {
+			llmInfo.LLMLimit = *modelLimit
+		}
+		llmInfo.LLMCapabilities = price.GetLLMCapabilities(modelName)
+		newLLMInfos = append(newLLMInfos, llmInfo)
 		newLLMNames = append(newLLMNames, modelName)
 	}
 	if len(newLLMInfos) > 0 {
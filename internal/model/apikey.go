@@ -8,4 +8,6 @@ type APIKey struct {
 	ExpireAt        int64   `json:"expire_at,omitempty"`
 	MaxCost         float64 `json:"max_cost,omitempty"`
 	SupportedModels string  `json:"supported_models,omitempty"`
+	// ReasoningVisibility 推理内容的处理方式，为空时使用分组的设置
+	ReasoningVisibility ReasoningVisibility `json:"reasoning_visibility,omitempty"`
 }
@@ -17,6 +17,7 @@ type DBDump struct {
 	LLMInfos   []LLMInfo   `json:"llm_infos,omitempty"`
 	APIKeys    []APIKey    `json:"api_keys,omitempty"`
 	Settings   []Setting   `json:"settings,omitempty"`
+	ReasoningRules []ReasoningRule `json:"reasoning_rules,omitempty"`
 
 	StatsTotal   []StatsTotal   `json:"stats_total,omitempty"`
 	StatsDaily   []StatsDaily   `json:"stats_daily,omitempty"`
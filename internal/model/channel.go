@@ -3,6 +3,7 @@ package model
 import (
 	"time"
 
+	transformer "github.com/bestruirui/octopus/internal/transformer/model"
 	"github.com/bestruirui/octopus/internal/transformer/outbound"
 )
 
@@ -15,6 +16,14 @@ const (
 	AutoGroupTypeRegex AutoGroupType = 3 //正则匹配
 )
 
+type ChannelStreamMode int
+
+const (
+	ChannelStreamModeAuto           ChannelStreamMode = 0 //跟随客户端请求
+	ChannelStreamModeForceStream    ChannelStreamMode = 1 //上游强制流式, 非流式请求聚合后返回
+	ChannelStreamModeForceNonStream ChannelStreamMode = 2 //上游强制非流式, 流式请求合成 SSE 返回
+)
+
 type Channel struct {
 	ID            int                   `json:"id" gorm:"primaryKey"`
 	Name          string                `json:"name" gorm:"unique;not null"`
@@ -32,6 +41,14 @@ type Channel struct {
 	ChannelProxy  *string               `json:"channel_proxy"`
 	Stats         *StatsChannel         `json:"stats,omitempty" gorm:"foreignKey:ChannelID"`
 	MatchRegex    *string               `json:"match_regex"`
+	StreamMode    ChannelStreamMode     `json:"stream_mode" gorm:"default:0"`
+
+	ConnectTimeOut        int `json:"connect_time_out"`         // 建立连接超时时间(秒), 0 为不限制
+	ResponseHeaderTimeOut int `json:"response_header_time_out"` // 发送请求后等待响应头超时时间(秒), 0 为不限制
+	StreamIdleTimeOut     int `json:"stream_idle_time_out"`     // 流式响应相邻数据块的最大间隔(秒), 0 为不限制
+	TotalTimeOut          int `json:"total_time_out"`           // 单次请求总时长上限(秒), 0 为不限制
+
+	Options *transformer.ChannelOptions `json:"options" gorm:"serializer:json"` // 渠道类型相关的附加配置, 如 Azure 的 api-version 和部署映射
 }
 
 type BaseUrl struct {
@@ -71,6 +88,14 @@ type ChannelUpdateRequest struct {
 	ChannelProxy  *string                `json:"channel_proxy,omitempty"`
 	ParamOverride *string                `json:"param_override,omitempty"`
 	MatchRegex    *string                `json:"match_regex,omitempty"`
+	StreamMode    *ChannelStreamMode     `json:"stream_mode,omitempty"`
+
+	ConnectTimeOut        *int `json:"connect_time_out,omitempty"`
+	ResponseHeaderTimeOut *int `json:"response_header_time_out,omitempty"`
+	StreamIdleTimeOut     *int `json:"stream_idle_time_out,omitempty"`
+	TotalTimeOut          *int `json:"total_time_out,omitempty"`
+
+	Options *transformer.ChannelOptions `json:"options,omitempty"`
 
 	KeysToAdd    []ChannelKeyAddRequest    `json:"keys_to_add,omitempty"`
 	KeysToUpdate []ChannelKeyUpdateRequest `json:"keys_to_update,omitempty"`
@@ -10,12 +10,32 @@ const (
 )
 
 type Group struct {
-	ID                int         `json:"id" gorm:"primaryKey"`
-	Name              string      `json:"name" gorm:"unique;not null"`
-	Mode              GroupMode   `json:"mode" gorm:"not null"`
-	MatchRegex        string      `json:"match_regex"`
-	FirstTokenTimeOut int         `json:"first_token_time_out"` // 单个渠道首个Token响应超时时间(秒)
-	Items             []GroupItem `json:"items,omitempty" gorm:"foreignKey:GroupID"`
+	ID                  int                 `json:"id" gorm:"primaryKey"`
+	Name                string              `json:"name" gorm:"unique;not null"`
+	Mode                GroupMode           `json:"mode" gorm:"not null"`
+	MatchRegex          string              `json:"match_regex"`
+	ModelPatterns       []string            `json:"model_patterns,omitempty" gorm:"serializer:json"` // 路由到该分组的模型名规则, 支持 * 通配符或 /正则/, 分组项的模型名可用 $1 引用捕获组
+	FirstTokenTimeOut   int                 `json:"first_token_time_out"`                            // 单个渠道首个Token响应超时时间(秒)
+	ReasoningVisibility ReasoningVisibility `json:"reasoning_visibility,omitempty"`                  // 推理内容的处理方式，为空时原样返回
+	Items               []GroupItem         `json:"items,omitempty" gorm:"foreignKey:GroupID"`
+	Rules               []GroupRule         `json:"rules,omitempty" gorm:"serializer:json"` // 条件路由规则, 按顺序匹配, 第一条满足所有条件的规则生效
+}
+
+// GroupRule 分组的条件路由规则，在负载均衡选择分组项前按请求选择使用的分组项和负载均衡模式
+// 未设置的条件不参与匹配，所有条件都未设置的规则匹配所有请求
+type GroupRule struct {
+	Name string `json:"name"`
+
+	Stream         *bool             `json:"stream,omitempty"`           // 是否为流式请求
+	HasTools       *bool             `json:"has_tools,omitempty"`        // 是否带有函数工具
+	HasImages      *bool             `json:"has_images,omitempty"`       // 是否带有图片
+	MinInputTokens int64             `json:"min_input_tokens,omitempty"` // 估算的输入 token 数下限, 0 为不限制
+	MaxInputTokens int64             `json:"max_input_tokens,omitempty"` // 估算的输入 token 数上限, 0 为不限制
+	APIKeyIDs      []int             `json:"api_key_ids,omitempty"`      // 调用的 API Key
+	Headers        map[string]string `json:"headers,omitempty"`          // 请求头的值, 支持 * 通配符, 不区分大小写
+
+	ItemIDs []int      `json:"item_ids,omitempty"` // 仅使用这些分组项, 为空时使用所有分组项
+	Mode    *GroupMode `json:"mode,omitempty"`     // 使用的负载均衡模式, 为空时使用分组的模式
 }
 
 type GroupItem struct {
@@ -23,28 +43,34 @@ type GroupItem struct {
 	GroupID   int    `json:"group_id" gorm:"not null;index:idx_group_channel_model,unique"` // 创建时不携带此字段,更新时需要
 	ChannelID int    `json:"channel_id" gorm:"not null;index:idx_group_channel_model,unique"`
 	ModelName string `json:"model_name" gorm:"not null;index:idx_group_channel_model,unique"`
-	Priority  int    `json:"priority"`
-	Weight    int    `json:"weight"`
+	// SubGroupID 引用的子分组, 设置时在子分组内继续选择渠道, ChannelID 为 0, ModelName 为子分组名称
+	SubGroupID *int `json:"sub_group_id,omitempty" gorm:"index"`
+	Priority   int  `json:"priority"`
+	Weight     int  `json:"weight"`
 }
 
 // GroupUpdateRequest 分组更新请求 - 仅包含变更的数据
 type GroupUpdateRequest struct {
-	ID                int                      `json:"id" binding:"required"`
-	Name              *string                  `json:"name,omitempty"`                 // 仅在名称变更时发送
-	Mode              *GroupMode               `json:"mode,omitempty"`                 // 仅在模式变更时发送
-	MatchRegex        *string                  `json:"match_regex,omitempty"`          // 仅在匹配正则变更时发送
-	FirstTokenTimeOut *int                     `json:"first_token_time_out,omitempty"` // 仅在超时变更时发送(秒)
-	ItemsToAdd        []GroupItemAddRequest    `json:"items_to_add,omitempty"`         // 新增的 items
-	ItemsToUpdate     []GroupItemUpdateRequest `json:"items_to_update,omitempty"`      // 更新的 items (priority 变更)
-	ItemsToDelete     []int                    `json:"items_to_delete,omitempty"`      // 删除的 item IDs
+	ID                  int                      `json:"id" binding:"required"`
+	Name                *string                  `json:"name,omitempty"`                 // 仅在名称变更时发送
+	Mode                *GroupMode               `json:"mode,omitempty"`                 // 仅在模式变更时发送
+	MatchRegex          *string                  `json:"match_regex,omitempty"`          // 仅在匹配正则变更时发送
+	ModelPatterns       *[]string                `json:"model_patterns,omitempty"`       // 仅在模型名规则变更时发送
+	Rules               *[]GroupRule             `json:"rules,omitempty"`                // 仅在条件路由规则变更时发送
+	FirstTokenTimeOut   *int                     `json:"first_token_time_out,omitempty"` // 仅在超时变更时发送(秒)
+	ReasoningVisibility *ReasoningVisibility     `json:"reasoning_visibility,omitempty"` // 仅在推理内容处理方式变更时发送
+	ItemsToAdd          []GroupItemAddRequest    `json:"items_to_add,omitempty"`         // 新增的 items
+	ItemsToUpdate       []GroupItemUpdateRequest `json:"items_to_update,omitempty"`      // 更新的 items (priority 变更)
+	ItemsToDelete       []int                    `json:"items_to_delete,omitempty"`      // 删除的 item IDs
 }
 
 // GroupItemAddRequest 新增 item 请求
 type GroupItemAddRequest struct {
-	ChannelID int    `json:"channel_id" binding:"required"`
-	ModelName string `json:"model_name" binding:"required"`
-	Priority  int    `json:"priority,omitempty"`
-	Weight    int    `json:"weight,omitempty"`
+	ChannelID  int    `json:"channel_id"`
+	ModelName  string `json:"model_name"`
+	SubGroupID *int   `json:"sub_group_id,omitempty"` // 引用子分组时不需要 ChannelID 和 ModelName
+	Priority   int    `json:"priority,omitempty"`
+	Weight     int    `json:"weight,omitempty"`
 }
 
 // GroupItemUpdateRequest 更新 item 请求
@@ -7,9 +7,42 @@ type LLMPrice struct {
 	CacheWrite float64 `json:"cache_write"`
 }
 
+// LLMLimit 模型的上下文窗口和最大输出 token 数，0 表示未知
+type LLMLimit struct {
+	ContextWindow int64 `json:"context_window"`
+	MaxOutput     int64 `json:"max_output"`
+}
+
+// LLMCapabilities 模型支持的能力，nil 表示未知
+type LLMCapabilities struct {
+	Vision    *bool `json:"vision,omitempty"`
+	Tools     *bool `json:"tools,omitempty"`
+	Reasoning *bool `json:"reasoning,omitempty"`
+	Audio     *bool `json:"audio,omitempty"`
+}
+
+// Merge 用 other 中已知的能力覆盖当前值
+func (c LLMCapabilities) Merge(other LLMCapabilities) LLMCapabilities {
+	if other.Vision != nil {
+		c.Vision = other.Vision
+	}
+	if other.Tools != nil {
+		c.Tools = other.Tools
+	}
+	if other.Reasoning != nil {
+		c.Reasoning = other.Reasoning
+	}
+	if other.Audio != nil {
+		c.Audio = other.Audio
+	}
+	return c
+}
+
 type LLMInfo struct {
 	Name string `json:"name" gorm:"primaryKey;not null"`
 	LLMPrice
+	LLMLimit
+	LLMCapabilities
 }
 
 type LLMChannel struct {
@@ -54,3 +87,22 @@ type AnthropicModelList struct {
 	HasMore bool             `json:"has_more"`
 	LastID  string           `json:"last_id"`
 }
+
+type AzureDeployment struct {
+	ID     string `json:"id"`
+	Model  string `json:"model"`
+	Status string `json:"status"`
+}
+
+type AzureDeploymentList struct {
+	Data []AzureDeployment `json:"data"`
+}
+
+type OllamaModel struct {
+	Name  string `json:"name"`
+	Model string `json:"model"`
+}
+
+type OllamaModelList struct {
+	Models []OllamaModel `json:"models"`
+}
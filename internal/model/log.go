@@ -5,6 +5,7 @@ type ChannelAttempt struct {
 	ChannelID   int    `json:"channel_id"`
 	ChannelName string `json:"channel_name"`
 	ModelName   string `json:"model_name"`
+	Path        []string `json:"path,omitempty"` // 分组路径, 从请求的分组到渠道所在的子分组
 	Round       int    `json:"round"`       // 第几轮 (1-3)
 	AttemptNum  int    `json:"attempt_num"` // 第几次尝试
 	Success     bool   `json:"success"`
@@ -30,4 +31,6 @@ type RelayLog struct {
 	Attempts         []ChannelAttempt  `json:"attempts" gorm:"serializer:json"`          // 所有尝试记录
 	TotalAttempts    int               `json:"total_attempts"`                           // 总尝试次数
 	SuccessfulRound  int               `json:"successful_round"`                         // 成功的轮次
+	ClientCanceled   bool              `json:"client_canceled"`                          // 客户端是否提前断开
+	RouteRules       []string          `json:"route_rules,omitempty" gorm:"serializer:json"` // 命中的条件路由规则, 格式为 "分组名/规则名"
 }
@@ -0,0 +1,20 @@
+package model
+
+import transformer "github.com/bestruirui/octopus/internal/transformer/model"
+
+// ReasoningRule 管理员配置的推理强度映射规则，优先级高的规则先匹配
+type ReasoningRule struct {
+	ID                           int   `json:"id" gorm:"primaryKey"`
+	Priority                     int   `json:"priority"`
+	Enabled                      *bool `json:"enabled" gorm:"default:true"`
+	transformer.ReasoningMapping `gorm:"embedded"`
+}
+
+// ReasoningVisibility 返回给客户端的推理内容的处理方式
+type ReasoningVisibility string
+
+const (
+	ReasoningVisibilityKeep      ReasoningVisibility = "keep"      // 原样返回
+	ReasoningVisibilityStrip     ReasoningVisibility = "strip"     // 移除推理内容
+	ReasoningVisibilitySummarize ReasoningVisibility = "summarize" // 仅返回推理内容的开头部分
+)
@@ -9,13 +9,22 @@ import (
 type SettingKey string
 
 const (
-	SettingKeyProxyURL                SettingKey = "proxy_url"
-	SettingKeyStatsSaveInterval       SettingKey = "stats_save_interval"        // 将统计信息写入数据库的周期(分钟)
-	SettingKeyModelInfoUpdateInterval SettingKey = "model_info_update_interval" // 模型信息更新间隔(小时)
-	SettingKeySyncLLMInterval         SettingKey = "sync_llm_interval"          // LLM 同步间隔(小时)
-	SettingKeyRelayLogKeepPeriod      SettingKey = "relay_log_keep_period"      // 日志保存时间范围(天)
-	SettingKeyRelayLogKeepEnabled     SettingKey = "relay_log_keep_enabled"     // 是否保留历史日志
-	SettingKeyCORSAllowOrigins        SettingKey = "cors_allow_origins"         // 跨域白名单(逗号分隔, 如 "example.com,example2.com"). 为空不允许跨域, "*"允许所有
+	SettingKeyProxyURL                  SettingKey = "proxy_url"
+	SettingKeyStatsSaveInterval         SettingKey = "stats_save_interval"          // 将统计信息写入数据库的周期(分钟)
+	SettingKeyModelInfoUpdateInterval   SettingKey = "model_info_update_interval"   // 模型信息更新间隔(小时)
+	SettingKeySyncLLMInterval           SettingKey = "sync_llm_interval"            // LLM 同步间隔(小时)
+	SettingKeyRelayLogKeepPeriod        SettingKey = "relay_log_keep_period"        // 日志保存时间范围(天)
+	SettingKeyRelayLogKeepEnabled       SettingKey = "relay_log_keep_enabled"       // 是否保留历史日志
+	SettingKeyCORSAllowOrigins          SettingKey = "cors_allow_origins"           // 跨域白名单(逗号分隔, 如 "example.com,example2.com"). 为空不允许跨域, "*"允许所有
+	SettingKeyRelayCancelDrainTimeout   SettingKey = "relay_cancel_drain_timeout"   // 客户端断开后继续读取上游以统计用量的最长时间(秒), 0 为立即断开
+	SettingKeyRelaySSEHeartbeatInterval SettingKey = "relay_sse_heartbeat_interval" // 流式请求等待首个 Token 时发送心跳的间隔(秒), 0 为关闭
+	SettingKeyRelaySSEHeartbeatMode     SettingKey = "relay_sse_heartbeat_mode"     // 心跳类型: comment 为 ": ping" 注释, protocol 为入站协议自身的心跳事件
+	SettingKeyRelayHookURL              SettingKey = "relay_hook_url"               // 外部 Hook 地址, 转发前后将请求和响应 POST 到该地址, 为空不启用
+	SettingKeyRelayHookTimeout          SettingKey = "relay_hook_timeout"           // 外部 Hook 超时时间(秒)
+	SettingKeyRelayStructuredOutput     SettingKey = "relay_structured_output"      // 结构化输出校验: off 不校验, failover 不符合 schema 时转发下一个渠道, reask 先要求模型重新回答
+	SettingKeyRelayContextTruncation    SettingKey = "relay_context_truncation"     // 分组中所有模型的上下文窗口都放不下请求时的处理: off 直接返回错误, middle_out 从对话中间移除消息
+	SettingKeySyncCapabilityProbe       SettingKey = "sync_capability_probe"        // 同步模型时是否向渠道发送探测请求, 补全 models.dev 中没有的模型能力
+	SettingKeyRelayMaxChoices           SettingKey = "relay_max_choices"            // 单个请求允许的最大 n, 超过时拒绝请求, 0 为不限制
 )
 
 type Setting struct {
@@ -26,21 +35,33 @@ type Setting struct {
 func DefaultSettings() []Setting {
 	return []Setting{
 		{Key: SettingKeyProxyURL, Value: ""},
-		{Key: SettingKeyStatsSaveInterval, Value: "10"},       // 默认10分钟保存一次统计信息
-		{Key: SettingKeyCORSAllowOrigins, Value: ""},          // CORS 默认不允许跨域，设置为 "*" 才允许所有来源
-		{Key: SettingKeyModelInfoUpdateInterval, Value: "24"}, // 默认24小时更新一次模型信息
-		{Key: SettingKeySyncLLMInterval, Value: "24"},         // 默认24小时同步一次LLM
-		{Key: SettingKeyRelayLogKeepPeriod, Value: "7"},       // 默认日志保存7天
-		{Key: SettingKeyRelayLogKeepEnabled, Value: "true"},   // 默认保留历史日志
+		{Key: SettingKeyStatsSaveInterval, Value: "10"},          // 默认10分钟保存一次统计信息
+		{Key: SettingKeyCORSAllowOrigins, Value: ""},             // CORS 默认不允许跨域，设置为 "*" 才允许所有来源
+		{Key: SettingKeyModelInfoUpdateInterval, Value: "24"},    // 默认24小时更新一次模型信息
+		{Key: SettingKeySyncLLMInterval, Value: "24"},            // 默认24小时同步一次LLM
+		{Key: SettingKeyRelayLogKeepPeriod, Value: "7"},          // 默认日志保存7天
+		{Key: SettingKeyRelayLogKeepEnabled, Value: "true"},      // 默认保留历史日志
+		{Key: SettingKeyRelayCancelDrainTimeout, Value: "0"},     // 默认客户端断开后立即停止读取上游
+		{Key: SettingKeyRelaySSEHeartbeatInterval, Value: "0"},   // 默认不发送心跳
+		{Key: SettingKeyRelaySSEHeartbeatMode, Value: "comment"}, // 默认使用 SSE 注释作为心跳
+		{Key: SettingKeyRelayHookURL, Value: ""},                 // 默认不启用外部 Hook
+		{Key: SettingKeyRelayHookTimeout, Value: "5"},            // 默认外部 Hook 超时5秒
+		{Key: SettingKeyRelayStructuredOutput, Value: "off"},     // 默认不校验结构化输出
+		{Key: SettingKeyRelayContextTruncation, Value: "off"},    // 默认不截断请求
+		{Key: SettingKeySyncCapabilityProbe, Value: "false"},     // 默认不探测模型能力
+		{Key: SettingKeyRelayMaxChoices, Value: "8"},             // 默认单个请求最多8个 choice
 	}
 }
 
 func (s *Setting) Validate() error {
 	switch s.Key {
-	case SettingKeyModelInfoUpdateInterval, SettingKeySyncLLMInterval, SettingKeyRelayLogKeepPeriod:
-		_, err := strconv.Atoi(s.Value)
+	case SettingKeyModelInfoUpdateInterval, SettingKeySyncLLMInterval, SettingKeyRelayLogKeepPeriod, SettingKeyRelayCancelDrainTimeout, SettingKeyRelaySSEHeartbeatInterval, SettingKeyRelayHookTimeout, SettingKeyRelayMaxChoices:
+		value, err := strconv.Atoi(s.Value)
 		if err != nil {
-			return fmt.Errorf("model info update interval must be an integer")
+			return fmt.Errorf("%s must be an integer", s.Key)
+		}
+		if value < 0 && (s.Key == SettingKeyRelayCancelDrainTimeout || s.Key == SettingKeyRelaySSEHeartbeatInterval || s.Key == SettingKeyRelayHookTimeout || s.Key == SettingKeyRelayMaxChoices) {
+			return fmt.Errorf("%s must not be negative", s.Key)
 		}
 		return nil
 	case SettingKeyRelayLogKeepEnabled:
@@ -48,6 +69,41 @@ func (s *Setting) Validate() error {
 			return fmt.Errorf("relay log keep enabled must be true or false")
 		}
 		return nil
+	case SettingKeySyncCapabilityProbe:
+		if s.Value != "true" && s.Value != "false" {
+			return fmt.Errorf("sync capability probe must be true or false")
+		}
+		return nil
+	case SettingKeyRelaySSEHeartbeatMode:
+		if s.Value != "comment" && s.Value != "protocol" {
+			return fmt.Errorf("relay sse heartbeat mode must be comment or protocol")
+		}
+		return nil
+	case SettingKeyRelayStructuredOutput:
+		if s.Value != "off" && s.Value != "failover" && s.Value != "reask" {
+			return fmt.Errorf("relay structured output must be off, failover or reask")
+		}
+		return nil
+	case SettingKeyRelayContextTruncation:
+		if s.Value != "off" && s.Value != "middle_out" {
+			return fmt.Errorf("relay context truncation must be off or middle_out")
+		}
+		return nil
+	case SettingKeyRelayHookURL:
+		if s.Value == "" {
+			return nil
+		}
+		parsedURL, err := url.Parse(s.Value)
+		if err != nil {
+			return fmt.Errorf("relay hook URL is invalid: %w", err)
+		}
+		if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+			return fmt.Errorf("relay hook URL scheme must be http or https")
+		}
+		if parsedURL.Host == "" {
+			return fmt.Errorf("relay hook URL must have a host")
+		}
+		return nil
 	case SettingKeyProxyURL:
 		if s.Value == "" {
 			return nil
@@ -44,6 +44,9 @@ func DBExportAll(ctx context.Context, includeLogs, includeStats bool) (*model.DB
 	if err := conn.Find(&d.Settings).Error; err != nil {
 		return nil, fmt.Errorf("export settings: %w", err)
 	}
+	if err := conn.Find(&d.ReasoningRules).Error; err != nil {
+		return nil, fmt.Errorf("export reasoning_rules: %w", err)
+	}
 
 	if includeStats {
 		if err := conn.Find(&d.StatsTotal).Error; err != nil {
@@ -124,6 +127,11 @@ func DBImportIncremental(ctx context.Context, dump *model.DBDump) (*model.DBImpo
 		} else {
 			res.RowsAffected["settings"] = n
 		}
+		if n, err := createDoNothing(tx, dump.ReasoningRules); err != nil {
+			return fmt.Errorf("import reasoning_rules: %w", err)
+		} else {
+			res.RowsAffected["reasoning_rules"] = n
+		}
 
 		if dump.IncludeStats {
 			if n, err := createUpsertAll(tx, dump.StatsTotal, []clause.Column{{Name: "id"}}); err != nil {
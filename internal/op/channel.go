@@ -177,6 +177,30 @@ func ChannelUpdate(req *model.ChannelUpdateRequest, ctx context.Context) (*model
 		selectFields = append(selectFields, "match_regex")
 		updates.MatchRegex = req.MatchRegex
 	}
+	if req.StreamMode != nil {
+		selectFields = append(selectFields, "stream_mode")
+		updates.StreamMode = *req.StreamMode
+	}
+	if req.ConnectTimeOut != nil {
+		selectFields = append(selectFields, "connect_time_out")
+		updates.ConnectTimeOut = *req.ConnectTimeOut
+	}
+	if req.ResponseHeaderTimeOut != nil {
+		selectFields = append(selectFields, "response_header_time_out")
+		updates.ResponseHeaderTimeOut = *req.ResponseHeaderTimeOut
+	}
+	if req.StreamIdleTimeOut != nil {
+		selectFields = append(selectFields, "stream_idle_time_out")
+		updates.StreamIdleTimeOut = *req.StreamIdleTimeOut
+	}
+	if req.TotalTimeOut != nil {
+		selectFields = append(selectFields, "total_time_out")
+		updates.TotalTimeOut = *req.TotalTimeOut
+	}
+	if req.Options != nil {
+		selectFields = append(selectFields, "options")
+		updates.Options = req.Options
+	}
 
 	// 只有当有字段需要更新时才执行 UPDATE
 	if len(selectFields) > 0 {
@@ -3,10 +3,12 @@ package op
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/bestruirui/octopus/internal/db"
 	"github.com/bestruirui/octopus/internal/model"
 	"github.com/bestruirui/octopus/internal/utils/cache"
+	"github.com/samber/lo"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -27,6 +29,8 @@ func GroupListModel(ctx context.Context) ([]string, error) {
 	for _, group := range groupCache.GetAll() {
 		models = append(models, group.Name)
 	}
+	// 渠道中匹配分组模型名规则的模型同样可以请求
+	models = append(models, groupPatternModels(ctx, models)...)
 	return models, nil
 }
 
@@ -38,20 +42,37 @@ func GroupGet(id int, ctx context.Context) (*model.Group, error) {
 	return &group, nil
 }
 
+// GroupGetMap 按模型名获取分组，优先精确匹配分组名，其次按分组的模型名规则匹配
+// 规则匹配时分组项的模型名中的 $n 替换为捕获组
 func GroupGetMap(name string, ctx context.Context) (model.Group, error) {
-	items, ok := groupMap.Get(name)
+	if group, ok := groupMap.Get(name); ok {
+		return group, nil
+	}
+	group, captures, ok := groupMatchPattern(name)
 	if !ok {
 		return model.Group{}, fmt.Errorf("group not found")
 	}
-	return items, nil
+	items := make([]model.GroupItem, len(group.Items))
+	for i, item := range group.Items {
+		item.ModelName = expandModelName(item.ModelName, captures)
+		items[i] = item
+	}
+	group.Items = items
+	return group, nil
 }
 
 func GroupCreate(group *model.Group, ctx context.Context) error {
+	for i := range group.Items {
+		if err := groupItemPrepare(0, &group.Items[i]); err != nil {
+			return err
+		}
+	}
 	if err := db.GetDB().WithContext(ctx).Create(group).Error; err != nil {
 		return err
 	}
 	groupCache.Set(group.ID, *group)
 	groupMap.Set(group.Name, *group)
+	groupPatternCompile(*group)
 	return nil
 }
 
@@ -62,6 +83,21 @@ func GroupUpdate(req *model.GroupUpdateRequest, ctx context.Context) (*model.Gro
 	}
 	oldName := oldGroup.Name
 
+	newItems := make([]model.GroupItem, len(req.ItemsToAdd))
+	for i, item := range req.ItemsToAdd {
+		newItems[i] = model.GroupItem{
+			GroupID:    req.ID,
+			ChannelID:  item.ChannelID,
+			ModelName:  item.ModelName,
+			SubGroupID: item.SubGroupID,
+			Priority:   item.Priority,
+			Weight:     item.Weight,
+		}
+		if err := groupItemPrepare(req.ID, &newItems[i]); err != nil {
+			return nil, err
+		}
+	}
+
 	tx := db.GetDB().WithContext(ctx).Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -84,10 +120,22 @@ func GroupUpdate(req *model.GroupUpdateRequest, ctx context.Context) (*model.Gro
 		selectFields = append(selectFields, "match_regex")
 		updates.MatchRegex = *req.MatchRegex
 	}
+	if req.ModelPatterns != nil {
+		selectFields = append(selectFields, "model_patterns")
+		updates.ModelPatterns = *req.ModelPatterns
+	}
+	if req.Rules != nil {
+		selectFields = append(selectFields, "rules")
+		updates.Rules = *req.Rules
+	}
 	if req.FirstTokenTimeOut != nil {
 		selectFields = append(selectFields, "first_token_time_out")
 		updates.FirstTokenTimeOut = *req.FirstTokenTimeOut
 	}
+	if req.ReasoningVisibility != nil {
+		selectFields = append(selectFields, "reasoning_visibility")
+		updates.ReasoningVisibility = *req.ReasoningVisibility
+	}
 
 	if len(selectFields) > 0 {
 		if err := tx.Model(&model.Group{}).Where("id = ?", req.ID).Select(selectFields).Updates(&updates).Error; err != nil {
@@ -96,6 +144,19 @@ func GroupUpdate(req *model.GroupUpdateRequest, ctx context.Context) (*model.Gro
 		}
 	}
 
+	// 引用该分组的分组项以子分组名称作为模型名
+	var parentIDs []int
+	if req.Name != nil && *req.Name != oldName {
+		if err := tx.Model(&model.GroupItem{}).Where("sub_group_id = ?", req.ID).Distinct().Pluck("group_id", &parentIDs).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to find parent groups: %w", err)
+		}
+		if err := tx.Model(&model.GroupItem{}).Where("sub_group_id = ?", req.ID).Update("model_name", *req.Name).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to rename sub group items: %w", err)
+		}
+	}
+
 	// 删除 items
 	if len(req.ItemsToDelete) > 0 {
 		if err := tx.Where("id IN ? AND group_id = ?", req.ItemsToDelete, req.ID).Delete(&model.GroupItem{}).Error; err != nil {
@@ -104,6 +165,14 @@ func GroupUpdate(req *model.GroupUpdateRequest, ctx context.Context) (*model.Gro
 		}
 	}
 
+	// 条件路由规则中移除已删除的分组项
+	if len(req.ItemsToDelete) > 0 {
+		if err := groupPruneRules(tx, []int{req.ID}); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to prune group rules: %w", err)
+		}
+	}
+
 	// 批量更新 items
 	if len(req.ItemsToUpdate) > 0 {
 		ids := make([]int, len(req.ItemsToUpdate))
@@ -129,17 +198,7 @@ func GroupUpdate(req *model.GroupUpdateRequest, ctx context.Context) (*model.Gro
 	}
 
 	// 批量新增 items
-	if len(req.ItemsToAdd) > 0 {
-		newItems := make([]model.GroupItem, len(req.ItemsToAdd))
-		for i, item := range req.ItemsToAdd {
-			newItems[i] = model.GroupItem{
-				GroupID:   req.ID,
-				ChannelID: item.ChannelID,
-				ModelName: item.ModelName,
-				Priority:  item.Priority,
-				Weight:    item.Weight,
-			}
-		}
+	if len(newItems) > 0 {
 		if err := tx.Create(&newItems).Error; err != nil {
 			tx.Rollback()
 			return nil, fmt.Errorf("failed to create items: %w", err)
@@ -154,6 +213,9 @@ func GroupUpdate(req *model.GroupUpdateRequest, ctx context.Context) (*model.Gro
 	if err := groupRefreshCacheByID(req.ID, ctx); err != nil {
 		return nil, err
 	}
+	if err := groupRefreshCacheByIDs(parentIDs, ctx); err != nil {
+		return nil, err
+	}
 
 	group, _ := groupCache.Get(req.ID)
 	if oldName != "" && oldName != group.Name {
@@ -180,6 +242,21 @@ func GroupDel(id int, ctx context.Context) error {
 		return fmt.Errorf("failed to delete group items: %w", err)
 	}
 
+	// 删除其他分组中引用该分组的 GroupItem
+	var parentIDs []int
+	if err := tx.Model(&model.GroupItem{}).Where("sub_group_id = ?", id).Distinct().Pluck("group_id", &parentIDs).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to find parent groups: %w", err)
+	}
+	if err := tx.Where("sub_group_id = ?", id).Delete(&model.GroupItem{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete sub group items: %w", err)
+	}
+	if err := groupPruneRules(tx, parentIDs); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to prune group rules: %w", err)
+	}
+
 	if err := tx.Delete(&model.Group{}, id).Error; err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to delete group: %w", err)
@@ -191,13 +268,17 @@ func GroupDel(id int, ctx context.Context) error {
 
 	groupCache.Del(id)
 	groupMap.Del(group.Name)
-	return nil
+	groupPatternCache.Del(id)
+	return groupRefreshCacheByIDs(parentIDs, ctx)
 }
 
 func GroupItemAdd(item *model.GroupItem, ctx context.Context) error {
 	if _, ok := groupCache.Get(item.GroupID); !ok {
 		return fmt.Errorf("group not found")
 	}
+	if err := groupItemPrepare(item.GroupID, item); err != nil {
+		return err
+	}
 
 	if err := db.GetDB().WithContext(ctx).Create(item).Error; err != nil {
 		return err
@@ -283,6 +364,9 @@ func GroupItemDel(id int, ctx context.Context) error {
 	if err := db.GetDB().WithContext(ctx).Delete(&item).Error; err != nil {
 		return err
 	}
+	if err := groupPruneRules(db.GetDB().WithContext(ctx), []int{item.GroupID}); err != nil {
+		return fmt.Errorf("failed to prune group rules: %w", err)
+	}
 
 	return groupRefreshCacheByID(item.GroupID, ctx)
 }
@@ -317,6 +401,10 @@ func GroupItemBatchDelByChannelAndModels(keys []model.GroupIDAndLLMName, ctx con
 		return fmt.Errorf("failed to delete group items: %w", err)
 	}
 
+	if err := groupPruneRules(db.GetDB().WithContext(ctx), groupIDs); err != nil {
+		return fmt.Errorf("failed to prune group rules: %w", err)
+	}
+
 	if err := groupRefreshCacheByIDs(groupIDs, ctx); err != nil {
 		return fmt.Errorf("failed to refresh group cache: %w", err)
 	}
@@ -335,6 +423,80 @@ func GroupItemList(groupID int, ctx context.Context) ([]model.GroupItem, error)
 	return items, nil
 }
 
+// groupItemPrepare 校验新增的分组项，引用子分组时检查子分组存在且不会形成循环引用
+func groupItemPrepare(groupID int, item *model.GroupItem) error {
+	if item.SubGroupID == nil {
+		if item.ChannelID == 0 || item.ModelName == "" {
+			return fmt.Errorf("channel_id and model_name are required")
+		}
+		return nil
+	}
+	if item.ChannelID != 0 {
+		return fmt.Errorf("channel_id and sub_group_id must not both be set")
+	}
+	subGroup, ok := groupCache.Get(*item.SubGroupID)
+	if !ok {
+		return fmt.Errorf("sub group %d not found", *item.SubGroupID)
+	}
+	if groupID != 0 && groupReachable(subGroup.ID, groupID, map[int]bool{}) {
+		return fmt.Errorf("sub group %s would create a cycle", subGroup.Name)
+	}
+	item.ChannelID = 0
+	item.ModelName = subGroup.Name
+	return nil
+}
+
+// groupPruneRules 从分组的条件路由规则中移除已不存在的分组项
+func groupPruneRules(tx *gorm.DB, groupIDs []int) error {
+	if len(groupIDs) == 0 {
+		return nil
+	}
+	var groups []model.Group
+	if err := tx.Preload("Items").Where("id IN ?", groupIDs).Find(&groups).Error; err != nil {
+		return err
+	}
+	for _, group := range groups {
+		changed := false
+		for i, rule := range group.Rules {
+			itemIDs := lo.Filter(rule.ItemIDs, func(id int, _ int) bool {
+				return slices.ContainsFunc(group.Items, func(item model.GroupItem) bool { return item.ID == id })
+			})
+			if len(itemIDs) != len(rule.ItemIDs) {
+				group.Rules[i].ItemIDs = itemIDs
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		if err := tx.Model(&model.Group{}).Where("id = ?", group.ID).Select("rules").Updates(&model.Group{Rules: group.Rules}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupReachable 判断从分组 from 沿子分组引用能否到达分组 to
+func groupReachable(from, to int, visited map[int]bool) bool {
+	if from == to {
+		return true
+	}
+	if visited[from] {
+		return false
+	}
+	visited[from] = true
+	group, ok := groupCache.Get(from)
+	if !ok {
+		return false
+	}
+	for _, item := range group.Items {
+		if item.SubGroupID != nil && groupReachable(*item.SubGroupID, to, visited) {
+			return true
+		}
+	}
+	return false
+}
+
 func groupRefreshCache(ctx context.Context) error {
 	groups := []model.Group{}
 	if err := db.GetDB().WithContext(ctx).
@@ -345,6 +507,7 @@ func groupRefreshCache(ctx context.Context) error {
 	for _, group := range groups {
 		groupCache.Set(group.ID, group)
 		groupMap.Set(group.Name, group)
+		groupPatternCompile(group)
 	}
 	return nil
 }
@@ -358,6 +521,7 @@ func groupRefreshCacheByID(id int, ctx context.Context) error {
 	}
 	groupCache.Set(group.ID, group)
 	groupMap.Set(group.Name, group)
+	groupPatternCompile(group)
 	return nil
 }
 
@@ -375,6 +539,7 @@ func groupRefreshCacheByIDs(ids []int, ctx context.Context) error {
 	for _, group := range groups {
 		groupCache.Set(group.ID, group)
 		groupMap.Set(group.Name, group)
+		groupPatternCompile(group)
 	}
 	return nil
 }
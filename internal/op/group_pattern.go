@@ -0,0 +1,156 @@
+package op
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bestruirui/octopus/internal/model"
+	"github.com/bestruirui/octopus/internal/utils/cache"
+	"github.com/bestruirui/octopus/internal/utils/xstrings"
+)
+
+// groupPatternCache 各分组已编译的模型名规则，与 groupCache 同步更新，下标与 ModelPatterns 一致
+var groupPatternCache = cache.New[int, []*regexp.Regexp](16)
+
+// captureRefRegex 分组项模型名中对捕获组的引用，如 $1、${1}
+var captureRefRegex = regexp.MustCompile(`\$(\d+|\{\d+\})`)
+
+// isRegexPattern 判断规则是否为 /正则/ 形式
+func isRegexPattern(pattern string) bool {
+	return len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/")
+}
+
+// CompileGroupPattern 编译分组的模型名规则
+// /正则/ 按 RE2 语法编译，其他按通配符编译为不区分大小写的整体匹配，每个 * 为一个捕获组
+func CompileGroupPattern(pattern string) (*regexp.Regexp, error) {
+	if isRegexPattern(pattern) {
+		return regexp.Compile(pattern[1 : len(pattern)-1])
+	}
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.Compile("(?i)^" + strings.Join(parts, "(.*)") + "$")
+}
+
+// groupPatternCompile 编译分组的模型名规则并写入缓存，无法编译的规则为 nil
+func groupPatternCompile(group model.Group) {
+	if len(group.ModelPatterns) == 0 {
+		groupPatternCache.Del(group.ID)
+		return
+	}
+	regexps := make([]*regexp.Regexp, len(group.ModelPatterns))
+	for i, pattern := range group.ModelPatterns {
+		regexps[i], _ = CompileGroupPattern(pattern)
+	}
+	groupPatternCache.Set(group.ID, regexps)
+}
+
+// groupPatternMatch 规则匹配到的分组
+type groupPatternMatch struct {
+	group    model.Group
+	captures []string
+	// regex 是否由 /正则/ 匹配
+	regex bool
+	// literal 通配符中非 * 的字符数，越多越具体
+	literal int
+	index   int
+}
+
+// groupMatchPattern 按分组的模型名规则查找分组，返回分组和捕获组（第 0 个为完整的模型名）
+// 通配符优先于正则，通配符中非 * 的字符越多越优先，其余按分组 ID 和规则的先后顺序
+func groupMatchPattern(name string) (model.Group, []string, bool) {
+	var best *groupPatternMatch
+	for _, group := range groupCache.GetAll() {
+		regexps, _ := groupPatternCache.Get(group.ID)
+		for index, re := range regexps {
+			if re == nil || index >= len(group.ModelPatterns) {
+				continue
+			}
+			captures := re.FindStringSubmatch(name)
+			if captures == nil {
+				continue
+			}
+			pattern := group.ModelPatterns[index]
+			candidate := &groupPatternMatch{
+				group:    group,
+				captures: captures,
+				regex:    isRegexPattern(pattern),
+				literal:  len(pattern) - strings.Count(pattern, "*"),
+				index:    index,
+			}
+			if best == nil || candidate.before(best) {
+				best = candidate
+			}
+		}
+	}
+	if best == nil {
+		return model.Group{}, nil, false
+	}
+	return best.group, best.captures, true
+}
+
+func (m *groupPatternMatch) before(other *groupPatternMatch) bool {
+	if m.regex != other.regex {
+		return !m.regex
+	}
+	if !m.regex && m.literal != other.literal {
+		return m.literal > other.literal
+	}
+	if m.group.ID != other.group.ID {
+		return m.group.ID < other.group.ID
+	}
+	return m.index < other.index
+}
+
+// expandModelName 将分组项模型名中的 $n、${n} 替换为对应的捕获组，不存在的捕获组替换为空
+func expandModelName(template string, captures []string) string {
+	if !strings.Contains(template, "$") {
+		return template
+	}
+	return captureRefRegex.ReplaceAllStringFunc(template, func(ref string) string {
+		n, _ := strconv.Atoi(strings.Trim(ref, "${}"))
+		if n < len(captures) {
+			return captures[n]
+		}
+		return ""
+	})
+}
+
+// groupPatternModels 返回渠道模型中匹配任一分组规则、且不是分组名的模型
+func groupPatternModels(ctx context.Context, groupNames []string) []string {
+	var patterns []*regexp.Regexp
+	for _, regexps := range groupPatternCache.GetAll() {
+		for _, re := range regexps {
+			if re != nil {
+				patterns = append(patterns, re)
+			}
+		}
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+	channels, err := ChannelList(ctx)
+	if err != nil {
+		return nil
+	}
+	var models []string
+	for _, channel := range channels {
+		for _, modelName := range xstrings.SplitTrimCompact(",", channel.Model, channel.CustomModel) {
+			if slices.Contains(groupNames, modelName) || slices.Contains(models, modelName) {
+				continue
+			}
+			for _, re := range patterns {
+				if re.MatchString(modelName) {
+					models = append(models, modelName)
+					break
+				}
+			}
+		}
+	}
+	slices.Sort(models)
+	return models
+}
@@ -0,0 +1,49 @@
+package op
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bestruirui/octopus/internal/model"
+)
+
+func TestGroupGetMapPatterns(t *testing.T) {
+	groups := []model.Group{
+		{ID: 1, Name: "claude-sonnet-4", ModelPatterns: []string{"claude-*"}, Items: []model.GroupItem{{ModelName: "exact"}}},
+		{ID: 2, Name: "sonnet", ModelPatterns: []string{"claude-sonnet-4*"}, Items: []model.GroupItem{{ModelName: "anthropic/claude-sonnet-4$1"}}},
+		{ID: 3, Name: "regex", ModelPatterns: []string{`/^claude-sonnet-4-(\d{8})$/`}, Items: []model.GroupItem{{ModelName: "${1}"}}},
+	}
+	for _, group := range groups {
+		groupCache.Set(group.ID, group)
+		groupMap.Set(group.Name, group)
+		groupPatternCompile(group)
+	}
+	defer func() {
+		for _, group := range groups {
+			groupCache.Del(group.ID)
+			groupMap.Del(group.Name)
+			groupPatternCache.Del(group.ID)
+		}
+	}()
+
+	cases := []struct{ model, group, item string }{
+		{"claude-sonnet-4", "claude-sonnet-4", "exact"},
+		{"claude-sonnet-4-20250514", "sonnet", "anthropic/claude-sonnet-4-20250514"},
+		{"CLAUDE-opus-4", "claude-sonnet-4", "exact"},
+	}
+	for _, tc := range cases {
+		group, err := GroupGetMap(tc.model, context.Background())
+		if err != nil {
+			t.Fatalf("GroupGetMap(%q): %v", tc.model, err)
+		}
+		if group.Name != tc.group || group.Items[0].ModelName != tc.item {
+			t.Errorf("GroupGetMap(%q) = %s/%s, want %s/%s", tc.model, group.Name, group.Items[0].ModelName, tc.group, tc.item)
+		}
+	}
+	if cached, _ := groupCache.Get(2); cached.Items[0].ModelName != "anthropic/claude-sonnet-4$1" {
+		t.Errorf("cached group items were modified: %s", cached.Items[0].ModelName)
+	}
+	if _, err := GroupGetMap("gpt-4o", context.Background()); err == nil {
+		t.Error("expected no group for gpt-4o")
+	}
+}
@@ -0,0 +1,142 @@
+package op
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bestruirui/octopus/internal/db"
+	"github.com/bestruirui/octopus/internal/model"
+	"github.com/samber/lo"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "octopus-op")
+	if err != nil {
+		panic(err)
+	}
+	if err := db.InitDB("sqlite", filepath.Join(dir, "test.db"), false); err != nil {
+		panic(err)
+	}
+	if err := InitCache(); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	db.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// createTestGroup 创建分组，测试结束时删除
+func createTestGroup(t *testing.T, group model.Group) model.Group {
+	t.Helper()
+	if err := GroupCreate(&group, context.Background()); err != nil {
+		t.Fatalf("create group %s: %v", group.Name, err)
+	}
+	t.Cleanup(func() { GroupDel(group.ID, context.Background()) })
+	return group
+}
+
+func TestGroupItemPrepareCycle(t *testing.T) {
+	ctx := context.Background()
+	a := createTestGroup(t, model.Group{Name: "cycle-a"})
+	b := createTestGroup(t, model.Group{Name: "cycle-b", Items: []model.GroupItem{{SubGroupID: lo.ToPtr(a.ID)}}})
+	c := createTestGroup(t, model.Group{Name: "cycle-c", Items: []model.GroupItem{{SubGroupID: lo.ToPtr(b.ID)}}})
+
+	cases := []struct {
+		name string
+		item model.GroupItemAddRequest
+	}{
+		{"self", model.GroupItemAddRequest{SubGroupID: lo.ToPtr(a.ID)}},
+		{"direct", model.GroupItemAddRequest{SubGroupID: lo.ToPtr(b.ID)}},
+		{"indirect", model.GroupItemAddRequest{SubGroupID: lo.ToPtr(c.ID)}},
+		{"missing", model.GroupItemAddRequest{SubGroupID: lo.ToPtr(-1)}},
+		{"both", model.GroupItemAddRequest{ChannelID: 1, ModelName: "gpt-4o", SubGroupID: lo.ToPtr(b.ID)}},
+		{"neither", model.GroupItemAddRequest{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &model.GroupUpdateRequest{ID: a.ID, ItemsToAdd: []model.GroupItemAddRequest{tc.item}}
+			if _, err := GroupUpdate(req, ctx); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+	if group, _ := GroupGet(a.ID, ctx); len(group.Items) != 0 {
+		t.Fatalf("rejected items were saved: %+v", group.Items)
+	}
+
+	// 没有形成循环的引用可以添加
+	d := createTestGroup(t, model.Group{Name: "cycle-d"})
+	req := &model.GroupUpdateRequest{ID: c.ID, ItemsToAdd: []model.GroupItemAddRequest{{SubGroupID: lo.ToPtr(d.ID)}}}
+	group, err := GroupUpdate(req, ctx)
+	if err != nil {
+		t.Fatalf("GroupUpdate: %v", err)
+	}
+	if len(group.Items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(group.Items))
+	}
+}
+
+func TestGroupSubGroupCascade(t *testing.T) {
+	ctx := context.Background()
+	child := createTestGroup(t, model.Group{Name: "cascade-child"})
+	parent := createTestGroup(t, model.Group{Name: "cascade-parent", Items: []model.GroupItem{{SubGroupID: lo.ToPtr(child.ID)}}})
+	if parent.Items[0].ModelName != child.Name {
+		t.Fatalf("sub group item model name = %q, want %q", parent.Items[0].ModelName, child.Name)
+	}
+
+	// 重命名子分组时同步引用它的分组项
+	if _, err := GroupUpdate(&model.GroupUpdateRequest{ID: child.ID, Name: lo.ToPtr("cascade-renamed")}, ctx); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	group, _ := GroupGet(parent.ID, ctx)
+	if len(group.Items) != 1 || group.Items[0].ModelName != "cascade-renamed" {
+		t.Fatalf("parent items after rename: %+v", group.Items)
+	}
+	if _, err := GroupGetMap("cascade-child", ctx); err == nil {
+		t.Fatal("old name still maps to a group")
+	}
+
+	// 删除子分组时删除引用它的分组项
+	if err := GroupDel(child.ID, ctx); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	group, _ = GroupGet(parent.ID, ctx)
+	if len(group.Items) != 0 {
+		t.Fatalf("parent items after delete: %+v", group.Items)
+	}
+	mapped, err := GroupGetMap(parent.Name, ctx)
+	if err != nil || len(mapped.Items) != 0 {
+		t.Fatalf("group map after delete: %+v, %v", mapped.Items, err)
+	}
+}
+
+func TestGroupPruneRules(t *testing.T) {
+	ctx := context.Background()
+	group := createTestGroup(t, model.Group{Name: "prune", Items: []model.GroupItem{
+		{ChannelID: 1, ModelName: "a"},
+		{ChannelID: 1, ModelName: "b"},
+		{ChannelID: 1, ModelName: "c"},
+	}})
+	a, b, c := group.Items[0].ID, group.Items[1].ID, group.Items[2].ID
+	rules := []model.GroupRule{{Name: "ab", ItemIDs: []int{a, b}}, {Name: "c", ItemIDs: []int{c}}}
+	if _, err := GroupUpdate(&model.GroupUpdateRequest{ID: group.ID, Rules: &rules, ItemsToDelete: []int{a}}, ctx); err != nil {
+		t.Fatalf("GroupUpdate: %v", err)
+	}
+	if err := GroupItemDel(c, ctx); err != nil {
+		t.Fatalf("GroupItemDel: %v", err)
+	}
+
+	cached, _ := GroupGet(group.ID, ctx)
+	var stored model.Group
+	if err := db.GetDB().First(&stored, group.ID).Error; err != nil {
+		t.Fatalf("load group: %v", err)
+	}
+	for _, got := range [][]model.GroupRule{cached.Rules, stored.Rules} {
+		if len(got) != 2 || len(got[0].ItemIDs) != 1 || got[0].ItemIDs[0] != b || len(got[1].ItemIDs) != 0 {
+			t.Fatalf("rules after deleting items: %+v", got)
+		}
+	}
+}
@@ -10,15 +10,12 @@ import (
 	"github.com/bestruirui/octopus/internal/utils/cache"
 )
 
-var llmModelCache = cache.New[string, model.LLMPrice](16)
+var llmModelCache = cache.New[string, model.LLMInfo](16)
 
 func LLMList(ctx context.Context) ([]model.LLMInfo, error) {
 	models := make([]model.LLMInfo, 0, llmModelCache.Len())
-	for m, cost := range llmModelCache.GetAll() {
-		models = append(models, model.LLMInfo{
-			Name:     m,
-			LLMPrice: cost,
-		})
+	for _, info := range llmModelCache.GetAll() {
+		models = append(models, info)
 	}
 	return models, nil
 }
@@ -31,7 +28,7 @@ func LLMUpdate(model model.LLMInfo, ctx context.Context) error {
 	if err := db.GetDB().WithContext(ctx).Save(model).Error; err != nil {
 		return err
 	}
-	llmModelCache.Set(model.Name, model.LLMPrice)
+	llmModelCache.Set(model.Name, model)
 	return nil
 }
 
@@ -65,7 +62,7 @@ func LLMCreate(model model.LLMInfo, ctx context.Context) error {
 	if err := db.GetDB().WithContext(ctx).Create(&model).Error; err != nil {
 		return err
 	}
-	llmModelCache.Set(model.Name, model.LLMPrice)
+	llmModelCache.Set(model.Name, model)
 	return nil
 }
 func LLMBatchCreate(llmInfos []model.LLMInfo, ctx context.Context) error {
@@ -92,16 +89,48 @@ func LLMBatchCreate(llmInfos []model.LLMInfo, ctx context.Context) error {
 		return err
 	}
 	for _, llmInfo := range newLLMInfos {
-		llmModelCache.Set(llmInfo.Name, llmInfo.LLMPrice)
+		llmModelCache.Set(llmInfo.Name, llmInfo)
 	}
 	return nil
 }
 func LLMGet(name string) (model.LLMPrice, error) {
-	price, ok := llmModelCache.Get(name)
+	info, ok := llmModelCache.Get(name)
 	if !ok {
 		return model.LLMPrice{}, fmt.Errorf("model not found")
 	}
-	return price, nil
+	return info.LLMPrice, nil
+}
+
+// LLMGetLimit 获取模型的上下文窗口和最大输出配置
+func LLMGetLimit(name string) (model.LLMLimit, error) {
+	info, ok := llmModelCache.Get(name)
+	if !ok {
+		return model.LLMLimit{}, fmt.Errorf("model not found")
+	}
+	return info.LLMLimit, nil
+}
+
+// LLMGetCapabilities 获取模型管理中记录的模型能力
+func LLMGetCapabilities(name string) (model.LLMCapabilities, error) {
+	info, ok := llmModelCache.Get(name)
+	if !ok {
+		return model.LLMCapabilities{}, fmt.Errorf("model not found")
+	}
+	return info.LLMCapabilities, nil
+}
+
+// LLMUpdateCapabilities 合并探测到的模型能力，已记录的能力以 capabilities 中已知的值为准
+func LLMUpdateCapabilities(name string, capabilities model.LLMCapabilities, ctx context.Context) error {
+	info, ok := llmModelCache.Get(name)
+	if !ok {
+		return fmt.Errorf("model not found")
+	}
+	info.LLMCapabilities = info.LLMCapabilities.Merge(capabilities)
+	if err := db.GetDB().WithContext(ctx).Save(&info).Error; err != nil {
+		return err
+	}
+	llmModelCache.Set(info.Name, info)
+	return nil
 }
 
 func llmRefreshCache(ctx context.Context) error {
@@ -110,7 +139,7 @@ func llmRefreshCache(ctx context.Context) error {
 		return err
 	}
 	for _, model := range models {
-		llmModelCache.Set(model.Name, model.LLMPrice)
+		llmModelCache.Set(model.Name, model)
 	}
 	return nil
 }
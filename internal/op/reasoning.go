@@ -0,0 +1,97 @@
+package op
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/bestruirui/octopus/internal/db"
+	"github.com/bestruirui/octopus/internal/model"
+	transformer "github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/bestruirui/octopus/internal/utils/cache"
+	"github.com/samber/lo"
+)
+
+var reasoningRuleCache = cache.New[int, model.ReasoningRule](16)
+
+func ReasoningRuleCreate(rule *model.ReasoningRule, ctx context.Context) error {
+	if err := db.GetDB().WithContext(ctx).Create(rule).Error; err != nil {
+		return fmt.Errorf("failed to create reasoning rule: %w", err)
+	}
+	reasoningRuleCache.Set(rule.ID, *rule)
+	reasoningApplyMappings()
+	return nil
+}
+
+func ReasoningRuleUpdate(rule *model.ReasoningRule, ctx context.Context) error {
+	if _, ok := reasoningRuleCache.Get(rule.ID); !ok {
+		return fmt.Errorf("reasoning rule not found")
+	}
+	// 只更新已存在的规则，不使用 Save 以免规则被并发删除后重新插入
+	result := db.GetDB().WithContext(ctx).Model(rule).Select("*").Updates(rule)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update reasoning rule: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("reasoning rule not found")
+	}
+	reasoningRuleCache.Set(rule.ID, *rule)
+	reasoningApplyMappings()
+	return nil
+}
+
+func ReasoningRuleList(ctx context.Context) ([]model.ReasoningRule, error) {
+	return reasoningRuleSorted(), nil
+}
+
+func ReasoningRuleDelete(id int, ctx context.Context) error {
+	result := db.GetDB().WithContext(ctx).Delete(&model.ReasoningRule{ID: id})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete reasoning rule: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("reasoning rule not found")
+	}
+	reasoningRuleCache.Del(id)
+	reasoningApplyMappings()
+	return nil
+}
+
+// reasoningRuleSorted 按优先级从高到低排列，优先级相同时按创建顺序
+func reasoningRuleSorted() []model.ReasoningRule {
+	rules := make([]model.ReasoningRule, 0, reasoningRuleCache.Len())
+	for _, rule := range reasoningRuleCache.GetAll() {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority > rules[j].Priority
+		}
+		return rules[i].ID < rules[j].ID
+	})
+	return rules
+}
+
+// reasoningApplyMappings 将启用的规则同步到转换层
+func reasoningApplyMappings() {
+	mappings := make([]transformer.ReasoningMapping, 0, reasoningRuleCache.Len())
+	for _, rule := range reasoningRuleSorted() {
+		if lo.FromPtr(rule.Enabled) {
+			mappings = append(mappings, rule.ReasoningMapping)
+		}
+	}
+	transformer.SetReasoningMappings(mappings)
+}
+
+func reasoningRefreshCache(ctx context.Context) error {
+	rules := []model.ReasoningRule{}
+	if err := db.GetDB().WithContext(ctx).Find(&rules).Error; err != nil {
+		return err
+	}
+	reasoningRuleCache.Clear()
+	for _, rule := range rules {
+		reasoningRuleCache.Set(rule.ID, rule)
+	}
+	reasoningApplyMappings()
+	return nil
+}
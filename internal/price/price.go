@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/bestruirui/octopus/internal/model"
 	"github.com/bestruirui/octopus/internal/op"
 	"github.com/bestruirui/octopus/internal/utils/log"
+	"github.com/samber/lo"
 )
 
 const llmPriceUrl = "https://models.dev/api.json"
@@ -32,6 +34,12 @@ var Provider = []string{
 
 var lastUpdateTime time.Time
 
+// llmLimit 从 models.dev 获取的模型上下文窗口和最大输出，与 llmPrice 共用锁
+var llmLimit = map[string]model.LLMLimit{}
+
+// llmCapabilities 从 models.dev 获取的模型能力，与 llmPrice 共用锁
+var llmCapabilities = map[string]model.LLMCapabilities{}
+
 func UpdateLLMPrice(ctx context.Context) error {
 	log.Debugf("update LLM price task started")
 	startTime := time.Now()
@@ -56,8 +64,17 @@ func UpdateLLMPrice(ctx context.Context) error {
 	}
 	var rawPrice map[string]struct {
 		Models map[string]struct {
-			ID   string         `json:"id"`
-			Cost model.LLMPrice `json:"cost"`
+			ID    string         `json:"id"`
+			Cost  model.LLMPrice `json:"cost"`
+			Limit struct {
+				Context int64 `json:"context"`
+				Output  int64 `json:"output"`
+			} `json:"limit"`
+			ToolCall   *bool `json:"tool_call"`
+			Reasoning  *bool `json:"reasoning"`
+			Modalities *struct {
+				Input []string `json:"input"`
+			} `json:"modalities"`
 		} `json:"models"`
 	}
 	body, err := io.ReadAll(resp.Body)
@@ -69,9 +86,18 @@ func UpdateLLMPrice(ctx context.Context) error {
 	}
 	llmPriceLock.Lock()
 	for _, provider := range Provider {
-		for _, model := range rawPrice[provider].Models {
-			model.ID = strings.ToLower(model.ID)
-			llmPrice[model.ID] = model.Cost
+		for _, llm := range rawPrice[provider].Models {
+			llm.ID = strings.ToLower(llm.ID)
+			llmPrice[llm.ID] = llm.Cost
+			if llm.Limit.Context > 0 || llm.Limit.Output > 0 {
+				llmLimit[llm.ID] = model.LLMLimit{ContextWindow: llm.Limit.Context, MaxOutput: llm.Limit.Output}
+			}
+			capabilities := model.LLMCapabilities{Tools: llm.ToolCall, Reasoning: llm.Reasoning}
+			if llm.Modalities != nil {
+				capabilities.Vision = lo.ToPtr(slices.Contains(llm.Modalities.Input, "image"))
+				capabilities.Audio = lo.ToPtr(slices.Contains(llm.Modalities.Input, "audio"))
+			}
+			llmCapabilities[llm.ID] = capabilities
 		}
 	}
 	llmPriceLock.Unlock()
@@ -97,3 +123,31 @@ func GetLLMPrice(modelName string) *model.LLMPrice {
 	}
 	return &price
 }
+
+// GetLLMLimit 获取模型的上下文窗口和最大输出，优先使用模型管理中配置的值，均未知时返回 nil
+func GetLLMLimit(modelName string) *model.LLMLimit {
+	modelName = strings.ToLower(modelName)
+	limit, err := op.LLMGetLimit(modelName)
+	if err == nil && (limit.ContextWindow > 0 || limit.MaxOutput > 0) {
+		return &limit
+	}
+	llmPriceLock.RLock()
+	defer llmPriceLock.RUnlock()
+	limit, ok := llmLimit[modelName]
+	if !ok {
+		return nil
+	}
+	return &limit
+}
+
+// GetLLMCapabilities 获取模型的能力，模型管理中记录的能力覆盖 models.dev 的数据，均未知的能力为 nil
+func GetLLMCapabilities(modelName string) model.LLMCapabilities {
+	modelName = strings.ToLower(modelName)
+	llmPriceLock.RLock()
+	capabilities := llmCapabilities[modelName]
+	llmPriceLock.RUnlock()
+	if recorded, err := op.LLMGetCapabilities(modelName); err == nil {
+		capabilities = capabilities.Merge(recorded)
+	}
+	return capabilities
+}
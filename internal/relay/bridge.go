@@ -0,0 +1,180 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bestruirui/octopus/internal/relay/interceptor"
+	"github.com/bestruirui/octopus/internal/transformer/inbound"
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/bestruirui/octopus/internal/utils/log"
+)
+
+// handleStreamAsResponse 上游流式、客户端非流式：读取完整的上游流并聚合为非流式响应
+func (rc *relayContext) handleStreamAsResponse(ctx context.Context, response *http.Response) error {
+	// 上游忽略了 stream 参数直接返回 JSON 时按非流式处理
+	if ct := response.Header.Get("Content-Type"); !rc.customStreamDecoder() && ct != "" && !strings.Contains(strings.ToLower(ct), "text/event-stream") {
+		return rc.handleResponse(ctx, response)
+	}
+	internalResponse, err := rc.aggregateStream(ctx, response.Body)
+	if err != nil {
+		return err
+	}
+	return rc.writeResponse(ctx, internalResponse)
+}
+
+// aggregateStream 读取完整的上游流并聚合为内部响应，每次尝试使用新的聚合器
+// 入站适配器在多次尝试间共用，直接交给它聚合会混入之前失败渠道的部分内容
+// 出站已将流式块转换为内部格式，内部格式与 OpenAI Chat 一致，因此不论客户端使用哪种入站协议，
+// 都使用 OpenAI Chat 入站聚合：它只合并内部数据块、不做协议转换，聚合结果再交给客户端的入站适配器转换
+func (rc *relayContext) aggregateStream(ctx context.Context, body io.Reader) (*model.InternalLLMResponse, error) {
+	// 流式相邻数据块的最大间隔，超时后取消上游请求
+	var idleTimer *time.Timer
+	idleTimeout := time.Duration(rc.channel.StreamIdleTimeOut) * time.Second
+	if idleTimeout > 0 {
+		idleTimer = time.AfterFunc(idleTimeout, func() {
+			rc.cancelUpstream(fmt.Errorf("%w: stream idle time out (%ds)", errUpstreamTimeout, rc.channel.StreamIdleTimeOut))
+		})
+		defer idleTimer.Stop()
+	}
+
+	aggregator := inbound.Get(inbound.InboundTypeOpenAIChat)
+	for event, err := range rc.readStream(body) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stream event: %w", err)
+		}
+		if idleTimer != nil {
+			idleTimer.Reset(idleTimeout)
+		}
+		internalStream, err := rc.outboundStream(ctx, event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to transform outbound stream: %w", err)
+		}
+		if internalStream == nil || internalStream.Object == "[DONE]" {
+			continue
+		}
+		if _, err := aggregator.TransformStream(ctx, internalStream); err != nil {
+			return nil, fmt.Errorf("failed to aggregate stream: %w", err)
+		}
+	}
+
+	internalResponse, err := aggregator.GetInternalResponse(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate stream response: %w", err)
+	}
+	if internalResponse == nil {
+		return nil, fmt.Errorf("upstream stream returned no data")
+	}
+	return internalResponse, nil
+}
+
+// handleResponseAsStream 上游非流式、客户端流式：将完整响应拆分为流式块后按 SSE 返回
+func (rc *relayContext) handleResponseAsStream(ctx context.Context, response *http.Response) error {
+	internalResponse, err := rc.outboundResponse(ctx, response)
+	if err != nil {
+		log.Warnf("failed to transform response: %v", err)
+		return fmt.Errorf("failed to transform outbound response: %w", err)
+	}
+	return rc.writeResponseAsStream(ctx, internalResponse)
+}
+
+// writeResponseAsStream 将完整的内部响应拆分为流式块，转换为入站格式后按 SSE 写回客户端
+func (rc *relayContext) writeResponseAsStream(ctx context.Context, internalResponse *model.InternalLLMResponse) error {
+	rc.stopBufferedHeartbeat()
+	rc.setSSEHeaders()
+	rc.streamed = true
+	firstToken := true
+	for _, chunk := range responseToStreamChunks(internalResponse) {
+		if chunk.Object != "[DONE]" {
+			if err := interceptor.OnStreamChunk(ctx, rc.hookInfo, chunk); err != nil {
+				return err
+			}
+		}
+		rc.reasoningFilter.apply(chunk)
+		data, err := rc.inAdapter.TransformStream(ctx, chunk)
+		if err != nil {
+			return fmt.Errorf("failed to transform inbound stream: %w", err)
+		}
+		if len(data) == 0 {
+			continue
+		}
+		if firstToken {
+			rc.metrics.SetFirstTokenTime(time.Now())
+			firstToken = false
+		}
+		rc.c.Writer.Write(data)
+		rc.c.Writer.Flush()
+		rc.outputWritten = true
+	}
+	if ctx.Err() != nil {
+		rc.metrics.SetClientCanceled()
+	}
+	return nil
+}
+
+// responseToStreamChunks 将非流式响应拆分为流式块
+// 顺序为：每个 choice 的完整 delta、finish_reason、usage，最后是 [DONE]
+func responseToStreamChunks(resp *model.InternalLLMResponse) []*model.InternalLLMResponse {
+	newChunk := func(choices []model.Choice) *model.InternalLLMResponse {
+		return &model.InternalLLMResponse{
+			ID:                resp.ID,
+			Object:            "chat.completion.chunk",
+			Created:           resp.Created,
+			Model:             resp.Model,
+			SystemFingerprint: resp.SystemFingerprint,
+			ServiceTier:       resp.ServiceTier,
+			Choices:           choices,
+		}
+	}
+
+	chunks := make([]*model.InternalLLMResponse, 0, 2*len(resp.Choices)+2)
+	for _, choice := range resp.Choices {
+		if choice.Message == nil {
+			continue
+		}
+		delta := *choice.Message
+		if delta.Role == "" {
+			delta.Role = "assistant"
+		}
+		// 图片统一放入 MultipleContent，避免流式聚合时重复
+		if len(delta.Images) > 0 {
+			delta.Content.MultipleContent = append(delta.Content.MultipleContent, delta.Images...)
+			delta.Images = nil
+		}
+		for i := range delta.ToolCalls {
+			delta.ToolCalls[i].Index = i
+		}
+		chunks = append(chunks, newChunk([]model.Choice{{
+			Index:    choice.Index,
+			Delta:    &delta,
+			Logprobs: choice.Logprobs,
+		}}))
+
+		finishReason := choice.FinishReason
+		if finishReason == nil {
+			reason := "stop"
+			if len(delta.ToolCalls) > 0 {
+				reason = "tool_calls"
+			}
+			finishReason = &reason
+		}
+		chunks = append(chunks, newChunk([]model.Choice{{
+			Index:        choice.Index,
+			Delta:        &model.Message{},
+			FinishReason: finishReason,
+		}}))
+	}
+
+	usageChunk := newChunk(nil)
+	usageChunk.Usage = resp.Usage
+	if usageChunk.Usage == nil {
+		usageChunk.Usage = &model.Usage{}
+	}
+	chunks = append(chunks, usageChunk)
+	chunks = append(chunks, &model.InternalLLMResponse{Object: "[DONE]"})
+	return chunks
+}
@@ -0,0 +1,83 @@
+package relay
+
+import (
+	"context"
+	"strings"
+
+	dbmodel "github.com/bestruirui/octopus/internal/model"
+	"github.com/bestruirui/octopus/internal/op"
+	"github.com/bestruirui/octopus/internal/price"
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/samber/lo"
+)
+
+// requiredCapabilities 请求实际用到的模型能力
+type requiredCapabilities struct {
+	vision, tools, reasoning, audio bool
+}
+
+// any 请求是否需要任一能力
+func (r requiredCapabilities) any() bool {
+	return r.vision || r.tools || r.reasoning || r.audio
+}
+
+// String 返回需要的能力列表，用于错误信息
+func (r requiredCapabilities) String() string {
+	var names []string
+	for _, c := range []struct {
+		name     string
+		required bool
+	}{{"vision", r.vision}, {"tools", r.tools}, {"reasoning", r.reasoning}, {"audio", r.audio}} {
+		if c.required {
+			names = append(names, c.name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// requestCapabilities 根据消息内容、函数工具和推理参数判断请求需要的能力
+func requestCapabilities(req *model.InternalLLMRequest) requiredCapabilities {
+	var required requiredCapabilities
+	for _, msg := range req.Messages {
+		for _, part := range msg.Content.MultipleContent {
+			switch {
+			case part.Type == "image_url" && part.ImageURL != nil:
+				required.vision = true
+			case part.Type == "input_audio" && part.Audio != nil:
+				required.audio = true
+			}
+		}
+	}
+	required.tools = lo.ContainsBy(req.Tools, func(tool model.Tool) bool { return tool.Type == "function" })
+	switch {
+	case req.ReasoningBudget != nil:
+		required.reasoning = *req.ReasoningBudget != 0
+	case req.ReasoningEffort != "":
+		required.reasoning = req.ReasoningEffort != "none" && req.ReasoningEffort != "minimal"
+	case req.EnableThinking != nil:
+		required.reasoning = *req.EnableThinking
+	}
+	return required
+}
+
+// filterCapabilityItems 过滤明确不支持请求所需能力的分组项，能力未知的模型视为支持
+// 渠道通过提示词模拟函数调用时不要求模型支持函数调用
+func filterCapabilityItems(ctx context.Context, items []dbmodel.GroupItem, required requiredCapabilities) []dbmodel.GroupItem {
+	if !required.any() {
+		return items
+	}
+	return lo.Filter(items, func(item dbmodel.GroupItem, _ int) bool {
+		capabilities := price.GetLLMCapabilities(item.ModelName)
+		unsupported := func(needed bool, supported *bool) bool {
+			return needed && supported != nil && !*supported
+		}
+		if unsupported(required.vision, capabilities.Vision) || unsupported(required.reasoning, capabilities.Reasoning) || unsupported(required.audio, capabilities.Audio) {
+			return false
+		}
+		if unsupported(required.tools, capabilities.Tools) {
+			channel, err := op.ChannelGet(item.ChannelID, ctx)
+			return err == nil && channel.Options.EmulatesTools(item.ModelName)
+		}
+		return true
+	})
+}
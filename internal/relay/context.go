@@ -0,0 +1,120 @@
+package relay
+
+import (
+	dbmodel "github.com/bestruirui/octopus/internal/model"
+	"github.com/bestruirui/octopus/internal/price"
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/bestruirui/octopus/internal/utils/log"
+	"github.com/samber/lo"
+)
+
+// requestedOutputTokens 请求指定的最大输出 token 数，未指定时为 0
+func requestedOutputTokens(req *model.InternalLLMRequest) int64 {
+	if req.MaxCompletionTokens != nil {
+		return *req.MaxCompletionTokens
+	}
+	return lo.FromPtr(req.MaxTokens)
+}
+
+// contextBudget 返回模型可用于输入的 token 数，预留请求的输出（不超过模型的最大输出）
+// 模型的上下文窗口未知时返回 -1
+func contextBudget(modelName string, outputTokens int64) int64 {
+	limit := price.GetLLMLimit(modelName)
+	if limit == nil || limit.ContextWindow <= 0 {
+		return -1
+	}
+	if limit.MaxOutput > 0 && outputTokens > limit.MaxOutput {
+		outputTokens = limit.MaxOutput
+	}
+	return limit.ContextWindow - outputTokens
+}
+
+// filterContextItems 过滤上下文窗口放不下请求的分组项，上下文窗口未知的模型视为可用
+func filterContextItems(items []dbmodel.GroupItem, promptTokens, outputTokens int64) []dbmodel.GroupItem {
+	return lo.Filter(items, func(item dbmodel.GroupItem, _ int) bool {
+		budget := contextBudget(item.ModelName, outputTokens)
+		return budget < 0 || promptTokens <= budget
+	})
+}
+
+// contextUnit 截断时整体移除的消息范围，助手的工具调用与其后的工具结果属于同一单元
+type contextUnit struct {
+	start, end int
+	tokens     int64
+}
+
+// truncateMiddleOut 从对话中间向两侧移除消息，直到请求不超过 budget 个 token
+// 开头的系统消息和最后一个单元始终保留，放不下时返回移除后最短的请求
+func truncateMiddleOut(req *model.InternalLLMRequest, budget int64) *model.InternalLLMRequest {
+	if budget < 0 {
+		return req
+	}
+
+	head := 0
+	for head < len(req.Messages) && (req.Messages[head].Role == "system" || req.Messages[head].Role == "developer") {
+		head++
+	}
+	total := countToolTokens(req)
+	for _, msg := range req.Messages[:head] {
+		total += countMessageTokens(&msg, req.Model)
+	}
+	var units []contextUnit
+	for i := head; i < len(req.Messages); {
+		unit := contextUnit{start: i, end: i + 1}
+		if req.Messages[i].Role == "assistant" && len(req.Messages[i].ToolCalls) > 0 {
+			for unit.end < len(req.Messages) && req.Messages[unit.end].Role == "tool" {
+				unit.end++
+			}
+		}
+		for _, msg := range req.Messages[unit.start:unit.end] {
+			unit.tokens += countMessageTokens(&msg, req.Model)
+		}
+		total += unit.tokens
+		units = append(units, unit)
+		i = unit.end
+	}
+	if total <= budget || len(units) < 2 {
+		return req
+	}
+
+	originalTotal := total
+	removed := make([]bool, len(units))
+	last := len(units) - 1
+	mid := last / 2
+	// 依次移除 mid、mid+1、mid-1、mid+2 ...
+	for offset := 0; total > budget && (mid-offset >= 0 || mid+offset < last); offset++ {
+		for _, index := range []int{mid + offset, mid - offset} {
+			if total <= budget || index < 0 || index >= last || removed[index] {
+				continue
+			}
+			removed[index] = true
+			total -= units[index].tokens
+		}
+	}
+	// 部分服务商要求系统消息后的第一条消息来自用户
+	for i := 0; i < last; i++ {
+		if removed[i] {
+			continue
+		}
+		if req.Messages[units[i].start].Role != "assistant" {
+			break
+		}
+		removed[i] = true
+		total -= units[i].tokens
+	}
+
+	messages := append([]model.Message{}, req.Messages[:head]...)
+	removedCount := 0
+	for i, unit := range units {
+		if removed[i] {
+			removedCount += unit.end - unit.start
+			continue
+		}
+		messages = append(messages, req.Messages[unit.start:unit.end]...)
+	}
+	log.Infof("context truncated for model %s: removed %d messages, about %d -> %d tokens (budget %d)", req.Model, removedCount, originalTotal, total, budget)
+
+	truncated := *req
+	truncated.Messages = messages
+	return &truncated
+}
@@ -0,0 +1,130 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/bestruirui/octopus/internal/helper"
+	dbmodel "github.com/bestruirui/octopus/internal/model"
+	"github.com/bestruirui/octopus/internal/transformer/model"
+)
+
+// embeddingBatchOutbound 输入条数超过上游单次请求上限时，将 embedding 请求按上限拆分为多个请求依次发送
+// 每个请求使用独立的出站适配器，合并时按拆分前的位置改写 index，usage 累加
+type embeddingBatchOutbound struct {
+	channel  *dbmodel.Channel
+	adapters []model.Outbound
+	requests []*http.Request
+	// offsets 每个请求第一条输入在原请求中的位置
+	offsets []int
+
+	// response 合并后的响应
+	response *model.InternalLLMResponse
+}
+
+// newEmbeddingBatchOutbound 以 outAdapter 作为第一个请求的适配器，其余请求创建新的适配器
+func newEmbeddingBatchOutbound(channel *dbmodel.Channel, outAdapter model.Outbound, batches int) (*embeddingBatchOutbound, error) {
+	adapters := []model.Outbound{outAdapter}
+	for len(adapters) < batches {
+		adapter, err := newOutboundAdapter(channel)
+		if err != nil {
+			return nil, err
+		}
+		adapters = append(adapters, adapter)
+	}
+	return &embeddingBatchOutbound{channel: channel, adapters: adapters}, nil
+}
+
+func (o *embeddingBatchOutbound) TransformRequest(ctx context.Context, request *model.InternalLLMRequest, baseUrl, key string) (*http.Request, error) {
+	texts := request.EmbeddingInput.Multiple
+	size := (len(texts) + len(o.adapters) - 1) / len(o.adapters)
+	o.requests = make([]*http.Request, len(o.adapters))
+	o.offsets = make([]int, len(o.adapters))
+	for i, adapter := range o.adapters {
+		start := min(i*size, len(texts))
+		batch := *request
+		batch.EmbeddingInput = &model.EmbeddingInput{Multiple: texts[start:min(start+size, len(texts))]}
+		req, err := adapter.TransformRequest(ctx, &batch, baseUrl, key)
+		if err != nil {
+			return nil, err
+		}
+		o.requests[i] = req
+		o.offsets[i] = start
+	}
+	// 中转的请求头和拦截器作用于该请求，发送时复制到每个拆分的请求
+	return http.NewRequestWithContext(ctx, http.MethodPost, o.requests[0].URL.String(), http.NoBody)
+}
+
+// RoundTrip 依次发送所有请求，任一请求失败时返回该错误或上游错误响应，由中转切换渠道
+func (o *embeddingBatchOutbound) RoundTrip(req *http.Request) (*http.Response, error) {
+	httpClient, err := helper.ChannelHttpClient(o.channel)
+	if err != nil {
+		return nil, err
+	}
+
+	o.response = nil
+	for i, sub := range o.requests {
+		for key, values := range req.Header {
+			sub.Header[key] = values
+		}
+		// 使用中转请求的 context，使连接和响应头超时同样作用于拆分的请求
+		sub = sub.WithContext(req.Context())
+		var resp *http.Response
+		if transport, ok := o.adapters[i].(model.OutboundTransport); ok {
+			resp, err = transport.RoundTrip(sub)
+		} else {
+			resp, err = httpClient.Do(sub)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return resp, nil
+		}
+		err = o.mergeResponse(req.Context(), i, resp)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fanOutResponse(req, "application/json", io.NopCloser(http.NoBody)), nil
+}
+
+// mergeResponse 合并第 i 个请求的 embedding 和 usage
+func (o *embeddingBatchOutbound) mergeResponse(ctx context.Context, i int, resp *http.Response) error {
+	internalResponse, err := o.adapters[i].TransformResponse(ctx, resp)
+	if err != nil {
+		return err
+	}
+	if o.response == nil {
+		merged := *internalResponse
+		merged.EmbeddingData = nil
+		merged.Usage = nil
+		o.response = &merged
+	}
+	for _, data := range internalResponse.EmbeddingData {
+		data.Index += o.offsets[i]
+		o.response.EmbeddingData = append(o.response.EmbeddingData, data)
+	}
+	if internalResponse.Usage != nil {
+		if o.response.Usage == nil {
+			o.response.Usage = &model.Usage{}
+		}
+		o.response.Usage.Add(internalResponse.Usage)
+	}
+	return nil
+}
+
+func (o *embeddingBatchOutbound) TransformResponse(ctx context.Context, response *http.Response) (*model.InternalLLMResponse, error) {
+	if o.response == nil {
+		return nil, fmt.Errorf("upstream returned no response")
+	}
+	return o.response, nil
+}
+
+func (o *embeddingBatchOutbound) TransformStream(ctx context.Context, eventData []byte) (*model.InternalLLMResponse, error) {
+	return nil, errors.New("streaming is not supported for embedding API")
+}
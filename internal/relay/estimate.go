@@ -0,0 +1,85 @@
+package relay
+
+import (
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/bestruirui/octopus/internal/utils/tokenizer"
+	"github.com/samber/lo"
+)
+
+// estimateUsage 在上游未返回 usage 时估算 token 用量
+// 输入按请求中的文本、工具定义计算，输出按已收到的内容、推理内容和工具调用参数计算
+func estimateUsage(req *model.InternalLLMRequest, resp *model.InternalLLMResponse) *model.Usage {
+	if req == nil || resp == nil {
+		return nil
+	}
+
+	promptTokens := estimatePromptTokens(req)
+
+	var completionTokens int64
+	for _, choice := range resp.Choices {
+		if choice.Message != nil {
+			completionTokens += countMessageTokens(choice.Message, req.Model)
+		}
+	}
+
+	return &model.Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+}
+
+// estimatePromptTokens 按请求中的文本、工具定义估算输入的 token 数
+func estimatePromptTokens(req *model.InternalLLMRequest) int64 {
+	var promptTokens int64
+	for _, msg := range req.Messages {
+		promptTokens += countMessageTokens(&msg, req.Model)
+	}
+	promptTokens += countToolTokens(req)
+	if req.EmbeddingInput != nil {
+		if req.EmbeddingInput.Single != nil {
+			promptTokens += int64(tokenizer.CountTokens(*req.EmbeddingInput.Single, req.Model))
+		}
+		for _, input := range req.EmbeddingInput.Multiple {
+			promptTokens += int64(tokenizer.CountTokens(input, req.Model))
+		}
+	}
+	return promptTokens
+}
+
+// countToolTokens 计算工具定义的 token 数
+func countToolTokens(req *model.InternalLLMRequest) int64 {
+	tools := make([]tokenizer.Tool, 0, len(req.Tools))
+	for _, tool := range req.Tools {
+		tools = append(tools, tokenizer.Tool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Parameters:  string(tool.Function.Parameters),
+		})
+	}
+	return int64(tokenizer.CountToolTokens(tools, req.Model))
+}
+
+// countMessageTokens 计算单条消息中文本、图片、推理内容和工具调用的 token 数
+func countMessageTokens(msg *model.Message, modelName string) int64 {
+	var tokens int64
+	if msg.Content.Content != nil {
+		tokens += int64(tokenizer.CountTokens(*msg.Content.Content, modelName))
+	}
+	for _, part := range msg.Content.MultipleContent {
+		if part.Text != nil {
+			tokens += int64(tokenizer.CountTokens(*part.Text, modelName))
+		}
+		if part.ImageURL != nil {
+			tokens += int64(tokenizer.CountImageTokens(part.ImageURL.URL, lo.FromPtr(part.ImageURL.Detail), modelName))
+		}
+	}
+	if reasoning := msg.GetReasoningContent(); reasoning != "" {
+		tokens += int64(tokenizer.CountTokens(reasoning, modelName))
+	}
+	for _, toolCall := range msg.ToolCalls {
+		tokens += int64(tokenizer.CountTokens(toolCall.Function.Name, modelName))
+		tokens += int64(tokenizer.CountTokens(toolCall.Function.Arguments, modelName))
+	}
+	return tokens
+}
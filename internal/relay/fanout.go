@@ -0,0 +1,304 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/bestruirui/octopus/internal/helper"
+	dbmodel "github.com/bestruirui/octopus/internal/model"
+	"github.com/bestruirui/octopus/internal/transformer/model"
+)
+
+// fanOutOutbound 上游不支持 n 时将请求拆分为 n 个并发的单 choice 请求，合并后作为一个上游响应交给中转处理
+// 每个请求使用独立的出站适配器，choice 的 index 按请求顺序改写，usage 累加
+// 合并结果保存在内存中，合成的上游响应体不含数据，流式块由 decodeChunks 直接交给中转
+type fanOutOutbound struct {
+	channel  *dbmodel.Channel
+	adapters []model.Outbound
+	requests []*http.Request
+	stream   bool
+
+	// response 合并后的非流式响应
+	response *model.InternalLLMResponse
+
+	// events 合并后的流式块，done 在响应体关闭时关闭以停止读取上游
+	events chan fanOutEvent
+	done   chan struct{}
+}
+
+type fanOutEvent struct {
+	chunk *model.InternalLLMResponse
+	err   error
+}
+
+// newFanOutOutbound 以 outAdapter 作为第一个请求的适配器，其余请求创建新的适配器
+func newFanOutOutbound(channel *dbmodel.Channel, outAdapter model.Outbound, n int) (*fanOutOutbound, error) {
+	adapters := []model.Outbound{outAdapter}
+	for len(adapters) < n {
+		adapter, err := newOutboundAdapter(channel)
+		if err != nil {
+			return nil, err
+		}
+		adapters = append(adapters, adapter)
+	}
+	return &fanOutOutbound{channel: channel, adapters: adapters}, nil
+}
+
+func (o *fanOutOutbound) TransformRequest(ctx context.Context, request *model.InternalLLMRequest, baseUrl, key string) (*http.Request, error) {
+	o.stream = request.Stream != nil && *request.Stream
+	o.requests = make([]*http.Request, len(o.adapters))
+	for i, adapter := range o.adapters {
+		// 每个请求使用独立的副本，出站适配器对请求的修改不影响其他请求
+		single := *request
+		single.N = nil
+		single.Messages = slices.Clone(request.Messages)
+		req, err := adapter.TransformRequest(ctx, &single, baseUrl, key)
+		if err != nil {
+			return nil, err
+		}
+		o.requests[i] = req
+	}
+	// 中转的请求头和拦截器作用于该请求，发送时复制到每个拆分的请求
+	return http.NewRequestWithContext(ctx, http.MethodPost, o.requests[0].URL.String(), http.NoBody)
+}
+
+// RoundTrip 并发发送所有请求，任一请求失败时返回该错误或上游错误响应，由中转切换渠道
+func (o *fanOutOutbound) RoundTrip(req *http.Request) (*http.Response, error) {
+	httpClient, err := helper.ChannelHttpClient(o.channel)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*http.Response, len(o.requests))
+	errs := make([]error, len(o.requests))
+	var wg sync.WaitGroup
+	for i, sub := range o.requests {
+		for key, values := range req.Header {
+			sub.Header[key] = values
+		}
+		// 使用中转请求的 context，使连接和响应头超时同样作用于拆分的请求
+		sub = sub.WithContext(req.Context())
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if transport, ok := o.adapters[i].(model.OutboundTransport); ok {
+				responses[i], errs[i] = transport.RoundTrip(sub)
+			} else {
+				responses[i], errs[i] = httpClient.Do(sub)
+			}
+		}()
+	}
+	wg.Wait()
+
+	closeAll := func(except int) {
+		for i, resp := range responses {
+			if i != except && resp != nil {
+				resp.Body.Close()
+			}
+		}
+	}
+	for i, resp := range responses {
+		if errs[i] != nil {
+			closeAll(-1)
+			return nil, errs[i]
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			closeAll(i)
+			return resp, nil
+		}
+	}
+
+	if !o.stream {
+		defer closeAll(-1)
+		if err := o.mergeResponses(req.Context(), responses); err != nil {
+			return nil, err
+		}
+		return fanOutResponse(req, "application/json", io.NopCloser(http.NoBody)), nil
+	}
+
+	for i, resp := range responses {
+		_, customDecoder := o.adapters[i].(model.StreamDecoder)
+		if ct := resp.Header.Get("Content-Type"); !customDecoder && ct != "" && !strings.Contains(strings.ToLower(ct), "text/event-stream") {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 16*1024))
+			closeAll(-1)
+			return nil, fmt.Errorf("upstream returned non-SSE content-type %q for stream request: %s", ct, string(body))
+		}
+	}
+	o.events = make(chan fanOutEvent)
+	o.done = make(chan struct{})
+	go o.mergeStreams(req.Context(), responses)
+	return fanOutResponse(req, "text/event-stream", &fanOutBody{done: o.done, close: func() { closeAll(-1) }}), nil
+}
+
+// mergeResponses 合并非流式响应的 choice 和 usage
+func (o *fanOutOutbound) mergeResponses(ctx context.Context, responses []*http.Response) error {
+	for i, resp := range responses {
+		internalResponse, err := o.adapters[i].TransformResponse(ctx, resp)
+		if err != nil {
+			return err
+		}
+		if o.response == nil {
+			merged := *internalResponse
+			merged.Choices = nil
+			merged.Usage = nil
+			o.response = &merged
+		}
+		for _, choice := range internalResponse.Choices {
+			choice.Index = i
+			o.response.Choices = append(o.response.Choices, choice)
+		}
+		if internalResponse.Usage != nil {
+			if o.response.Usage == nil {
+				o.response.Usage = &model.Usage{}
+			}
+			o.response.Usage.Add(internalResponse.Usage)
+		}
+	}
+	return nil
+}
+
+// mergeStreams 并发读取所有流式响应，usage 在全部结束后累加为最后一个数据块
+func (o *fanOutOutbound) mergeStreams(ctx context.Context, responses []*http.Response) {
+	defer close(o.events)
+
+	usages := make([]*model.Usage, len(responses))
+	var wg sync.WaitGroup
+	for i, resp := range responses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for event, err := range readStream(o.adapters[i], resp.Body) {
+				if err != nil {
+					o.send(fanOutEvent{err: err})
+					return
+				}
+				chunk, err := o.adapters[i].TransformStream(ctx, []byte(event.data))
+				if err != nil {
+					o.send(fanOutEvent{err: err})
+					return
+				}
+				if chunk == nil || chunk.Object == "[DONE]" {
+					continue
+				}
+				if chunk.Usage != nil {
+					usages[i] = chunk.Usage
+					chunk.Usage = nil
+				}
+				if len(chunk.Choices) == 0 {
+					continue
+				}
+				for j := range chunk.Choices {
+					chunk.Choices[j].Index = i
+				}
+				if !o.send(fanOutEvent{chunk: chunk}) {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	usageChunk := &model.InternalLLMResponse{Object: "chat.completion.chunk"}
+	for _, usage := range usages {
+		if usage == nil {
+			continue
+		}
+		if usageChunk.Usage == nil {
+			usageChunk.Usage = &model.Usage{}
+		}
+		usageChunk.Usage.Add(usage)
+	}
+	if usageChunk.Usage != nil {
+		o.send(fanOutEvent{chunk: usageChunk})
+	}
+}
+
+func (o *fanOutOutbound) send(event fanOutEvent) bool {
+	select {
+	case o.events <- event:
+		return true
+	case <-o.done:
+		return false
+	}
+}
+
+func (o *fanOutOutbound) TransformResponse(ctx context.Context, response *http.Response) (*model.InternalLLMResponse, error) {
+	if o.response == nil {
+		return nil, fmt.Errorf("upstream returned no response")
+	}
+	return o.response, nil
+}
+
+// TransformStream 合并后的流式块由 decodeChunks 直接返回，不经过该方法
+func (o *fanOutOutbound) TransformStream(ctx context.Context, eventData []byte) (*model.InternalLLMResponse, error) {
+	return nil, fmt.Errorf("fan-out stream chunks are decoded directly")
+}
+
+// decodeChunks 依次返回合并后的流式块
+func (o *fanOutOutbound) decodeChunks(body io.Reader) iter.Seq2[*model.InternalLLMResponse, error] {
+	return func(yield func(*model.InternalLLMResponse, error) bool) {
+		// 各请求的 id 不同，统一使用第一个数据块的 id
+		var id string
+		for {
+			var event fanOutEvent
+			var ok bool
+			select {
+			case event, ok = <-o.events:
+			case <-o.done:
+				return
+			}
+			if !ok {
+				return
+			}
+			if event.err != nil {
+				yield(nil, event.err)
+				return
+			}
+			if id == "" {
+				id = event.chunk.ID
+			}
+			event.chunk.ID = id
+			if !yield(event.chunk, nil) {
+				return
+			}
+		}
+	}
+}
+
+// fanOutBody 合成的流式响应体，关闭时停止合并并关闭所有上游响应
+type fanOutBody struct {
+	once  sync.Once
+	done  chan struct{}
+	close func()
+}
+
+func (b *fanOutBody) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (b *fanOutBody) Close() error {
+	b.once.Do(func() {
+		close(b.done)
+		b.close()
+	})
+	return nil
+}
+
+func fanOutResponse(req *http.Request, contentType string, body io.ReadCloser) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{contentType}},
+		Body:       body,
+		Request:    req,
+	}
+}
@@ -0,0 +1,154 @@
+package relay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/bestruirui/octopus/internal/transformer/outbound"
+	"github.com/samber/lo"
+)
+
+// newFanOutServer 模拟不支持 n 的 Anthropic 上游，第 k 个请求回答 answer-k，输出 k 个 Token
+// failAt 大于 0 时第 failAt 个请求返回 500
+func newFanOutServer(t *testing.T, failAt int32) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			N      *int  `json:"n"`
+			Stream *bool `json:"stream"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil || req.N != nil {
+			http.Error(w, "n must not be sent upstream", http.StatusBadRequest)
+			return
+		}
+		k := calls.Add(1)
+		if k == failAt {
+			http.Error(w, `{"type":"error","error":{"type":"api_error","message":"boom"}}`, http.StatusInternalServerError)
+			return
+		}
+		if req.Stream == nil || !*req.Stream {
+			fmt.Fprintf(w, `{"id":"msg_%d","type":"message","role":"assistant","model":"claude","content":[{"type":"text","text":"answer-%d"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":%d}}`, k, k, k)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			fmt.Sprintf(`{"type":"message_start","message":{"id":"msg_%d","type":"message","role":"assistant","model":"claude","content":[],"usage":{"input_tokens":10,"output_tokens":0}}}`, k),
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"answer-"}}`,
+			fmt.Sprintf(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"%d"}}`, k),
+			`{"type":"content_block_stop","index":0}`,
+			fmt.Sprintf(`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":%d}}`, k),
+			`{"type":"message_stop"}`,
+		} {
+			var typed struct {
+				Type string `json:"type"`
+			}
+			json.Unmarshal([]byte(event), &typed)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typed.Type, event)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestFanOutResponse(t *testing.T) {
+	server, calls := newFanOutServer(t, 0)
+	rc, recorder := newTestRelayContext(t, outbound.OutboundTypeAnthropic, server.URL,
+		`{"model":"claude","n":3,"messages":[{"role":"user","content":"hi"}]}`)
+	if _, err := rc.forward(); err != nil {
+		t.Fatalf("forward: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 upstream calls, got %d", calls.Load())
+	}
+
+	var response model.InternalLLMResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	var answers []string
+	for i, choice := range response.Choices {
+		if choice.Index != i {
+			t.Errorf("choice %d has index %d", i, choice.Index)
+		}
+		answers = append(answers, lo.FromPtr(choice.Message.Content.Content))
+	}
+	slices.Sort(answers)
+	if !slices.Equal(answers, []string{"answer-1", "answer-2", "answer-3"}) {
+		t.Errorf("unexpected answers: %v", answers)
+	}
+	if response.Usage == nil || response.Usage.PromptTokens != 30 || response.Usage.CompletionTokens != 6 {
+		t.Errorf("expected summed usage, got %+v", response.Usage)
+	}
+}
+
+func TestFanOutStream(t *testing.T) {
+	server, _ := newFanOutServer(t, 0)
+	rc, recorder := newTestRelayContext(t, outbound.OutboundTypeAnthropic, server.URL,
+		`{"model":"claude","n":3,"stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	if _, err := rc.forward(); err != nil {
+		t.Fatalf("forward: %v", err)
+	}
+
+	contents := map[int]string{}
+	var usage *model.Usage
+	ids := map[string]bool{}
+	scanner := bufio.NewScanner(recorder.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk model.InternalLLMResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("invalid chunk %s: %v", data, err)
+		}
+		ids[chunk.ID] = true
+		for _, choice := range chunk.Choices {
+			if choice.Delta != nil {
+				contents[choice.Index] += lo.FromPtr(choice.Delta.Content.Content)
+			}
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+	if len(ids) != 1 {
+		t.Errorf("expected a single stream id, got %v", ids)
+	}
+	answers := []string{contents[0], contents[1], contents[2]}
+	slices.Sort(answers)
+	if len(contents) != 3 || !slices.Equal(answers, []string{"answer-1", "answer-2", "answer-3"}) {
+		t.Errorf("unexpected stream contents: %v", contents)
+	}
+	if usage == nil || usage.PromptTokens != 30 || usage.CompletionTokens != 6 {
+		t.Errorf("expected summed usage, got %+v", usage)
+	}
+}
+
+func TestFanOutPartialFailure(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		server, _ := newFanOutServer(t, 2)
+		rc, recorder := newTestRelayContext(t, outbound.OutboundTypeAnthropic, server.URL,
+			fmt.Sprintf(`{"model":"claude","n":3,"stream":%t,"messages":[{"role":"user","content":"hi"}]}`, stream))
+		_, err := rc.forward()
+		if err == nil || !strings.Contains(err.Error(), "upstream error: 500") {
+			t.Fatalf("stream %t: expected upstream error, got %v", stream, err)
+		}
+		if rc.outputWritten || recorder.Body.Len() != 0 {
+			t.Errorf("stream %t: partial response written to client: %s", stream, recorder.Body.String())
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package interceptor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bestruirui/octopus/internal/client"
+	dbmodel "github.com/bestruirui/octopus/internal/model"
+	"github.com/bestruirui/octopus/internal/op"
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/bestruirui/octopus/internal/utils/log"
+)
+
+const (
+	HookStageBeforeRequest = "before_request"
+	HookStageAfterResponse = "after_response"
+
+	HookActionContinue = "continue"
+	HookActionModify   = "modify"
+	HookActionReject   = "reject"
+)
+
+// HookPayload 发送给外部 Hook 的请求体
+type HookPayload struct {
+	Stage    string                     `json:"stage"`
+	Info     *Info                      `json:"info"`
+	Request  *model.InternalLLMRequest  `json:"request,omitempty"`
+	Response *model.InternalLLMResponse `json:"response,omitempty"`
+}
+
+// HookResult 外部 Hook 的返回，空响应体视为 continue
+type HookResult struct {
+	Action     string          `json:"action"`
+	Request    json.RawMessage `json:"request,omitempty"`
+	Response   json.RawMessage `json:"response,omitempty"`
+	StatusCode int             `json:"status_code,omitempty"`
+	Message    string          `json:"message,omitempty"`
+}
+
+// ExternalHook 将请求和非流式响应 POST 到设置中的 Hook 地址，并应用返回的修改或拒绝
+// 未配置地址时不做任何处理；Hook 调用失败时记录日志并放行请求
+type ExternalHook struct{}
+
+func init() {
+	Register(&ExternalHook{})
+}
+
+func (h *ExternalHook) BeforeRequest(ctx context.Context, info *Info, req *model.InternalLLMRequest) error {
+	result, err := h.call(ctx, &HookPayload{Stage: HookStageBeforeRequest, Info: info, Request: req})
+	if err != nil || result == nil {
+		return err
+	}
+	switch result.Action {
+	case HookActionReject:
+		return result.rejectError()
+	case HookActionModify:
+		if len(result.Request) == 0 {
+			return nil
+		}
+		var modified model.InternalLLMRequest
+		if err := json.Unmarshal(result.Request, &modified); err != nil {
+			log.Warnf("relay hook returned invalid request: %v", err)
+			return nil
+		}
+		if err := modified.Validate(); err != nil {
+			log.Warnf("relay hook returned invalid request: %v", err)
+			return nil
+		}
+		restoreRequestHelpFields(req, &modified)
+		*req = modified
+	}
+	return nil
+}
+
+func (h *ExternalHook) BeforeUpstream(ctx context.Context, info *Info, req *http.Request) error {
+	return nil
+}
+
+func (h *ExternalHook) OnStreamChunk(ctx context.Context, info *Info, chunk *model.InternalLLMResponse) error {
+	return nil
+}
+
+func (h *ExternalHook) AfterResponse(ctx context.Context, info *Info, resp *model.InternalLLMResponse) error {
+	result, err := h.call(ctx, &HookPayload{Stage: HookStageAfterResponse, Info: info, Response: resp})
+	if err != nil || result == nil {
+		return err
+	}
+	switch result.Action {
+	case HookActionReject:
+		return result.rejectError()
+	case HookActionModify:
+		if len(result.Response) == 0 {
+			return nil
+		}
+		var modified model.InternalLLMResponse
+		if err := json.Unmarshal(result.Response, &modified); err != nil {
+			log.Warnf("relay hook returned invalid response: %v", err)
+			return nil
+		}
+		// usage 仍以上游返回为准，避免 Hook 影响计费
+		modified.Usage = resp.Usage
+		*resp = modified
+	}
+	return nil
+}
+
+// call 调用外部 Hook，未配置地址或调用失败时返回 nil
+func (h *ExternalHook) call(ctx context.Context, payload *HookPayload) (*HookResult, error) {
+	hookURL, err := op.SettingGetString(dbmodel.SettingKeyRelayHookURL)
+	if err != nil || hookURL == "" {
+		return nil, nil
+	}
+	timeoutSec, err := op.SettingGetInt(dbmodel.SettingKeyRelayHookTimeout)
+	if err != nil || timeoutSec <= 0 {
+		timeoutSec = 5
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Warnf("failed to marshal relay hook payload: %v", err)
+		return nil, nil
+	}
+
+	hookCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSec)*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(hookCtx, http.MethodPost, hookURL, bytes.NewReader(body))
+	if err != nil {
+		log.Warnf("failed to create relay hook request: %v", err)
+		return nil, nil
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	httpClient, err := client.GetHTTPClientSystemProxy(false)
+	if err != nil {
+		log.Warnf("failed to get http client: %v", err)
+		return nil, nil
+	}
+	response, err := httpClient.Do(req)
+	if err != nil {
+		log.Warnf("relay hook %s failed: %v", payload.Stage, err)
+		return nil, nil
+	}
+	defer response.Body.Close()
+
+	respBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		log.Warnf("failed to read relay hook response: %v", err)
+		return nil, nil
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		log.Warnf("relay hook %s returned status %d: %s", payload.Stage, response.StatusCode, string(respBody))
+		return nil, nil
+	}
+	if len(bytes.TrimSpace(respBody)) == 0 {
+		return nil, nil
+	}
+
+	var result HookResult
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		log.Warnf("failed to parse relay hook response: %v", err)
+		return nil, nil
+	}
+	return &result, nil
+}
+
+func (r *HookResult) rejectError() *RejectError {
+	statusCode := r.StatusCode
+	if statusCode < 400 || statusCode > 599 {
+		statusCode = http.StatusForbidden
+	}
+	message := r.Message
+	if message == "" {
+		message = "request rejected by hook"
+	}
+	return &RejectError{StatusCode: statusCode, Message: message}
+}
+
+// restoreRequestHelpFields 将不参与序列化的辅助字段从原请求复制到修改后的请求
+// 消息数量未变时按位置保留每条消息的辅助字段
+func restoreRequestHelpFields(src, dst *model.InternalLLMRequest) {
+	dst.ReasoningBudget = src.ReasoningBudget
+	dst.ReasoningMapping = src.ReasoningMapping
+	dst.RawRequest = src.RawRequest
+	dst.RawAPIFormat = src.RawAPIFormat
+	dst.TransformerMetadata = src.TransformerMetadata
+	dst.Include = src.Include
+	dst.Query = src.Query
+	if len(src.Messages) != len(dst.Messages) {
+		return
+	}
+	for i := range dst.Messages {
+		dst.Messages[i].MessageIndex = src.Messages[i].MessageIndex
+		dst.Messages[i].ToolCallName = src.Messages[i].ToolCallName
+		dst.Messages[i].ToolCallIsError = src.Messages[i].ToolCallIsError
+		dst.Messages[i].CacheControl = src.Messages[i].CacheControl
+	}
+}
@@ -0,0 +1,183 @@
+package interceptor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bestruirui/octopus/internal/db"
+	dbmodel "github.com/bestruirui/octopus/internal/model"
+	"github.com/bestruirui/octopus/internal/op"
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/samber/lo"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "octopus-interceptor")
+	if err != nil {
+		panic(err)
+	}
+	if err := db.InitDB("sqlite", filepath.Join(dir, "test.db"), false); err != nil {
+		panic(err)
+	}
+	if err := op.InitCache(); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	db.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// setupHook 将 Hook 地址指向 handler
+func setupHook(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	if err := op.SettingSetString(dbmodel.SettingKeyRelayHookURL, server.URL); err != nil {
+		t.Fatalf("set hook url: %v", err)
+	}
+	if err := op.SettingSetInt(dbmodel.SettingKeyRelayHookTimeout, 1); err != nil {
+		t.Fatalf("set hook timeout: %v", err)
+	}
+	t.Cleanup(func() { op.SettingSetString(dbmodel.SettingKeyRelayHookURL, "") })
+}
+
+func newTestRequest() *model.InternalLLMRequest {
+	return &model.InternalLLMRequest{
+		Model:    "gpt-4o",
+		Messages: []model.Message{{Role: "user", Content: model.MessageContent{Content: lo.ToPtr("hi")}}},
+	}
+}
+
+// modelRecorder 记录 BeforeRequest 时看到的模型
+type modelRecorder struct {
+	seen string
+}
+
+func (m *modelRecorder) BeforeRequest(ctx context.Context, info *Info, req *model.InternalLLMRequest) error {
+	m.seen = info.RequestModel
+	return nil
+}
+
+func (m *modelRecorder) BeforeUpstream(ctx context.Context, info *Info, req *http.Request) error {
+	return nil
+}
+
+func (m *modelRecorder) OnStreamChunk(ctx context.Context, info *Info, chunk *model.InternalLLMResponse) error {
+	return nil
+}
+
+func (m *modelRecorder) AfterResponse(ctx context.Context, info *Info, resp *model.InternalLLMResponse) error {
+	return nil
+}
+
+func TestBeforeRequestModify(t *testing.T) {
+	setupHook(t, func(w http.ResponseWriter, r *http.Request) {
+		var payload HookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Stage != HookStageBeforeRequest {
+			http.Error(w, "bad payload", http.StatusBadRequest)
+			return
+		}
+		payload.Request.Model = "gpt-4o-mini"
+		request, _ := json.Marshal(payload.Request)
+		json.NewEncoder(w).Encode(HookResult{Action: HookActionModify, Request: request})
+	})
+	recorder := &modelRecorder{}
+	Register(recorder)
+	defer func() {
+		lock.Lock()
+		interceptors = interceptors[:len(interceptors)-1]
+		lock.Unlock()
+	}()
+
+	req := newTestRequest()
+	info := &Info{RequestModel: req.Model}
+	if err := BeforeRequest(context.Background(), info, req); err != nil {
+		t.Fatalf("BeforeRequest: %v", err)
+	}
+	if req.Model != "gpt-4o-mini" || info.RequestModel != "gpt-4o-mini" {
+		t.Fatalf("expected rewritten model, got request %s info %s", req.Model, info.RequestModel)
+	}
+	if recorder.seen != "gpt-4o-mini" {
+		t.Fatalf("later interceptor saw stale model %s", recorder.seen)
+	}
+}
+
+func TestBeforeRequestReject(t *testing.T) {
+	setupHook(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(HookResult{Action: HookActionReject, StatusCode: http.StatusTooManyRequests, Message: "quota"})
+	})
+	err := BeforeRequest(context.Background(), &Info{}, newTestRequest())
+	var rejectErr *RejectError
+	if !errors.As(err, &rejectErr) || rejectErr.StatusCode != http.StatusTooManyRequests || rejectErr.Message != "quota" {
+		t.Fatalf("expected 429 reject, got %v", err)
+	}
+}
+
+func TestHookFailOpen(t *testing.T) {
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"non-2xx", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"action":"reject"}`, http.StatusInternalServerError)
+		}},
+		{"invalid json", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("not json"))
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setupHook(t, tc.handler)
+			req := newTestRequest()
+			if err := BeforeRequest(context.Background(), &Info{}, req); err != nil {
+				t.Fatalf("expected fail open, got %v", err)
+			}
+			if req.Model != "gpt-4o" {
+				t.Fatalf("request modified on hook failure: %s", req.Model)
+			}
+		})
+	}
+}
+
+func TestHookTimeoutFailOpen(t *testing.T) {
+	release := make(chan struct{})
+	setupHook(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		json.NewEncoder(w).Encode(HookResult{Action: HookActionReject})
+	})
+	// 先于 server.Close 放行阻塞的 handler
+	t.Cleanup(func() { close(release) })
+
+	start := time.Now()
+	if err := BeforeRequest(context.Background(), &Info{}, newTestRequest()); err != nil {
+		t.Fatalf("expected fail open, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("hook timeout not applied, took %s", elapsed)
+	}
+}
+
+func TestAfterResponseKeepsUsage(t *testing.T) {
+	setupHook(t, func(w http.ResponseWriter, r *http.Request) {
+		response, _ := json.Marshal(model.InternalLLMResponse{ID: "rewritten", Usage: &model.Usage{PromptTokens: 1}})
+		json.NewEncoder(w).Encode(HookResult{Action: HookActionModify, Response: response})
+	})
+	resp := &model.InternalLLMResponse{ID: "origin", Usage: &model.Usage{PromptTokens: 10, CompletionTokens: 5}}
+	if err := AfterResponse(context.Background(), &Info{}, resp); err != nil {
+		t.Fatalf("AfterResponse: %v", err)
+	}
+	if resp.ID != "rewritten" {
+		t.Fatalf("expected rewritten response, got %s", resp.ID)
+	}
+	if resp.Usage.PromptTokens != 10 || resp.Usage.CompletionTokens != 5 {
+		t.Fatalf("usage changed by hook: %+v", resp.Usage)
+	}
+}
@@ -0,0 +1,99 @@
+package interceptor
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/bestruirui/octopus/internal/transformer/model"
+)
+
+// Info 描述当前请求的上下文信息，渠道相关字段在选择渠道后才有值
+type Info struct {
+	APIKeyID     int    `json:"api_key_id"`
+	RequestModel string `json:"request_model"`
+	ChannelID    int    `json:"channel_id,omitempty"`
+	ChannelName  string `json:"channel_name,omitempty"`
+}
+
+// Interceptor 在转发的各个阶段介入请求和响应，返回 *RejectError 时以对应状态码拒绝请求
+type Interceptor interface {
+	// BeforeRequest 在选择渠道前调用，可修改请求，如注入系统提示词、改写模型、添加 metadata
+	BeforeRequest(ctx context.Context, info *Info, req *model.InternalLLMRequest) error
+	// BeforeUpstream 在发送上游请求前调用，可修改出站 HTTP 请求
+	BeforeUpstream(ctx context.Context, info *Info, req *http.Request) error
+	// OnStreamChunk 对每个流式块调用，可原地修改；返回错误时终止本次流式响应
+	OnStreamChunk(ctx context.Context, info *Info, chunk *model.InternalLLMResponse) error
+	// AfterResponse 非流式响应在写回客户端前调用，可修改响应；流式响应在结束后以聚合结果调用，修改不会影响已发送的内容
+	AfterResponse(ctx context.Context, info *Info, resp *model.InternalLLMResponse) error
+}
+
+// RejectError 拦截器拒绝请求时返回的错误
+type RejectError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *RejectError) Error() string {
+	return e.Message
+}
+
+var (
+	interceptors []Interceptor
+	lock         sync.RWMutex
+)
+
+// Register 注册拦截器，按注册顺序依次调用
+func Register(i Interceptor) {
+	lock.Lock()
+	defer lock.Unlock()
+	interceptors = append(interceptors, i)
+}
+
+func list() []Interceptor {
+	lock.RLock()
+	defer lock.RUnlock()
+	return interceptors
+}
+
+// BeforeRequest 依次调用所有拦截器的 BeforeRequest，遇到错误立即返回
+// 每个拦截器执行后同步 info.RequestModel，后续拦截器看到的是改写后的模型
+func BeforeRequest(ctx context.Context, info *Info, req *model.InternalLLMRequest) error {
+	for _, i := range list() {
+		if err := i.BeforeRequest(ctx, info, req); err != nil {
+			return err
+		}
+		info.RequestModel = req.Model
+	}
+	return nil
+}
+
+// BeforeUpstream 依次调用所有拦截器的 BeforeUpstream，遇到错误立即返回
+func BeforeUpstream(ctx context.Context, info *Info, req *http.Request) error {
+	for _, i := range list() {
+		if err := i.BeforeUpstream(ctx, info, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnStreamChunk 依次调用所有拦截器的 OnStreamChunk，遇到错误立即返回
+func OnStreamChunk(ctx context.Context, info *Info, chunk *model.InternalLLMResponse) error {
+	for _, i := range list() {
+		if err := i.OnStreamChunk(ctx, info, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AfterResponse 依次调用所有拦截器的 AfterResponse，遇到错误立即返回
+func AfterResponse(ctx context.Context, info *Info, resp *model.InternalLLMResponse) error {
+	for _, i := range list() {
+		if err := i.AfterResponse(ctx, info, resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -24,6 +24,9 @@ type RelayMetrics struct {
 	ActualModel    string // 实际使用的模型名称
 	StartTime      time.Time
 	FirstTokenTime time.Time // 首个 Token 时间（流式场景）
+	ClientCanceled bool      // 客户端是否提前断开
+	RoutePath      []string  // 当前尝试的分组路径
+	RouteRules     []string  // 命中的条件路由规则
 
 	// 请求和响应内容
 	InternalRequest  *transformerModel.InternalLLMRequest
@@ -55,11 +58,26 @@ func (m *RelayMetrics) SetChannel(channelID int, channelName string, actualModel
 	m.ActualModel = actualModel
 }
 
+// SetRoutePath 设置当前尝试的分组路径
+func (m *RelayMetrics) SetRoutePath(path []string) {
+	m.RoutePath = path
+}
+
+// AddRouteRule 记录命中的条件路由规则
+func (m *RelayMetrics) AddRouteRule(groupName, ruleName string) {
+	m.RouteRules = append(m.RouteRules, groupName+"/"+ruleName)
+}
+
 // SetFirstTokenTime 设置首个 Token 时间
 func (m *RelayMetrics) SetFirstTokenTime(t time.Time) {
 	m.FirstTokenTime = t
 }
 
+// SetClientCanceled 标记客户端已提前断开
+func (m *RelayMetrics) SetClientCanceled() {
+	m.ClientCanceled = true
+}
+
 // SetInternalRequest 设置内部请求
 func (m *RelayMetrics) SetInternalRequest(req *transformerModel.InternalLLMRequest) {
 	m.InternalRequest = req
@@ -71,6 +89,7 @@ func (m *RelayMetrics) AddAttempt(round int, attemptNum int, success bool, err e
 		ChannelID:   m.ChannelID,
 		ChannelName: m.ChannelName,
 		ModelName:   m.ActualModel,
+		Path:        m.RoutePath,
 		Round:       round,
 		AttemptNum:  attemptNum,
 		Success:     success,
@@ -160,6 +179,8 @@ func (m *RelayMetrics) saveLog(ctx context.Context, err error, duration time.Dur
 		Attempts:         m.Attempts,
 		TotalAttempts:    len(m.Attempts),
 		SuccessfulRound:  successfulRound,
+		ClientCanceled:   m.ClientCanceled,
+		RouteRules:       m.RouteRules,
 	}
 
 	// 设置首字时间（流式场景）
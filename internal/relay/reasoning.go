@@ -0,0 +1,79 @@
+package relay
+
+import (
+	"unicode/utf8"
+
+	dbmodel "github.com/bestruirui/octopus/internal/model"
+	"github.com/bestruirui/octopus/internal/transformer/model"
+)
+
+// reasoningSummaryLength summarize 模式下保留的推理内容字符数
+const reasoningSummaryLength = 300
+
+// resolveReasoningVisibility API Key 的设置优先于分组的设置，均未设置时原样返回
+func resolveReasoningVisibility(apiKey, group dbmodel.ReasoningVisibility) dbmodel.ReasoningVisibility {
+	if apiKey != "" {
+		return apiKey
+	}
+	if group != "" {
+		return group
+	}
+	return dbmodel.ReasoningVisibilityKeep
+}
+
+// reasoningFilter 按配置处理返回给客户端的推理内容
+type reasoningFilter struct {
+	visibility dbmodel.ReasoningVisibility
+	// emitted 流式响应中各 choice 已输出的推理内容字符数
+	emitted map[int]int
+}
+
+func newReasoningFilter(visibility dbmodel.ReasoningVisibility) *reasoningFilter {
+	return &reasoningFilter{visibility: visibility, emitted: make(map[int]int)}
+}
+
+// apply 处理响应中的推理内容，流式响应按 choice 累计已输出的长度
+func (f *reasoningFilter) apply(resp *model.InternalLLMResponse) {
+	if f == nil || resp == nil || (f.visibility != dbmodel.ReasoningVisibilityStrip && f.visibility != dbmodel.ReasoningVisibilitySummarize) {
+		return
+	}
+	for i := range resp.Choices {
+		choice := &resp.Choices[i]
+		if choice.Message != nil {
+			f.filterMessage(choice.Message, 0)
+		}
+		if choice.Delta != nil {
+			f.emitted[choice.Index] = f.filterMessage(choice.Delta, f.emitted[choice.Index])
+		}
+	}
+}
+
+// filterMessage 处理单条消息，emitted 为此前已输出的字符数，返回处理后累计输出的字符数
+func (f *reasoningFilter) filterMessage(message *model.Message, emitted int) int {
+	reasoning := message.GetReasoningContent()
+	if reasoning == "" {
+		return emitted
+	}
+	message.Reasoning = nil
+	// 修改后的推理内容无法通过上游的签名校验
+	message.ReasoningSignature = nil
+
+	switch f.visibility {
+	case dbmodel.ReasoningVisibilityStrip:
+		message.ReasoningContent = nil
+		return emitted
+	case dbmodel.ReasoningVisibilitySummarize:
+		remaining := reasoningSummaryLength - emitted
+		length := utf8.RuneCountInString(reasoning)
+		switch {
+		case remaining <= 0:
+			message.ReasoningContent = nil
+		case length > remaining:
+			message.SetReasoningContent(string([]rune(reasoning)[:remaining]) + "…")
+		default:
+			message.SetReasoningContent(reasoning)
+		}
+		return emitted + length
+	}
+	return emitted
+}
@@ -2,16 +2,20 @@ package relay
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"net/http"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bestruirui/octopus/internal/helper"
+	dbmodel "github.com/bestruirui/octopus/internal/model"
 	"github.com/bestruirui/octopus/internal/op"
-	"github.com/bestruirui/octopus/internal/relay/balancer"
+	"github.com/bestruirui/octopus/internal/relay/interceptor"
 	"github.com/bestruirui/octopus/internal/server/resp"
 	"github.com/bestruirui/octopus/internal/transformer/inbound"
 	"github.com/bestruirui/octopus/internal/transformer/model"
@@ -21,6 +25,9 @@ import (
 	"github.com/tmaxmax/go-sse"
 )
 
+// maxRounds 所有分组项都失败后重新选择的最大轮数
+const maxRounds = 3
+
 // Handler 处理入站请求并转发到上游服务
 func Handler(inboundType inbound.InboundType, c *gin.Context) {
 	// 解析请求
@@ -37,8 +44,27 @@ func Handler(inboundType inbound.InboundType, c *gin.Context) {
 		}
 	}
 
-	// 初始化统计和日志
+	// 调用拦截器，可修改或拒绝请求
 	apiKeyID := c.GetInt("api_key_id")
+	hookInfo := &interceptor.Info{APIKeyID: apiKeyID, RequestModel: internalRequest.Model}
+	if err := interceptor.BeforeRequest(c.Request.Context(), hookInfo, internalRequest); err != nil {
+		var rejectErr *interceptor.RejectError
+		if errors.As(err, &rejectErr) {
+			resp.Error(c, rejectErr.StatusCode, rejectErr.Message)
+			return
+		}
+		log.Warnf("interceptor failed: %v", err)
+		resp.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// 限制 n，上游不支持 n 时每个 choice 都是一次独立的上游请求
+	if maxChoices, _ := op.SettingGetInt(dbmodel.SettingKeyRelayMaxChoices); maxChoices > 0 && internalRequest.N != nil && *internalRequest.N > int64(maxChoices) {
+		resp.Error(c, http.StatusBadRequest, fmt.Sprintf("n must not exceed %d", maxChoices))
+		return
+	}
+
+	// 初始化统计和日志
 	metrics := NewRelayMetrics(internalRequest.Model)
 	metrics.SetInternalRequest(internalRequest)
 	metrics.SetAPIKeyID(apiKeyID)
@@ -49,112 +75,106 @@ func Handler(inboundType inbound.InboundType, c *gin.Context) {
 		return
 	}
 
-	const maxRounds = 3
-	var lastErr error
-	itemCount := len(group.Items)
-	b := balancer.GetBalancer(group.Mode)
-	for round := 0; round < maxRounds; round++ {
-		item := b.Select(group.Items)
-		if item == nil {
-			resp.Error(c, http.StatusServiceUnavailable, "no available channel")
+	// 客户端断开后继续读取上游的时间，用于获取上游最终的 usage
+	cancelDrainTimeOutSec, err := op.SettingGetInt(dbmodel.SettingKeyRelayCancelDrainTimeout)
+	if err != nil {
+		cancelDrainTimeOutSec = 0
+	}
+	// 流式请求等待首个 Token 时的心跳
+	heartbeatIntervalSec, err := op.SettingGetInt(dbmodel.SettingKeyRelaySSEHeartbeatInterval)
+	if err != nil {
+		heartbeatIntervalSec = 0
+	}
+	heartbeatMode, _ := op.SettingGetString(dbmodel.SettingKeyRelaySSEHeartbeatMode)
+	// 推理内容的处理方式
+	apiKey, _ := op.APIKeyGet(apiKeyID, c.Request.Context())
+	reasoningVisibility := resolveReasoningVisibility(apiKey.ReasoningVisibility, group.ReasoningVisibility)
+	// 结构化输出的校验方式
+	structuredOutputMode, _ := op.SettingGetString(dbmodel.SettingKeyRelayStructuredOutput)
+	structuredOutput := newStructuredOutput(internalRequest, structuredOutputMode)
+
+	router := &groupRouter{
+		c:                     c,
+		inAdapter:             inAdapter,
+		internalRequest:       internalRequest,
+		metrics:               metrics,
+		hookInfo:              hookInfo,
+		cancelDrainTimeOutSec: cancelDrainTimeOutSec,
+		heartbeatIntervalSec:  heartbeatIntervalSec,
+		heartbeatProtocol:     heartbeatMode == "protocol",
+		reasoningVisibility:   reasoningVisibility,
+		structuredOutput:      structuredOutput,
+		outputTokens:          requestedOutputTokens(internalRequest),
+		promptTokens:          -1,
+	}
+
+	// 展开子分组后按模型能力和上下文窗口筛选渠道分组项
+	if items := leafItems(c.Request.Context(), group, map[int]bool{}); internalRequest.IsChatRequest() && len(items) > 0 {
+		// 没有模型支持请求所需的能力时直接返回错误
+		required := requestCapabilities(internalRequest)
+		items = filterCapabilityItems(c.Request.Context(), items, required)
+		if len(items) == 0 {
+			resp.Error(c, http.StatusBadRequest, fmt.Sprintf("no model in the group supports the capabilities required by the request: %s", required))
 			return
 		}
 
-		for i := 0; i < itemCount; i++ {
-			select {
-			case <-c.Request.Context().Done():
-				log.Infof("request context canceled, stopping retry")
+		// 都放不下时按设置截断请求或直接返回错误
+		promptTokens := router.inputTokens()
+		if fitItems := filterContextItems(items, promptTokens, router.outputTokens); len(fitItems) > 0 {
+			items = fitItems
+		} else {
+			truncation, _ := op.SettingGetString(dbmodel.SettingKeyRelayContextTruncation)
+			if truncation != "middle_out" {
+				resp.Error(c, http.StatusBadRequest, fmt.Sprintf("request (about %d tokens) exceeds the context window of every model in the group", promptTokens))
 				return
-			default:
-			}
-
-			attemptStart := time.Now()
-			channel, err := op.ChannelGet(item.ChannelID, c.Request.Context())
-			if err != nil {
-				log.Warnf("failed to get channel: %v", err)
-				lastErr = err
-				item = b.Next(group.Items, item)
-				continue
-			}
-			if channel.Enabled == false {
-				log.Warnf("channel %s is disabled", channel.Name)
-				lastErr = fmt.Errorf("channel %s is disabled", channel.Name)
-				item = b.Next(group.Items, item)
-				continue
-			}
-
-			log.Infof("request model %s, mode: %d, forwarding to channel: %s model: %s (round %d/%d, item %d/%d)", internalRequest.Model, group.Mode, channel.Name, item.ModelName, round+1, maxRounds, i+1, itemCount)
-
-			internalRequest.Model = item.ModelName
-			metrics.SetChannel(channel.ID, channel.Name, item.ModelName)
-
-			outAdapter := outbound.Get(channel.Type)
-			if outAdapter == nil {
-				log.Warnf("unsupported channel type: %d for channel: %s", channel.Type, channel.Name)
-				lastErr = fmt.Errorf("unsupported channel type: %d", channel.Type)
-				item = b.Next(group.Items, item)
-				continue
-			}
-
-			// 验证 channel 类型与请求类型匹配
-			if internalRequest.IsEmbeddingRequest() && !outbound.IsEmbeddingChannelType(channel.Type) {
-				log.Warnf("channel type %d is not compatible with embedding request for channel: %s", channel.Type, channel.Name)
-				lastErr = fmt.Errorf("channel type %d not compatible with embedding request", channel.Type)
-				item = b.Next(group.Items, item)
-				continue
-			}
-
-			if internalRequest.IsChatRequest() && !outbound.IsChatChannelType(channel.Type) {
-				log.Warnf("channel type %d is not compatible with chat request for channel: %s", channel.Type, channel.Name)
-				lastErr = fmt.Errorf("channel type %d not compatible with chat request", channel.Type)
-				item = b.Next(group.Items, item)
-				continue
-			}
-
-			rc := &relayContext{
-				c:                    c,
-				inAdapter:            inAdapter,
-				outAdapter:           outAdapter,
-				internalRequest:      internalRequest,
-				channel:              channel,
-				metrics:              metrics,
-				usedKey:              channel.GetChannelKey(),
-				firstTokenTimeOutSec: group.FirstTokenTimeOut,
 			}
+			router.truncateContext = true
+		}
+		router.eligible = make(map[int]bool, len(items))
+		for _, item := range items {
+			router.eligible[item.ID] = true
+		}
+	}
 
-			if statusCode, err := rc.forward(); err == nil {
-				// 成功
-				attemptDuration := time.Since(attemptStart)
-				metrics.AddAttempt(round+1, i+1, true, nil, attemptDuration)
-				rc.collectResponse()
-				rc.usedKey.StatusCode = statusCode
-				rc.usedKey.LastUseTimeStamp = time.Now().Unix()
-				rc.usedKey.TotalCost += metrics.Stats.InputCost + metrics.Stats.OutputCost
-				op.ChannelKeyUpdate(rc.usedKey)
-				metrics.Save(c.Request.Context(), true, nil, round+1)
-				return
-			} else {
-				// 失败
-				attemptDuration := time.Since(attemptStart)
-				metrics.AddAttempt(round+1, i+1, false, err, attemptDuration)
-				rc.usedKey.StatusCode = statusCode
-				rc.usedKey.LastUseTimeStamp = time.Now().Unix()
-				op.ChannelKeyUpdate(rc.usedKey)
-				if c.Writer.Written() {
-					// Streaming responses may have already started; retrying would corrupt the client stream.
-					rc.collectResponse()
-					metrics.Save(c.Request.Context(), false, err, 0)
-					return
-				}
-				lastErr = fmt.Errorf("channel %s failed: %v", channel.Name, err)
-			}
-			item = b.Next(group.Items, item)
+	for round := 0; round < maxRounds; round++ {
+		if len(router.items(group, map[int]bool{})) == 0 {
+			writeError(c, inAdapter, http.StatusServiceUnavailable, "no available channel")
+			return
+		}
+		router.round, router.attempt = round, 0
+		if router.route(group, []string{group.Name}, map[int]bool{}) {
+			return
 		}
 	}
 
 	// 所有通道都失败
-	metrics.Save(c.Request.Context(), false, lastErr, 0)
-	resp.Error(c, http.StatusBadGateway, "all channels failed")
+	metrics.Save(c.Request.Context(), false, router.lastErr, 0)
+	writeError(c, inAdapter, http.StatusBadGateway, "all channels failed")
+}
+
+// sseStarted 判断是否已向客户端发送 SSE 响应头，如心跳或部分流式输出之后
+func sseStarted(c *gin.Context) bool {
+	return c.Writer.Written() && strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "text/event-stream")
+}
+
+// writeError 返回错误，已发送 SSE 响应头时状态码无法再修改，按入站协议写入一条错误事件后结束响应
+func writeError(c *gin.Context, inAdapter model.Inbound, statusCode int, message string) {
+	if !c.Writer.Written() {
+		resp.Error(c, statusCode, message)
+		return
+	}
+	// 已写入非流式响应时无法再返回错误
+	if !sseStarted(c) {
+		c.Abort()
+		return
+	}
+	event := []byte(": error: " + message + "\n\n")
+	if streamError, ok := inAdapter.(model.StreamError); ok {
+		event = streamError.StreamError(statusCode, message)
+	}
+	c.Writer.Write(event)
+	c.Writer.Flush()
+	c.Abort()
 }
 
 // parseRequest 解析并验证入站请求
@@ -184,57 +204,205 @@ func parseRequest(inboundType inbound.InboundType, c *gin.Context) (*model.Inter
 }
 
 // forward 转发请求到上游服务
-func (rc *relayContext) forward() (int, error) {
+func (rc *relayContext) forward() (statusCode int, err error) {
 	ctx := rc.c.Request.Context()
+	upstreamCtx, cancel := rc.upstreamContext(ctx)
+	defer cancel(nil)
+	rc.cancelUpstream = cancel
+
+	// 单次请求总时长上限
+	if rc.channel.TotalTimeOut > 0 {
+		totalTimer := time.AfterFunc(time.Duration(rc.channel.TotalTimeOut)*time.Second, func() {
+			cancel(fmt.Errorf("%w: total time out (%ds)", errUpstreamTimeout, rc.channel.TotalTimeOut))
+		})
+		defer totalTimer.Stop()
+	}
+	// 因超时取消上游请求时，以具体的超时原因作为错误返回
+	defer func() {
+		if err == nil {
+			return
+		}
+		if cause := context.Cause(upstreamCtx); errors.Is(cause, errUpstreamTimeout) {
+			log.Warnf("channel %s: %v", rc.channel.Name, cause)
+			err = cause
+		}
+	}()
+
+	if err := rc.wrapOutAdapter(); err != nil {
+		return 0, err
+	}
+
+	// 根据渠道的流式模式决定上游是否使用流式
+	clientStream := rc.internalRequest.Stream != nil && *rc.internalRequest.Stream
+	upstreamStream := clientStream
+	switch rc.channel.StreamMode {
+	case dbmodel.ChannelStreamModeForceStream:
+		upstreamStream = true
+	case dbmodel.ChannelStreamModeForceNonStream:
+		upstreamStream = false
+	}
+
+	outboundRequest, err := rc.buildUpstreamRequest(upstreamCtx, upstreamStream)
+	if err != nil {
+		return 0, err
+	}
+
+	// 客户端流式但需要等待完整的上游响应时，在等待期间发送心跳
+	if clientStream && (!upstreamStream || rc.structuredOutput != nil) && rc.stopHeartbeat == nil {
+		rc.stopHeartbeat = rc.startHeartbeat(ctx)
+		defer rc.stopBufferedHeartbeat()
+	}
+
+	response, err := rc.sendUpstream(upstreamCtx, outboundRequest, true)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	// 处理响应
+	switch {
+	case rc.structuredOutput != nil:
+		err = rc.handleStructuredOutput(ctx, upstreamCtx, response, clientStream, upstreamStream)
+	case clientStream && upstreamStream:
+		err = rc.handleStreamResponse(ctx, response)
+	case clientStream && !upstreamStream:
+		err = rc.handleResponseAsStream(ctx, response)
+	case !clientStream && upstreamStream:
+		err = rc.handleStreamAsResponse(ctx, response)
+	default:
+		err = rc.handleResponse(ctx, response)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return response.StatusCode, nil
+}
+
+// wrapOutAdapter 按请求需要将出站适配器包装为拆分多个上游请求的适配器
+func (rc *relayContext) wrapOutAdapter() error {
+	// 上游不支持 n 时拆分为多个并发请求
+	if n := rc.internalRequest.N; n != nil && *n > 1 && !outbound.IsMultipleChoicesChannelType(rc.channel.Type, rc.channel.Options) {
+		if _, ok := rc.outAdapter.(*fanOutOutbound); !ok {
+			fanOut, err := newFanOutOutbound(rc.channel, rc.outAdapter, int(*n))
+			if err != nil {
+				return err
+			}
+			rc.outAdapter = fanOut
+		}
+	}
+
+	// embedding 输入超过上游单次请求的上限时拆分为多个请求
+	if size := outbound.EmbeddingBatchSize(rc.channel.Type); size > 0 && rc.internalRequest.IsEmbeddingRequest() && len(rc.internalRequest.EmbeddingInput.Multiple) > size {
+		if _, ok := rc.outAdapter.(*embeddingBatchOutbound); !ok {
+			batches := (len(rc.internalRequest.EmbeddingInput.Multiple) + size - 1) / size
+			batch, err := newEmbeddingBatchOutbound(rc.channel, rc.outAdapter, batches)
+			if err != nil {
+				return err
+			}
+			rc.outAdapter = batch
+		}
+	}
+	return nil
+}
+
+// buildUpstreamRequest 使用出站适配器构建上游请求，upstreamStream 决定上游是否使用流式
+func (rc *relayContext) buildUpstreamRequest(upstreamCtx context.Context, upstreamStream bool) (*http.Request, error) {
+	clientStreamField := rc.internalRequest.Stream
+	rc.internalRequest.Stream = &upstreamStream
+	defer func() { rc.internalRequest.Stream = clientStreamField }()
+
+	// 模型不支持函数调用时通过提示词模拟
+	request := rc.internalRequest
+	rc.toolCallParser = nil
+	if request.IsChatRequest() && rc.channel.Options.EmulatesTools(request.Model) {
+		request = request.EmulateTools()
+		rc.toolCallParser = &model.ToolCallParser{}
+	}
 
-	// 构建出站请求
 	outboundRequest, err := rc.outAdapter.TransformRequest(
-		ctx,
-		rc.internalRequest,
+		upstreamCtx,
+		request,
 		rc.channel.GetBaseUrl(),
 		rc.usedKey.ChannelKey,
 	)
 	if err != nil {
 		log.Warnf("failed to create request: %v", err)
-		return 0, fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	return outboundRequest, nil
+}
 
+// sendUpstream 复制请求头后发送上游请求，上游返回非 2xx 状态时返回错误
+// runInterceptors 为 false 时不调用 BeforeUpstream 拦截器，用于同一次转发中的后续请求
+func (rc *relayContext) sendUpstream(upstreamCtx context.Context, outboundRequest *http.Request, runInterceptors bool) (*http.Response, error) {
 	// 复制请求头
 	rc.copyHeaders(outboundRequest)
+	if runInterceptors {
+		if err := interceptor.BeforeUpstream(upstreamCtx, rc.hookInfo, outboundRequest); err != nil {
+			return nil, fmt.Errorf("interceptor rejected upstream request: %w", err)
+		}
+	}
 
 	// 发送请求
 	response, err := rc.sendRequest(outboundRequest)
 	if err != nil {
-		return 0, fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
-	defer response.Body.Close()
 
 	// 检查响应状态
 	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		defer response.Body.Close()
 		body, err := io.ReadAll(response.Body)
 		if err != nil {
-			return 0, fmt.Errorf("failed to read response body: %w", err)
+			return nil, fmt.Errorf("failed to read response body: %w", err)
 		}
-		return 0, fmt.Errorf("upstream error: %d: %s", response.StatusCode, string(body))
+		return nil, fmt.Errorf("upstream error: %d: %s", response.StatusCode, string(body))
 	}
+	return response, nil
+}
 
-	// 处理响应
-	if rc.internalRequest.Stream != nil && *rc.internalRequest.Stream {
-		if err := rc.handleStreamResponse(ctx, response); err != nil {
-			return 0, err
+// newOutboundAdapter 创建渠道类型对应的出站适配器并应用渠道选项和 HTTP 客户端
+func newOutboundAdapter(channel *dbmodel.Channel) (model.Outbound, error) {
+	outAdapter := outbound.Get(channel.Type)
+	if outAdapter == nil {
+		return nil, fmt.Errorf("unsupported channel type: %d", channel.Type)
+	}
+	if configurable, ok := outAdapter.(model.OutboundConfigurable); ok {
+		configurable.SetChannelOptions(channel.Options)
+	}
+	if clientSetter, ok := outAdapter.(model.OutboundHTTPClient); ok {
+		httpClient, err := helper.ChannelHttpClient(channel)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get http client: %w", err)
 		}
-		return response.StatusCode, nil
+		clientSetter.SetHTTPClient(httpClient)
 	}
-	if err := rc.handleResponse(ctx, response); err != nil {
-		return 0, err
+	return outAdapter, nil
+}
+
+// upstreamContext 创建上游请求使用的 context
+// 未开启断开后读取时随客户端一起取消；开启后在客户端断开 cancelDrainTimeOutSec 秒后才取消上游请求
+func (rc *relayContext) upstreamContext(clientCtx context.Context) (context.Context, context.CancelCauseFunc) {
+	if rc.cancelDrainTimeOutSec <= 0 {
+		return context.WithCancelCause(clientCtx)
+	}
+	upstreamCtx, cancel := context.WithCancelCause(context.WithoutCancel(clientCtx))
+	drainTimeout := time.Duration(rc.cancelDrainTimeOutSec) * time.Second
+	stop := context.AfterFunc(clientCtx, func() {
+		time.AfterFunc(drainTimeout, func() { cancel(context.Canceled) })
+	})
+	return upstreamCtx, func(cause error) {
+		stop()
+		cancel(cause)
 	}
-	return response.StatusCode, nil
 }
 
-// copyHeaders 复制请求头，过滤 hop-by-hop 头
+// copyHeaders 复制请求头，过滤 hop-by-hop 头和 AWS 签名相关的 x-amz-* 头
+// 出站请求在 TransformRequest 中已完成签名，客户端的同名头会覆盖签名值
 func (rc *relayContext) copyHeaders(outboundRequest *http.Request) {
 	for key, values := range rc.c.Request.Header {
-		if hopByHopHeaders[strings.ToLower(key)] {
+		lowerKey := strings.ToLower(key)
+		if hopByHopHeaders[lowerKey] || strings.HasPrefix(lowerKey, "x-amz-") {
 			continue
 		}
 		for _, value := range values {
@@ -256,7 +424,14 @@ func (rc *relayContext) sendRequest(req *http.Request) (*http.Response, error) {
 		return nil, err
 	}
 
-	response, err := httpClient.Do(req)
+	req, stopTrace := rc.traceTimeouts(req)
+	var response *http.Response
+	if transport, ok := rc.outAdapter.(model.OutboundTransport); ok {
+		response, err = transport.RoundTrip(req)
+	} else {
+		response, err = httpClient.Do(req)
+	}
+	stopTrace()
 	if err != nil {
 		log.Warnf("failed to send request: %v", err)
 		return nil, err
@@ -267,37 +442,36 @@ func (rc *relayContext) sendRequest(req *http.Request) (*http.Response, error) {
 
 // handleStreamResponse 处理流式响应
 func (rc *relayContext) handleStreamResponse(ctx context.Context, response *http.Response) error {
-	// 流式响应应当是 SSE
+	// 流式响应应当是 SSE，自定义流式解码的出站除外
 	// 某些上游可能会返回非SSE的JSON响应 (由于 Accept headers 配置错误)
-	if ct := response.Header.Get("Content-Type"); ct != "" && !strings.Contains(strings.ToLower(ct), "text/event-stream") {
+	if ct := response.Header.Get("Content-Type"); !rc.customStreamDecoder() && ct != "" && !strings.Contains(strings.ToLower(ct), "text/event-stream") {
 		body, _ := io.ReadAll(io.LimitReader(response.Body, 16*1024))
 		return fmt.Errorf("upstream returned non-SSE content-type %q for stream request: %s", ct, string(body))
 	}
 
-	// 设置 SSE 响应头
-	rc.c.Header("Content-Type", "text/event-stream")
-	rc.c.Header("Cache-Control", "no-cache")
-	rc.c.Header("Connection", "keep-alive")
-	rc.c.Header("X-Accel-Buffering", "no")
+	rc.setSSEHeaders()
+	rc.streamed = true
 
 	firstToken := true
+	// clientGone 表示客户端已断开，此后仅继续读取上游以统计用量，不再写入
+	clientGone := false
+	clientDone := ctx.Done()
 
 	// Streaming "time to first token" timeout: only applies before we write anything to the client.
 	// We read SSE events in a goroutine so we can race the first meaningful output against a timer.
 	type sseReadResult struct {
-		data string
-		err  error
+		event streamEvent
+		err   error
 	}
 	results := make(chan sseReadResult, 1)
 	go func() {
 		defer close(results)
-		readCfg := &sse.ReadConfig{MaxEventSize: maxSSEEventSize}
-		for ev, err := range sse.Read(response.Body, readCfg) {
+		for event, err := range rc.readStream(response.Body) {
 			if err != nil {
 				results <- sseReadResult{err: err}
 				return
 			}
-			results <- sseReadResult{data: ev.Data}
+			results <- sseReadResult{event: event}
 		}
 	}()
 
@@ -313,30 +487,87 @@ func (rc *relayContext) handleStreamResponse(ctx context.Context, response *http
 		}()
 	}
 
+	// 等待首个 Token 期间定时发送心跳，避免前置代理因空闲断开连接
+	var heartbeatTicker *time.Ticker
+	var heartbeatC <-chan time.Time
+	if rc.heartbeatIntervalSec > 0 {
+		heartbeatTicker = time.NewTicker(time.Duration(rc.heartbeatIntervalSec) * time.Second)
+		heartbeatC = heartbeatTicker.C
+		defer heartbeatTicker.Stop()
+	}
+
+	// 流式相邻数据块的最大间隔，每收到一个上游事件重置
+	var idleTimer *time.Timer
+	var idleC <-chan time.Time
+	idleTimeout := time.Duration(rc.channel.StreamIdleTimeOut) * time.Second
+	if idleTimeout > 0 {
+		idleTimer = time.NewTimer(idleTimeout)
+		idleC = idleTimer.C
+		defer idleTimer.Stop()
+	}
+
 	for {
 		// 检查客户端是否断开
 		select {
-		case <-ctx.Done():
-			log.Infof("client disconnected, stopping stream")
-			return nil
+		case <-clientDone:
+			rc.metrics.SetClientCanceled()
+			if rc.cancelDrainTimeOutSec <= 0 {
+				log.Infof("client disconnected, stopping stream")
+				return nil
+			}
+			log.Infof("client disconnected, draining upstream stream for usage (%ds)", rc.cancelDrainTimeOutSec)
+			clientGone = true
+			clientDone = nil
+			firstTokenC = nil
 		case <-firstTokenC:
 			// Abort upstream stream before any client writes; caller will retry next channel.
 			log.Warnf("first token timeout (%ds), switching channel", rc.firstTokenTimeOutSec)
 			_ = response.Body.Close()
 			return fmt.Errorf("first token timeout (%ds)", rc.firstTokenTimeOutSec)
+		case <-heartbeatC:
+			if !firstToken || clientGone {
+				heartbeatTicker.Stop()
+				heartbeatC = nil
+				continue
+			}
+			rc.c.Writer.Write(rc.heartbeatEvent())
+			rc.c.Writer.Flush()
+		case <-idleC:
+			_ = response.Body.Close()
+			if clientGone {
+				log.Infof("stop draining upstream stream: idle time out")
+				return nil
+			}
+			log.Warnf("stream idle timeout (%ds)", rc.channel.StreamIdleTimeOut)
+			return fmt.Errorf("%w: stream idle time out (%ds)", errUpstreamTimeout, rc.channel.StreamIdleTimeOut)
 		case r, ok := <-results:
+			if idleTimer != nil {
+				idleTimer.Reset(idleTimeout)
+			}
 			if !ok {
 				log.Infof("stream end")
 				return nil
 			}
 			if r.err != nil {
+				if clientGone {
+					log.Infof("stop draining upstream stream: %v", r.err)
+					return nil
+				}
 				log.Warnf("failed to read event: %v", r.err)
 				return fmt.Errorf("failed to read stream event: %w", r.err)
 			}
 
 			// 转换流式数据
-			data, err := rc.transformStreamData(ctx, r.data)
-			if err != nil || len(data) == 0 {
+			data, err := rc.transformStreamData(ctx, r.event)
+			if err != nil {
+				var rejectErr *interceptor.RejectError
+				if errors.As(err, &rejectErr) {
+					_ = response.Body.Close()
+					return err
+				}
+				continue
+			}
+			if len(data) == 0 {
 				continue
 			}
 			// 记录首个 Token 时间
@@ -356,16 +587,132 @@ func (rc *relayContext) handleStreamResponse(ctx context.Context, response *http
 				}
 			}
 
+			if clientGone {
+				continue
+			}
 			rc.c.Writer.Write(data)
 			rc.c.Writer.Flush()
+			rc.outputWritten = true
 		}
 	}
 }
 
+// customStreamDecoder 判断出站是否自行解码非 SSE 的流式响应
+func (rc *relayContext) customStreamDecoder() bool {
+	_, ok := rc.outAdapter.(model.StreamDecoder)
+	return ok
+}
+
+// streamEvent 上游流式响应中的一条事件
+// 拆分请求的出站直接产生内部格式的数据块，此时 chunk 不为空，不再经过出站的 TransformStream
+type streamEvent struct {
+	data  string
+	chunk *model.InternalLLMResponse
+}
+
+// chunkDecoder 由中转内部的出站实现，流式响应直接解码为内部格式的数据块
+type chunkDecoder interface {
+	decodeChunks(body io.Reader) iter.Seq2[*model.InternalLLMResponse, error]
+}
+
+// readStream 逐条读取上游流式响应的事件
+// 默认按 SSE 解析，出站实现 StreamDecoder 时使用其解码，实现 chunkDecoder 时直接返回数据块
+func (rc *relayContext) readStream(body io.Reader) iter.Seq2[streamEvent, error] {
+	return readStream(rc.outAdapter, body)
+}
+
+func readStream(outAdapter model.Outbound, body io.Reader) iter.Seq2[streamEvent, error] {
+	return func(yield func(streamEvent, error) bool) {
+		if decoder, ok := outAdapter.(chunkDecoder); ok {
+			for chunk, err := range decoder.decodeChunks(body) {
+				if !yield(streamEvent{chunk: chunk}, err) || err != nil {
+					return
+				}
+			}
+			return
+		}
+		if decoder, ok := outAdapter.(model.StreamDecoder); ok {
+			for data, err := range decoder.DecodeStream(body) {
+				if !yield(streamEvent{data: string(data)}, err) || err != nil {
+					return
+				}
+			}
+			return
+		}
+		readCfg := &sse.ReadConfig{MaxEventSize: maxSSEEventSize}
+		for ev, err := range sse.Read(body, readCfg) {
+			if !yield(streamEvent{data: ev.Data}, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// setSSEHeaders 设置 SSE 响应头
+func (rc *relayContext) setSSEHeaders() {
+	rc.c.Header("Content-Type", "text/event-stream")
+	rc.c.Header("Cache-Control", "no-cache")
+	rc.c.Header("Connection", "keep-alive")
+	rc.c.Header("X-Accel-Buffering", "no")
+}
+
+// heartbeatEvent 返回一条 SSE 心跳
+// protocol 模式下优先使用入站协议自身的心跳事件，否则使用 SSE 注释
+func (rc *relayContext) heartbeatEvent() []byte {
+	if rc.heartbeatProtocol {
+		if hb, ok := rc.inAdapter.(model.StreamHeartbeat); ok {
+			return hb.StreamHeartbeat()
+		}
+	}
+	return []byte(": ping\n\n")
+}
+
+// startHeartbeat 在后台定时发送心跳，首个心跳同时发送 SSE 响应头，用于客户端流式、上游响应需要完整读取的场景
+// 返回的函数停止心跳并等待进行中的写入结束，之后才能向客户端写入响应
+func (rc *relayContext) startHeartbeat(ctx context.Context) func() {
+	if rc.heartbeatIntervalSec <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(time.Duration(rc.heartbeatIntervalSec) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				rc.setSSEHeaders()
+				rc.c.Writer.Write(rc.heartbeatEvent())
+				rc.c.Writer.Flush()
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-finished
+		})
+	}
+}
+
+// stopBufferedHeartbeat 停止 startHeartbeat 启动的心跳
+func (rc *relayContext) stopBufferedHeartbeat() {
+	if rc.stopHeartbeat != nil {
+		rc.stopHeartbeat()
+		rc.stopHeartbeat = nil
+	}
+}
+
 // transformStreamData 转换流式数据
-func (rc *relayContext) transformStreamData(ctx context.Context, data string) ([]byte, error) {
+func (rc *relayContext) transformStreamData(ctx context.Context, event streamEvent) ([]byte, error) {
 	// 上游格式 → 内部格式
-	internalStream, err := rc.outAdapter.TransformStream(ctx, []byte(data))
+	internalStream, err := rc.outboundStream(ctx, event)
 	if err != nil {
 		log.Warnf("failed to transform stream: %v", err)
 		return nil, err
@@ -373,6 +720,13 @@ func (rc *relayContext) transformStreamData(ctx context.Context, data string) ([
 	if internalStream == nil {
 		return nil, nil
 	}
+	if internalStream.Object != "[DONE]" {
+		if err := interceptor.OnStreamChunk(ctx, rc.hookInfo, internalStream); err != nil {
+			log.Warnf("interceptor rejected stream chunk: %v", err)
+			return nil, err
+		}
+	}
+	rc.reasoningFilter.apply(internalStream)
 
 	// 内部格式 → 入站格式
 	inStream, err := rc.inAdapter.TransformStream(ctx, internalStream)
@@ -384,15 +738,54 @@ func (rc *relayContext) transformStreamData(ctx context.Context, data string) ([
 	return inStream, nil
 }
 
+// outboundStream 将上游流式事件转为内部格式，模拟工具调用时解析其中的工具调用块
+func (rc *relayContext) outboundStream(ctx context.Context, event streamEvent) (*model.InternalLLMResponse, error) {
+	internalStream := event.chunk
+	if internalStream == nil {
+		var err error
+		internalStream, err = rc.outAdapter.TransformStream(ctx, []byte(event.data))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if rc.toolCallParser != nil {
+		rc.toolCallParser.ParseStream(internalStream)
+	}
+	return internalStream, nil
+}
+
+// outboundResponse 将上游响应转为内部格式，模拟工具调用时解析其中的工具调用块
+func (rc *relayContext) outboundResponse(ctx context.Context, response *http.Response) (*model.InternalLLMResponse, error) {
+	internalResponse, err := rc.outAdapter.TransformResponse(ctx, response)
+	if err != nil {
+		return nil, err
+	}
+	if rc.toolCallParser != nil {
+		rc.toolCallParser.ParseResponse(internalResponse)
+	}
+	return internalResponse, nil
+}
+
 // handleResponse 处理非流式响应
 func (rc *relayContext) handleResponse(ctx context.Context, response *http.Response) error {
 	// 上游格式 → 内部格式
-	internalResponse, err := rc.outAdapter.TransformResponse(ctx, response)
+	internalResponse, err := rc.outboundResponse(ctx, response)
 	if err != nil {
 		log.Warnf("failed to transform response: %v", err)
 		return fmt.Errorf("failed to transform outbound response: %w", err)
 	}
 
+	return rc.writeResponse(ctx, internalResponse)
+}
+
+// writeResponse 将内部响应转换为入站格式并写回客户端
+func (rc *relayContext) writeResponse(ctx context.Context, internalResponse *model.InternalLLMResponse) error {
+	rc.stopBufferedHeartbeat()
+	if err := interceptor.AfterResponse(ctx, rc.hookInfo, internalResponse); err != nil {
+		return err
+	}
+	rc.reasoningFilter.apply(internalResponse)
+
 	// 内部格式 → 入站格式
 	inResponse, err := rc.inAdapter.TransformResponse(ctx, internalResponse)
 	if err != nil {
@@ -400,7 +793,11 @@ func (rc *relayContext) handleResponse(ctx context.Context, response *http.Respo
 		return fmt.Errorf("failed to transform inbound response: %w", err)
 	}
 
+	if ctx.Err() != nil {
+		rc.metrics.SetClientCanceled()
+	}
 	rc.c.Data(http.StatusOK, "application/json", inResponse)
+	rc.outputWritten = true
 	return nil
 }
 
@@ -411,6 +808,18 @@ func (rc *relayContext) collectResponse() {
 		return
 	}
 
+	// 客户端提前断开时上游可能尚未返回 usage，按已收到的内容估算
+	// 部分 embedding 接口 (如 Gemini) 不返回 usage，同样按输入估算
+	if internalResponse.Usage == nil && (rc.metrics.ClientCanceled || internalResponse.IsEmbeddingResponse()) {
+		internalResponse.Usage = estimateUsage(rc.internalRequest, internalResponse)
+	}
+	// 非流式响应已在写回前调用过拦截器
+	if rc.streamed {
+		if err := interceptor.AfterResponse(rc.c.Request.Context(), rc.hookInfo, internalResponse); err != nil {
+			log.Warnf("interceptor failed after stream response: %v", err)
+		}
+	}
+
 	// 设置响应内容
 	rc.metrics.SetInternalResponse(internalResponse)
 }
@@ -0,0 +1,82 @@
+package relay
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dbmodel "github.com/bestruirui/octopus/internal/model"
+	"github.com/bestruirui/octopus/internal/relay/interceptor"
+	"github.com/bestruirui/octopus/internal/transformer/inbound"
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/bestruirui/octopus/internal/transformer/outbound"
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+// newTestRelayContext 创建转发到 baseUrl 的 relayContext，body 为 OpenAI Chat 格式的客户端请求
+func newTestRelayContext(t *testing.T, channelType outbound.OutboundType, baseUrl, body string) (*relayContext, *httptest.ResponseRecorder) {
+	t.Helper()
+	inAdapter := inbound.Get(inbound.InboundTypeOpenAIChat)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	internalRequest, err := inAdapter.TransformRequest(c.Request.Context(), []byte(body))
+	if err != nil {
+		t.Fatalf("TransformRequest: %v", err)
+	}
+	channel := &dbmodel.Channel{
+		Name:     "test",
+		Type:     channelType,
+		BaseUrls: []dbmodel.BaseUrl{{URL: baseUrl}},
+		Keys:     []dbmodel.ChannelKey{{Enabled: true, ChannelKey: "sk-test"}},
+	}
+	outAdapter, err := newOutboundAdapter(channel)
+	if err != nil {
+		t.Fatalf("newOutboundAdapter: %v", err)
+	}
+	rc := &relayContext{
+		c:               c,
+		inAdapter:       inAdapter,
+		outAdapter:      outAdapter,
+		internalRequest: internalRequest,
+		channel:         channel,
+		metrics:         NewRelayMetrics(internalRequest.Model),
+		usedKey:         channel.GetChannelKey(),
+		hookInfo:        &interceptor.Info{},
+		reasoningFilter: newReasoningFilter(""),
+	}
+	return rc, recorder
+}
+
+func TestCopyHeadersKeepsSignature(t *testing.T) {
+	request := &model.InternalLLMRequest{
+		Model:    "anthropic.claude-3-5-sonnet-20240620-v1:0",
+		Messages: []model.Message{{Role: "user", Content: model.MessageContent{Content: lo.ToPtr("hello")}}},
+	}
+	outboundRequest, err := outbound.Get(outbound.OutboundTypeBedrock).TransformRequest(context.Background(), request, "", "AKIDEXAMPLE:secret:us-east-1:session-token")
+	if err != nil {
+		t.Fatalf("TransformRequest: %v", err)
+	}
+	signed := outboundRequest.Header.Clone()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	c.Request.Header.Set("Authorization", "Bearer sk-octopus")
+	c.Request.Header.Set("X-Amz-Date", "20200101T000000Z")
+	c.Request.Header.Set("X-Amz-Security-Token", "client-token")
+	c.Request.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	c.Request.Header.Set("User-Agent", "client")
+	rc := &relayContext{c: c, channel: &dbmodel.Channel{}}
+	rc.copyHeaders(outboundRequest)
+
+	for _, key := range []string{"Authorization", "X-Amz-Date", "X-Amz-Security-Token", "X-Amz-Content-Sha256"} {
+		if got, want := outboundRequest.Header.Get(key), signed.Get(key); got != want {
+			t.Errorf("%s overwritten by client header: got %q, want %q", key, got, want)
+		}
+	}
+	if outboundRequest.Header.Get("User-Agent") != "client" {
+		t.Errorf("client header not forwarded")
+	}
+}
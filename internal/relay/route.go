@@ -0,0 +1,249 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	dbmodel "github.com/bestruirui/octopus/internal/model"
+	"github.com/bestruirui/octopus/internal/op"
+	"github.com/bestruirui/octopus/internal/relay/balancer"
+	"github.com/bestruirui/octopus/internal/relay/interceptor"
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/bestruirui/octopus/internal/transformer/outbound"
+	"github.com/bestruirui/octopus/internal/utils/log"
+	"github.com/gin-gonic/gin"
+)
+
+// groupRouter 按分组的负载均衡模式选择分组项并转发
+// 分组项引用子分组时，在子分组内按子分组自身的模式继续选择，子分组全部失败后回到上一层选择下一个分组项
+type groupRouter struct {
+	c               *gin.Context
+	inAdapter       model.Inbound
+	internalRequest *model.InternalLLMRequest
+	metrics         *RelayMetrics
+	hookInfo        *interceptor.Info
+
+	cancelDrainTimeOutSec int
+	heartbeatIntervalSec  int
+	heartbeatProtocol     bool
+	reasoningVisibility   dbmodel.ReasoningVisibility
+	structuredOutput      *structuredOutput
+	truncateContext       bool
+	outputTokens          int64
+
+	// eligible 通过能力和上下文窗口筛选的渠道分组项 ID，为 nil 时不筛选
+	eligible map[int]bool
+	// ruleResults 各分组的条件路由规则的匹配结果
+	ruleResults map[int]groupRuleResult
+	// promptTokens 估算的输入 token 数，小于 0 表示尚未估算
+	promptTokens int64
+
+	round   int
+	attempt int
+	lastErr error
+}
+
+// subGroup 获取分组项引用的子分组，visiting 为当前路径上的分组，用于检测循环引用
+func subGroup(ctx context.Context, item dbmodel.GroupItem, visiting map[int]bool) (*dbmodel.Group, error) {
+	if visiting[*item.SubGroupID] {
+		return nil, fmt.Errorf("sub group %s is referenced recursively", item.ModelName)
+	}
+	return op.GroupGet(*item.SubGroupID, ctx)
+}
+
+// leafItems 递归展开子分组，返回分组下所有指向渠道的分组项
+func leafItems(ctx context.Context, group dbmodel.Group, visiting map[int]bool) []dbmodel.GroupItem {
+	visiting[group.ID] = true
+	defer delete(visiting, group.ID)
+
+	var items []dbmodel.GroupItem
+	for _, item := range group.Items {
+		if item.SubGroupID == nil {
+			items = append(items, item)
+			continue
+		}
+		sub, err := subGroup(ctx, item, visiting)
+		if err != nil {
+			log.Warnf("group %s: %v", group.Name, err)
+			continue
+		}
+		items = append(items, leafItems(ctx, *sub, visiting)...)
+	}
+	return items
+}
+
+// items 返回分组中条件路由规则选择且可用的分组项，子分组下没有可用的渠道分组项时跳过
+func (r *groupRouter) items(group dbmodel.Group, visiting map[int]bool) []dbmodel.GroupItem {
+	candidates := r.applyRules(group).items
+	if r.eligible == nil {
+		return candidates
+	}
+	visiting[group.ID] = true
+	defer delete(visiting, group.ID)
+
+	var items []dbmodel.GroupItem
+	for _, item := range candidates {
+		if item.SubGroupID == nil {
+			if r.eligible[item.ID] {
+				items = append(items, item)
+			}
+			continue
+		}
+		if sub, err := subGroup(r.c.Request.Context(), item, visiting); err == nil && len(r.items(*sub, visiting)) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// route 按条件路由规则选择的模式在分组内依次尝试分组项，请求已结束（成功、已向客户端输出或不可重试）时返回 true
+// path 为从请求的分组到当前分组的名称，visiting 为路径上的分组 ID
+func (r *groupRouter) route(group dbmodel.Group, path []string, visiting map[int]bool) bool {
+	items := r.items(group, visiting)
+	visiting[group.ID] = true
+	defer delete(visiting, group.ID)
+
+	b := balancer.GetBalancer(r.applyRules(group).mode)
+	item := b.Select(items)
+	for i := 0; i < len(items) && item != nil; i++ {
+		if item.SubGroupID != nil {
+			sub, err := subGroup(r.c.Request.Context(), *item, visiting)
+			if err != nil {
+				log.Warnf("group %s: %v", group.Name, err)
+				r.lastErr = err
+			} else if r.route(*sub, append(slices.Clip(path), sub.Name), visiting) {
+				return true
+			}
+		} else if r.try(group, *item, path) {
+			return true
+		}
+		item = b.Next(items, item)
+	}
+	return false
+}
+
+// try 将请求转发到分组项指向的渠道，请求已结束时返回 true
+func (r *groupRouter) try(group dbmodel.Group, item dbmodel.GroupItem, path []string) bool {
+	c := r.c
+	select {
+	case <-c.Request.Context().Done():
+		log.Infof("request context canceled, stopping retry")
+		r.metrics.SetClientCanceled()
+		r.metrics.Save(c.Request.Context(), false, r.lastErr, 0)
+		return true
+	default:
+	}
+
+	r.attempt++
+	attemptStart := time.Now()
+	channel, err := op.ChannelGet(item.ChannelID, c.Request.Context())
+	if err != nil {
+		log.Warnf("failed to get channel: %v", err)
+		r.lastErr = err
+		return false
+	}
+	if channel.Enabled == false {
+		log.Warnf("channel %s is disabled", channel.Name)
+		r.lastErr = fmt.Errorf("channel %s is disabled", channel.Name)
+		return false
+	}
+
+	log.Infof("request model %s, route: %s, mode: %d, forwarding to channel: %s model: %s (round %d/%d, attempt %d)", r.metrics.RequestModel, strings.Join(path, " > "), r.applyRules(group).mode, channel.Name, item.ModelName, r.round+1, maxRounds, r.attempt)
+
+	internalRequest := r.internalRequest
+	internalRequest.Model = item.ModelName
+	r.metrics.SetChannel(channel.ID, channel.Name, item.ModelName)
+	r.metrics.SetRoutePath(path)
+	r.hookInfo.ChannelID = channel.ID
+	r.hookInfo.ChannelName = channel.Name
+
+	outAdapter, err := newOutboundAdapter(channel)
+	if err != nil {
+		log.Warnf("%v for channel: %s", err, channel.Name)
+		r.lastErr = err
+		return false
+	}
+	internalRequest.ReasoningMapping = model.LookupReasoningMapping(item.ModelName, int(channel.Type))
+
+	// 验证 channel 类型与请求类型匹配
+	if internalRequest.IsEmbeddingRequest() && !outbound.IsEmbeddingChannelType(channel.Type) {
+		log.Warnf("channel type %d is not compatible with embedding request for channel: %s", channel.Type, channel.Name)
+		r.lastErr = fmt.Errorf("channel type %d not compatible with embedding request", channel.Type)
+		return false
+	}
+
+	if internalRequest.IsChatRequest() && !outbound.IsChatChannelType(channel.Type) {
+		log.Warnf("channel type %d is not compatible with chat request for channel: %s", channel.Type, channel.Name)
+		r.lastErr = fmt.Errorf("channel type %d not compatible with chat request", channel.Type)
+		return false
+	}
+
+	attemptRequest := internalRequest
+	if r.truncateContext {
+		attemptRequest = truncateMiddleOut(internalRequest, contextBudget(item.ModelName, r.outputTokens))
+	}
+
+	rc := &relayContext{
+		c:                     c,
+		inAdapter:             r.inAdapter,
+		outAdapter:            outAdapter,
+		internalRequest:       attemptRequest,
+		channel:               channel,
+		metrics:               r.metrics,
+		usedKey:               channel.GetChannelKey(),
+		firstTokenTimeOutSec:  group.FirstTokenTimeOut,
+		cancelDrainTimeOutSec: r.cancelDrainTimeOutSec,
+		heartbeatIntervalSec:  r.heartbeatIntervalSec,
+		heartbeatProtocol:     r.heartbeatProtocol,
+		hookInfo:              r.hookInfo,
+		reasoningFilter:       newReasoningFilter(r.reasoningVisibility),
+		structuredOutput:      r.structuredOutput,
+	}
+
+	statusCode, err := rc.forward()
+	attemptDuration := time.Since(attemptStart)
+	if err == nil {
+		// 成功
+		// 先收集响应以便 usage 和费用计入本次尝试的统计
+		rc.collectResponse()
+		r.metrics.AddAttempt(r.round+1, r.attempt, true, nil, attemptDuration)
+		rc.usedKey.StatusCode = statusCode
+		rc.usedKey.LastUseTimeStamp = time.Now().Unix()
+		rc.usedKey.TotalCost += r.metrics.Stats.InputCost + r.metrics.Stats.OutputCost
+		op.ChannelKeyUpdate(rc.usedKey)
+		r.metrics.Save(c.Request.Context(), true, nil, r.round+1)
+		return true
+	}
+
+	// 失败
+	// 心跳不算作输出，仅在已向客户端输出内容后放弃重试
+	written := rc.outputWritten
+	if written {
+		// 已有输出的部分响应同样产生了费用
+		rc.collectResponse()
+	}
+	r.metrics.AddAttempt(r.round+1, r.attempt, false, err, attemptDuration)
+	rc.usedKey.StatusCode = statusCode
+	rc.usedKey.LastUseTimeStamp = time.Now().Unix()
+	op.ChannelKeyUpdate(rc.usedKey)
+	if written {
+		// Streaming responses may have already started; retrying would corrupt the client stream.
+		r.metrics.Save(c.Request.Context(), false, err, 0)
+		writeError(c, r.inAdapter, http.StatusBadGateway, "upstream stream interrupted")
+		return true
+	}
+	// 拦截器拒绝的请求不再重试其他渠道
+	var rejectErr *interceptor.RejectError
+	if errors.As(err, &rejectErr) {
+		r.metrics.Save(c.Request.Context(), false, err, 0)
+		writeError(c, r.inAdapter, rejectErr.StatusCode, rejectErr.Message)
+		return true
+	}
+	r.lastErr = fmt.Errorf("channel %s failed: %v", channel.Name, err)
+	return false
+}
@@ -0,0 +1,94 @@
+package relay
+
+import (
+	"slices"
+
+	dbmodel "github.com/bestruirui/octopus/internal/model"
+	"github.com/bestruirui/octopus/internal/utils/log"
+	"github.com/bestruirui/octopus/internal/utils/xstrings"
+	"github.com/samber/lo"
+)
+
+// groupRuleResult 分组的条件路由规则的匹配结果
+type groupRuleResult struct {
+	items []dbmodel.GroupItem
+	mode  dbmodel.GroupMode
+}
+
+// applyRules 按分组的条件路由规则选择分组项和负载均衡模式，没有命中的规则时使用分组的全部分组项和模式
+// 同一请求内每个分组只匹配一次，多轮重试和嵌套分组的可用性判断使用相同的结果
+func (r *groupRouter) applyRules(group dbmodel.Group) groupRuleResult {
+	if result, ok := r.ruleResults[group.ID]; ok {
+		return result
+	}
+	result := groupRuleResult{items: group.Items, mode: group.Mode}
+	for _, rule := range group.Rules {
+		if !r.ruleMatches(&rule) {
+			continue
+		}
+		r.metrics.AddRouteRule(group.Name, rule.Name)
+		if rule.Mode != nil {
+			result.mode = *rule.Mode
+		}
+		if len(rule.ItemIDs) > 0 {
+			items := lo.Filter(group.Items, func(item dbmodel.GroupItem, _ int) bool {
+				return slices.Contains(rule.ItemIDs, item.ID)
+			})
+			// 规则引用的分组项都已删除时使用全部分组项，避免分组不可用
+			if len(items) > 0 {
+				result.items = items
+			} else {
+				log.Warnf("group %s: rule %s references no existing items, using all items", group.Name, rule.Name)
+			}
+		}
+		break
+	}
+	if r.ruleResults == nil {
+		r.ruleResults = make(map[int]groupRuleResult)
+	}
+	r.ruleResults[group.ID] = result
+	return result
+}
+
+// ruleMatches 判断请求是否满足规则的所有条件
+func (r *groupRouter) ruleMatches(rule *dbmodel.GroupRule) bool {
+	req := r.internalRequest
+	if rule.Stream != nil && *rule.Stream != (req.Stream != nil && *req.Stream) {
+		return false
+	}
+	if rule.HasTools != nil || rule.HasImages != nil {
+		required := requestCapabilities(req)
+		if rule.HasTools != nil && *rule.HasTools != required.tools {
+			return false
+		}
+		if rule.HasImages != nil && *rule.HasImages != required.vision {
+			return false
+		}
+	}
+	if rule.MinInputTokens > 0 || rule.MaxInputTokens > 0 {
+		tokens := r.inputTokens()
+		if rule.MinInputTokens > 0 && tokens < rule.MinInputTokens {
+			return false
+		}
+		if rule.MaxInputTokens > 0 && tokens > rule.MaxInputTokens {
+			return false
+		}
+	}
+	if len(rule.APIKeyIDs) > 0 && !slices.Contains(rule.APIKeyIDs, r.hookInfo.APIKeyID) {
+		return false
+	}
+	for name, pattern := range rule.Headers {
+		if !xstrings.MatchWildcard(pattern, r.c.GetHeader(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// inputTokens 估算的输入 token 数，只计算一次
+func (r *groupRouter) inputTokens() int64 {
+	if r.promptTokens < 0 {
+		r.promptTokens = estimatePromptTokens(r.internalRequest)
+	}
+	return r.promptTokens
+}
@@ -0,0 +1,126 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	dbmodel "github.com/bestruirui/octopus/internal/model"
+	"github.com/bestruirui/octopus/internal/relay/interceptor"
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+// newTestGroupRouter 创建带有 X-Team: search 请求头、API Key 7、估算 1000 个输入 token 的 groupRouter
+func newTestGroupRouter(req *model.InternalLLMRequest) *groupRouter {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	c.Request.Header.Set("X-Team", "search")
+	return &groupRouter{
+		c:               c,
+		internalRequest: req,
+		metrics:         NewRelayMetrics(req.Model),
+		hookInfo:        &interceptor.Info{APIKeyID: 7},
+		promptTokens:    1000,
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	text := &model.InternalLLMRequest{
+		Model:    "gpt-4o",
+		Messages: []model.Message{{Role: "user", Content: model.MessageContent{Content: lo.ToPtr("hi")}}},
+	}
+	rich := &model.InternalLLMRequest{
+		Model:  "gpt-4o",
+		Stream: lo.ToPtr(true),
+		Messages: []model.Message{{Role: "user", Content: model.MessageContent{MultipleContent: []model.MessageContentPart{
+			{Type: "image_url", ImageURL: &model.ImageURL{URL: "https://example.com/a.png"}},
+		}}}},
+		Tools: []model.Tool{{Type: "function", Function: model.Function{Name: "search"}}},
+	}
+
+	cases := []struct {
+		name string
+		req  *model.InternalLLMRequest
+		rule dbmodel.GroupRule
+		want bool
+	}{
+		{"empty rule", text, dbmodel.GroupRule{}, true},
+		{"stream", rich, dbmodel.GroupRule{Stream: lo.ToPtr(true)}, true},
+		{"stream unset is false", text, dbmodel.GroupRule{Stream: lo.ToPtr(true)}, false},
+		{"non stream", text, dbmodel.GroupRule{Stream: lo.ToPtr(false)}, true},
+		{"has tools", rich, dbmodel.GroupRule{HasTools: lo.ToPtr(true)}, true},
+		{"no tools", rich, dbmodel.GroupRule{HasTools: lo.ToPtr(false)}, false},
+		{"has images", rich, dbmodel.GroupRule{HasImages: lo.ToPtr(true)}, true},
+		{"no images", text, dbmodel.GroupRule{HasImages: lo.ToPtr(true)}, false},
+		{"min tokens", text, dbmodel.GroupRule{MinInputTokens: 1000}, true},
+		{"below min tokens", text, dbmodel.GroupRule{MinInputTokens: 1001}, false},
+		{"max tokens", text, dbmodel.GroupRule{MaxInputTokens: 1000}, true},
+		{"above max tokens", text, dbmodel.GroupRule{MaxInputTokens: 999}, false},
+		{"api key", text, dbmodel.GroupRule{APIKeyIDs: []int{3, 7}}, true},
+		{"other api key", text, dbmodel.GroupRule{APIKeyIDs: []int{3}}, false},
+		{"header wildcard", text, dbmodel.GroupRule{Headers: map[string]string{"x-team": "SEA*"}}, true},
+		{"header mismatch", text, dbmodel.GroupRule{Headers: map[string]string{"X-Team": "ads"}}, false},
+		{"missing header", text, dbmodel.GroupRule{Headers: map[string]string{"X-Other": "*x*"}}, false},
+		{"all conditions", rich, dbmodel.GroupRule{Stream: lo.ToPtr(true), HasTools: lo.ToPtr(true), MaxInputTokens: 2000, APIKeyIDs: []int{7}}, true},
+		{"one condition fails", rich, dbmodel.GroupRule{Stream: lo.ToPtr(true), HasTools: lo.ToPtr(true), APIKeyIDs: []int{8}}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := newTestGroupRouter(tc.req).ruleMatches(&tc.rule); got != tc.want {
+				t.Errorf("ruleMatches = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyRules(t *testing.T) {
+	req := &model.InternalLLMRequest{
+		Model:    "gpt-4o",
+		Stream:   lo.ToPtr(true),
+		Messages: []model.Message{{Role: "user", Content: model.MessageContent{Content: lo.ToPtr("hi")}}},
+	}
+	items := []dbmodel.GroupItem{{ID: 1}, {ID: 2}, {ID: 3}}
+	failover := lo.ToPtr(dbmodel.GroupModeFailover)
+
+	cases := []struct {
+		name  string
+		rules []dbmodel.GroupRule
+		items []int
+		mode  dbmodel.GroupMode
+		hit   []string
+	}{
+		{"no rules", nil, []int{1, 2, 3}, dbmodel.GroupModeRoundRobin, nil},
+		{"no match", []dbmodel.GroupRule{{Name: "batch", Stream: lo.ToPtr(false), ItemIDs: []int{1}}}, []int{1, 2, 3}, dbmodel.GroupModeRoundRobin, nil},
+		{"items", []dbmodel.GroupRule{{Name: "stream", Stream: lo.ToPtr(true), ItemIDs: []int{3, 1}}}, []int{1, 3}, dbmodel.GroupModeRoundRobin, []string{"g/stream"}},
+		{"mode", []dbmodel.GroupRule{{Name: "stream", Stream: lo.ToPtr(true), Mode: failover}}, []int{1, 2, 3}, dbmodel.GroupModeFailover, []string{"g/stream"}},
+		{"first match wins", []dbmodel.GroupRule{
+			{Name: "batch", Stream: lo.ToPtr(false), ItemIDs: []int{1}},
+			{Name: "stream", Stream: lo.ToPtr(true), ItemIDs: []int{2}},
+			{Name: "all", ItemIDs: []int{3}, Mode: failover},
+		}, []int{2}, dbmodel.GroupModeRoundRobin, []string{"g/stream"}},
+		{"deleted items", []dbmodel.GroupRule{{Name: "stale", ItemIDs: []int{9}, Mode: failover}}, []int{1, 2, 3}, dbmodel.GroupModeFailover, []string{"g/stale"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			group := dbmodel.Group{ID: 1, Name: "g", Mode: dbmodel.GroupModeRoundRobin, Items: items, Rules: tc.rules}
+			router := newTestGroupRouter(req)
+			result := router.applyRules(group)
+			ids := lo.Map(result.items, func(item dbmodel.GroupItem, _ int) int { return item.ID })
+			if !slices.Equal(ids, tc.items) || result.mode != tc.mode {
+				t.Errorf("applyRules = %v/%d, want %v/%d", ids, result.mode, tc.items, tc.mode)
+			}
+			if !slices.Equal(router.metrics.RouteRules, tc.hit) {
+				t.Errorf("route rules = %v, want %v", router.metrics.RouteRules, tc.hit)
+			}
+
+			// 同一请求内再次匹配使用缓存的结果
+			group.Rules = nil
+			if again := router.applyRules(group); len(again.items) != len(result.items) || again.mode != result.mode {
+				t.Errorf("second applyRules = %+v, want cached %+v", again, result)
+			}
+		})
+	}
+}
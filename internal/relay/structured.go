@@ -0,0 +1,177 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/bestruirui/octopus/internal/utils/jsonschema"
+	"github.com/bestruirui/octopus/internal/utils/log"
+	"github.com/samber/lo"
+)
+
+// errStructuredOutputInvalid 上游返回的内容不符合请求的 JSON Schema
+var errStructuredOutputInvalid = errors.New("structured output does not match the schema")
+
+// maxStructuredOutputReask 同一渠道内要求模型重新回答的最大次数，超过后转发到下一个渠道
+const maxStructuredOutputReask = 2
+
+// structuredOutput 校验结构化输出请求的回复是否符合 schema
+type structuredOutput struct {
+	schema *jsonschema.Schema
+	reask  bool
+}
+
+// newStructuredOutput 根据设置创建校验器，未开启校验或请求不要求 JSON 输出时返回 nil
+func newStructuredOutput(req *model.InternalLLMRequest, mode string) *structuredOutput {
+	if mode != "failover" && mode != "reask" {
+		return nil
+	}
+	raw := req.StructuredOutputSchema()
+	if raw == nil {
+		return nil
+	}
+	schema, err := jsonschema.Compile(raw)
+	if err != nil {
+		log.Warnf("structured output schema is not supported, skip validation: %v", err)
+		return nil
+	}
+	return &structuredOutput{schema: schema, reask: mode == "reask"}
+}
+
+// validate 校验每个 choice 的文本内容，调用工具的 choice 不含结构化输出，跳过
+// 不合规时返回第一个不合规的 choice 在 resp.Choices 中的位置，没有 choice 时位置为 -1
+func (s *structuredOutput) validate(resp *model.InternalLLMResponse) (int, error) {
+	if resp == nil || len(resp.Choices) == 0 {
+		return -1, fmt.Errorf("upstream returned no choices")
+	}
+	for i, choice := range resp.Choices {
+		if choice.Message == nil {
+			return i, fmt.Errorf("choice %d: no message", choice.Index)
+		}
+		if len(choice.Message.ToolCalls) > 0 {
+			continue
+		}
+		if err := s.schema.ValidateJSON([]byte(extractJSONText(messageText(choice.Message)))); err != nil {
+			return i, fmt.Errorf("choice %d: %w", choice.Index, err)
+		}
+	}
+	return -1, nil
+}
+
+// reaskRequest 在原请求后追加不合规的回复和校验错误，要求模型重新回答
+// invalid 为不合规的 choice 的位置，n > 1 时重新生成全部 choice，反馈以该 choice 的回复为准
+func (s *structuredOutput) reaskRequest(req *model.InternalLLMRequest, resp *model.InternalLLMResponse, invalid int, validateErr error) *model.InternalLLMRequest {
+	var previous string
+	if invalid >= 0 && invalid < len(resp.Choices) && resp.Choices[invalid].Message != nil {
+		previous = messageText(resp.Choices[invalid].Message)
+	}
+	feedback := fmt.Sprintf("Your previous response is not valid against the required JSON schema: %v. Respond again with only the JSON value that matches the schema, without any other text.", validateErr)
+
+	reask := *req
+	reask.Messages = append(slices.Clip(req.Messages),
+		model.Message{Role: "assistant", Content: model.MessageContent{Content: lo.ToPtr(previous)}},
+		model.Message{Role: "user", Content: model.MessageContent{Content: lo.ToPtr(feedback)}},
+	)
+	return &reask
+}
+
+// messageText 拼接消息中的文本内容
+func messageText(msg *model.Message) string {
+	if msg.Content.Content != nil {
+		return *msg.Content.Content
+	}
+	var sb strings.Builder
+	for _, part := range msg.Content.MultipleContent {
+		if part.Type == "text" && part.Text != nil {
+			sb.WriteString(*part.Text)
+		}
+	}
+	return sb.String()
+}
+
+// extractJSONText 去掉模型常附带的首尾空白和 ```json 代码块标记
+func extractJSONText(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") || !strings.HasSuffix(text, "```") || len(text) < 6 {
+		return text
+	}
+	text = strings.TrimSuffix(text[3:], "```")
+	if i := strings.IndexByte(text, '\n'); i >= 0 && !strings.ContainsAny(text[:i], "{[\"") {
+		text = text[i+1:]
+	}
+	return strings.TrimSpace(text)
+}
+
+// handleStructuredOutput 读取完整的上游响应并校验结构化输出，通过后再写回客户端
+// 不符合 schema 时按设置在同一次转发内要求模型重新回答，或返回错误转发到下一个渠道
+// 重新回答共用 upstreamCtx 的总时长上限，不再调用拦截器，写回的 usage 包含所有请求的用量
+func (rc *relayContext) handleStructuredOutput(ctx, upstreamCtx context.Context, response *http.Response, clientStream, upstreamStream bool) error {
+	// usage 已丢弃的不合规回复的用量
+	var usage *model.Usage
+	for {
+		internalResponse, err := rc.readInternalResponse(ctx, response, upstreamStream)
+		response.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		invalid, validateErr := rc.structuredOutput.validate(internalResponse)
+		if validateErr == nil {
+			if usage != nil {
+				usage.Add(internalResponse.Usage)
+				internalResponse.Usage = usage
+			}
+			if clientStream {
+				return rc.writeResponseAsStream(ctx, internalResponse)
+			}
+			return rc.writeResponse(ctx, internalResponse)
+		}
+		if !rc.structuredOutput.reask || rc.structuredOutputReasks >= maxStructuredOutputReask {
+			return fmt.Errorf("%w: %v", errStructuredOutputInvalid, validateErr)
+		}
+		rc.structuredOutputReasks++
+		log.Warnf("channel %s: structured output is invalid, re-asking (%d/%d): %v", rc.channel.Name, rc.structuredOutputReasks, maxStructuredOutputReask, validateErr)
+		if internalResponse.Usage != nil {
+			if usage == nil {
+				usage = &model.Usage{}
+			}
+			usage.Add(internalResponse.Usage)
+		}
+
+		// 出站适配器可能保存了流式状态，重新请求时使用新的实例
+		outAdapter, err := newOutboundAdapter(rc.channel)
+		if err != nil {
+			return err
+		}
+		rc.outAdapter = outAdapter
+		if err := rc.wrapOutAdapter(); err != nil {
+			return err
+		}
+		rc.internalRequest = rc.structuredOutput.reaskRequest(rc.internalRequest, internalResponse, invalid, validateErr)
+		outboundRequest, err := rc.buildUpstreamRequest(upstreamCtx, upstreamStream)
+		if err != nil {
+			return err
+		}
+		response, err = rc.sendUpstream(upstreamCtx, outboundRequest, false)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readInternalResponse 读取完整的上游响应，流式响应聚合为非流式
+func (rc *relayContext) readInternalResponse(ctx context.Context, response *http.Response, upstreamStream bool) (*model.InternalLLMResponse, error) {
+	if ct := response.Header.Get("Content-Type"); !upstreamStream || (!rc.customStreamDecoder() && ct != "" && !strings.Contains(strings.ToLower(ct), "text/event-stream")) {
+		internalResponse, err := rc.outboundResponse(ctx, response)
+		if err != nil {
+			return nil, fmt.Errorf("failed to transform outbound response: %w", err)
+		}
+		return internalResponse, nil
+	}
+	return rc.aggregateStream(ctx, response.Body)
+}
@@ -0,0 +1,61 @@
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/bestruirui/octopus/internal/transformer/outbound"
+	"github.com/samber/lo"
+)
+
+func TestStructuredOutputReask(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req model.InternalLLMRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		second := `not json`
+		if calls.Add(1) > 1 {
+			// 重新回答时反馈的是不合规的第二个 choice
+			previous := req.Messages[len(req.Messages)-2]
+			if previous.Role != "assistant" || lo.FromPtr(previous.Content.Content) != "not json" {
+				http.Error(w, fmt.Sprintf("unexpected feedback message %+v", previous), http.StatusBadRequest)
+				return
+			}
+			second = `{\"b\":2}`
+		}
+		fmt.Fprintf(w, `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","choices":[`+
+			`{"index":0,"message":{"role":"assistant","content":"{\"a\":1}"},"finish_reason":"stop"},`+
+			`{"index":1,"message":{"role":"assistant","content":"%s"},"finish_reason":"stop"}],`+
+			`"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`, second)
+	}))
+	defer server.Close()
+
+	rc, recorder := newTestRelayContext(t, outbound.OutboundTypeOpenAIChat, server.URL,
+		`{"model":"gpt-4o","n":2,"messages":[{"role":"user","content":"hi"}],"response_format":{"type":"json_object"}}`)
+	rc.structuredOutput = newStructuredOutput(rc.internalRequest, "reask")
+	if _, err := rc.forward(); err != nil {
+		t.Fatalf("forward: %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("expected 2 upstream calls, got %d", calls.Load())
+	}
+
+	var response model.InternalLLMResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Usage == nil || response.Usage.PromptTokens != 20 || response.Usage.CompletionTokens != 10 {
+		t.Errorf("expected usage summed across attempts, got %+v", response.Usage)
+	}
+	if len(response.Choices) != 2 || lo.FromPtr(response.Choices[1].Message.Content.Content) != `{"b":2}` {
+		t.Errorf("unexpected choices: %+v", response.Choices)
+	}
+}
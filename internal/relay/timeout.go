@@ -0,0 +1,64 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// errUpstreamTimeout 渠道超时配置触发的错误，未向客户端输出前会切换到下一个渠道
+var errUpstreamTimeout = errors.New("upstream timeout")
+
+// traceTimeouts 为上游请求挂载连接超时和响应头超时
+// 连接超时从发起请求开始计时，拿到连接(含 TLS 握手)后停止；响应头超时从请求写完开始计时，收到响应头后停止
+func (rc *relayContext) traceTimeouts(req *http.Request) (*http.Request, func()) {
+	connectSec := rc.channel.ConnectTimeOut
+	headerSec := rc.channel.ResponseHeaderTimeOut
+	if (connectSec <= 0 && headerSec <= 0) || rc.cancelUpstream == nil {
+		return req, func() {}
+	}
+
+	var mu sync.Mutex
+	var connectTimer, headerTimer *time.Timer
+	if connectSec > 0 {
+		connectTimer = time.AfterFunc(time.Duration(connectSec)*time.Second, func() {
+			rc.cancelUpstream(fmt.Errorf("%w: connect time out (%ds)", errUpstreamTimeout, connectSec))
+		})
+	}
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) {
+			if connectTimer != nil {
+				connectTimer.Stop()
+			}
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			if headerSec <= 0 {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if headerTimer != nil {
+				headerTimer.Stop()
+			}
+			headerTimer = time.AfterFunc(time.Duration(headerSec)*time.Second, func() {
+				rc.cancelUpstream(fmt.Errorf("%w: response header time out (%ds)", errUpstreamTimeout, headerSec))
+			})
+		},
+	}
+
+	stop := func() {
+		if connectTimer != nil {
+			connectTimer.Stop()
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if headerTimer != nil {
+			headerTimer.Stop()
+		}
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace)), stop
+}
@@ -1,12 +1,14 @@
 package relay
 
 import (
+	"context"
 	"os"
 	"strconv"
 	"strings"
 
 	"github.com/bestruirui/octopus/internal/conf"
 	dbmodel "github.com/bestruirui/octopus/internal/model"
+	"github.com/bestruirui/octopus/internal/relay/interceptor"
 	"github.com/bestruirui/octopus/internal/transformer/model"
 	"github.com/gin-gonic/gin"
 )
@@ -56,4 +58,34 @@ type relayContext struct {
 	// firstTokenTimeOutSec: streaming-only "time to first token" timeout for the selected group/channel.
 	// When >0 and stream doesn't produce any transformed output within this duration, we abort and retry next channel.
 	firstTokenTimeOutSec int
+
+	// cancelDrainTimeOutSec: after the client disconnects, keep reading the upstream for up to this duration
+	// so the final usage chunk still reaches stats and cost accounting. 0 tears down the upstream immediately.
+	cancelDrainTimeOutSec int
+
+	// heartbeatIntervalSec: while a stream waits for its first token, write an SSE heartbeat at this interval.
+	// Heartbeats are not counted as output, so the request can still fail over to the next channel.
+	heartbeatIntervalSec int
+	// heartbeatProtocol: use the inbound protocol's own ping event (e.g. Anthropic `event: ping`) instead of a comment.
+	heartbeatProtocol bool
+	// stopHeartbeat stops the heartbeat sent while a streaming client waits for a buffered upstream response, nil when not running.
+	stopHeartbeat func()
+	// outputWritten reports whether any response content has been written to the client.
+	outputWritten bool
+
+	// hookInfo describes the request for the interceptors, channel fields follow the current attempt.
+	hookInfo *interceptor.Info
+	// streamed reports whether the response was sent to the client as a stream.
+	streamed bool
+	// reasoningFilter strips or truncates the reasoning content returned to the client.
+	reasoningFilter *reasoningFilter
+	// structuredOutput validates the JSON returned for structured output requests, nil when disabled.
+	structuredOutput *structuredOutput
+	// toolCallParser parses the tool calls out of the completion when the channel emulates tools, nil otherwise.
+	toolCallParser *model.ToolCallParser
+	// structuredOutputReasks counts the re-asks sent to the current channel for invalid structured output.
+	structuredOutputReasks int
+
+	// cancelUpstream cancels the in-flight upstream request with a cause, used by the per-channel timeouts.
+	cancelUpstream context.CancelCauseFunc
 }
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/bestruirui/octopus/internal/model"
 	"github.com/bestruirui/octopus/internal/op"
@@ -61,6 +64,21 @@ func createGroup(c *gin.Context) {
 			return
 		}
 	}
+	if err := validateModelPatterns(group.ModelPatterns); err != nil {
+		resp.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, item := range group.Items {
+		if err := validateGroupItem(item.ChannelID, item.SubGroupID); err != nil {
+			resp.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	// 新建分组的分组项还没有 ID，规则不能引用分组项
+	if err := validateGroupRules(group.Rules, nil); err != nil {
+		resp.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err := op.GroupCreate(&group, c.Request.Context()); err != nil {
 		resp.Error(c, http.StatusInternalServerError, err.Error())
 		return
@@ -81,6 +99,32 @@ func updateGroup(c *gin.Context) {
 			return
 		}
 	}
+	if req.ModelPatterns != nil {
+		if err := validateModelPatterns(*req.ModelPatterns); err != nil {
+			resp.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Rules != nil {
+		var itemIDs []int
+		if group, err := op.GroupGet(req.ID, c.Request.Context()); err == nil {
+			for _, item := range group.Items {
+				if !slices.Contains(req.ItemsToDelete, item.ID) {
+					itemIDs = append(itemIDs, item.ID)
+				}
+			}
+		}
+		if err := validateGroupRules(*req.Rules, itemIDs); err != nil {
+			resp.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	for _, item := range req.ItemsToAdd {
+		if err := validateGroupItem(item.ChannelID, item.SubGroupID); err != nil {
+			resp.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
 	group, err := op.GroupUpdate(&req, c.Request.Context())
 	if err != nil {
 		resp.Error(c, http.StatusInternalServerError, err.Error())
@@ -122,3 +166,45 @@ func deleteGroup(c *gin.Context) {
 // 	}
 // 	resp.Success(c, nil)
 // }
+
+// validateModelPatterns 校验分组的模型名规则
+func validateModelPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("model pattern must not be empty")
+		}
+		if _, err := op.CompileGroupPattern(pattern); err != nil {
+			return fmt.Errorf("invalid model pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// validateGroupItem 校验分组项，渠道和子分组必须且只能设置其中一个
+func validateGroupItem(channelID int, subGroupID *int) error {
+	if (channelID != 0) == (subGroupID != nil) {
+		return fmt.Errorf("exactly one of channel_id and sub_group_id must be set")
+	}
+	return nil
+}
+
+// validateGroupRules 校验分组的条件路由规则，itemIDs 为规则可以引用的分组项
+func validateGroupRules(rules []model.GroupRule, itemIDs []int) error {
+	for _, rule := range rules {
+		if strings.TrimSpace(rule.Name) == "" {
+			return fmt.Errorf("rule name must not be empty")
+		}
+		if rule.Mode != nil && (*rule.Mode < model.GroupModeRoundRobin || *rule.Mode > model.GroupModeWeighted) {
+			return fmt.Errorf("rule %s: invalid mode %d", rule.Name, *rule.Mode)
+		}
+		if rule.MinInputTokens < 0 || rule.MaxInputTokens < 0 || (rule.MaxInputTokens > 0 && rule.MinInputTokens > rule.MaxInputTokens) {
+			return fmt.Errorf("rule %s: invalid input token range", rule.Name)
+		}
+		for _, id := range rule.ItemIDs {
+			if !slices.Contains(itemIDs, id) {
+				return fmt.Errorf("rule %s: item %d does not belong to the group", rule.Name, id)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bestruirui/octopus/internal/model"
+	"github.com/bestruirui/octopus/internal/op"
+	"github.com/bestruirui/octopus/internal/server/middleware"
+	"github.com/bestruirui/octopus/internal/server/resp"
+	"github.com/bestruirui/octopus/internal/server/router"
+	transformer "github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+func init() {
+	router.NewGroupRouter("/api/v1/reasoning").
+		Use(middleware.Auth()).
+		Use(middleware.RequireJSON()).
+		AddRoute(
+			router.NewRoute("/create", http.MethodPost).
+				Handle(createReasoningRule),
+		).
+		AddRoute(
+			router.NewRoute("/list", http.MethodGet).
+				Handle(listReasoningRule),
+		).
+		AddRoute(
+			router.NewRoute("/update", http.MethodPost).
+				Handle(updateReasoningRule),
+		).
+		AddRoute(
+			router.NewRoute("/delete/:id", http.MethodDelete).
+				Handle(deleteReasoningRule),
+		).
+		AddRoute(
+			router.NewRoute("/builtin", http.MethodGet).
+				Handle(listBuiltinReasoningMapping),
+		)
+}
+
+func createReasoningRule(c *gin.Context) {
+	var req model.ReasoningRule
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, http.StatusBadRequest, resp.ErrInvalidJSON)
+		return
+	}
+	if err := validateReasoningRule(&req); err != nil {
+		resp.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.ID = 0
+	if req.Enabled == nil {
+		req.Enabled = lo.ToPtr(true)
+	}
+	if err := op.ReasoningRuleCreate(&req, c.Request.Context()); err != nil {
+		resp.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp.Success(c, req)
+}
+
+func listReasoningRule(c *gin.Context) {
+	rules, err := op.ReasoningRuleList(c.Request.Context())
+	if err != nil {
+		resp.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp.Success(c, rules)
+}
+
+func updateReasoningRule(c *gin.Context) {
+	var req model.ReasoningRule
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, http.StatusBadRequest, resp.ErrInvalidJSON)
+		return
+	}
+	if req.ID <= 0 {
+		resp.Error(c, http.StatusBadRequest, resp.ErrInvalidParam)
+		return
+	}
+	if err := validateReasoningRule(&req); err != nil {
+		resp.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Enabled == nil {
+		req.Enabled = lo.ToPtr(true)
+	}
+	if err := op.ReasoningRuleUpdate(&req, c.Request.Context()); err != nil {
+		resp.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp.Success(c, req)
+}
+
+func deleteReasoningRule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		resp.Error(c, http.StatusBadRequest, resp.ErrInvalidParam)
+		return
+	}
+	if err := op.ReasoningRuleDelete(id, c.Request.Context()); err != nil {
+		resp.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp.Success(c, nil)
+}
+
+// validateReasoningRule 校验推理强度映射规则
+// 预算可为 0（关闭思考）或 -1（由上游动态决定），其余负数无效
+func validateReasoningRule(rule *model.ReasoningRule) error {
+	for effort, budget := range rule.Budgets {
+		if !transformer.IsReasoningEffort(effort) {
+			return fmt.Errorf("invalid reasoning effort %q", effort)
+		}
+		if budget < -1 {
+			return fmt.Errorf("budget of %s must not be negative", effort)
+		}
+	}
+	if rule.DefaultBudget < -1 {
+		return fmt.Errorf("default budget must not be negative")
+	}
+	return nil
+}
+
+// listBuiltinReasoningMapping 返回内置的映射规则，供配置时参考
+func listBuiltinReasoningMapping(c *gin.Context) {
+	resp.Success(c, transformer.BuiltinReasoningMappings())
+}
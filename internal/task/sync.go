@@ -31,6 +31,8 @@ func SyncModelsTask() {
 	}
 	totalNewModels := make([]string, 0, 128)
 	seenTotalNewModels := make(map[string]struct{}, 128)
+	// modelChannels 模型到第一个提供该模型的渠道的映射，用于探测模型能力
+	modelChannels := make(map[string]helper.ProbeTarget, 128)
 	for _, channel := range channels {
 		if !channel.AutoSync {
 			continue
@@ -47,12 +49,14 @@ func SyncModelsTask() {
 			if m == "" {
 				continue
 			}
+			original := m
 			m = strings.ToLower(m)
 			if _, ok := seenTotalNewModels[m]; ok {
 				continue
 			}
 			seenTotalNewModels[m] = struct{}{}
 			totalNewModels = append(totalNewModels, m)
+			modelChannels[m] = helper.ProbeTarget{Channel: &channel, ModelName: original}
 		}
 		deletedModels, addedModels := diff.Diff(oldModels, newModels)
 		if len(deletedModels) > 0 || len(addedModels) > 0 {
@@ -102,6 +106,16 @@ func SyncModelsTask() {
 		if err := helper.LLMPriceAddToDB(addedNorm, ctx); err != nil {
 			log.Errorf("failed to add models price: %v", err)
 		}
+		// 探测新增模型在 models.dev 中没有的能力
+		if probe, _ := op.SettingGetBool(model.SettingKeySyncCapabilityProbe); probe {
+			probeChannels := make(map[string]helper.ProbeTarget, len(addedNorm))
+			for _, m := range addedNorm {
+				if target, ok := modelChannels[m]; ok {
+					probeChannels[m] = target
+				}
+			}
+			helper.LLMCapabilitiesProbeToDB(probeChannels, ctx)
+		}
 	}
 	lastSyncModelsTime = time.Now()
 }
@@ -0,0 +1,101 @@
+package anthropic
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bestruirui/octopus/internal/transformer/model"
+)
+
+// convertToLLMBuiltinTool converts anthropic server tools to internal built-in tools.
+// Returns false if the tool is not a supported server tool.
+func convertToLLMBuiltinTool(tool Tool) (model.Tool, bool) {
+	switch {
+	case strings.HasPrefix(tool.Type, "web_search_"):
+		webSearch := &model.WebSearch{
+			MaxUses:        tool.MaxUses,
+			AllowedDomains: tool.AllowedDomains,
+			BlockedDomains: tool.BlockedDomains,
+		}
+		if loc := tool.UserLocation; loc != nil {
+			webSearch.UserLocation = &model.WebSearchUserLocation{
+				City:     loc.City,
+				Region:   loc.Region,
+				Country:  loc.Country,
+				Timezone: loc.Timezone,
+			}
+		}
+		return model.Tool{Type: "web_search", WebSearch: webSearch}, true
+	case strings.HasPrefix(tool.Type, "code_execution_"):
+		return model.Tool{Type: "code_execution"}, true
+	}
+	return model.Tool{}, false
+}
+
+// citationFromAnnotation converts the internal url citation to anthropic web search citation.
+func citationFromAnnotation(annotation model.Annotation, citedText string) *Citation {
+	if annotation.Type != "url_citation" || annotation.URLCitation == nil {
+		return nil
+	}
+	citation := &Citation{
+		Type:      "web_search_result_location",
+		CitedText: citedText,
+		URL:       annotation.URLCitation.URL,
+	}
+	if annotation.URLCitation.Title != "" {
+		citation.Title = &annotation.URLCitation.Title
+	}
+	return citation
+}
+
+// splitTextByAnnotations 按引用的位置将文本拆分为多个 text 块，被引用的部分附带 citations
+// 与 Anthropic 的返回格式一致，重叠的引用合并到先出现的块中
+func splitTextByAnnotations(text string, annotations []model.Annotation) []MessageContentBlock {
+	runes := []rune(text)
+	valid := make([]model.Annotation, 0, len(annotations))
+	for _, annotation := range annotations {
+		c := annotation.URLCitation
+		if annotation.Type == "url_citation" && c != nil && c.StartIndex >= 0 && c.StartIndex < c.EndIndex && c.EndIndex <= len(runes) {
+			valid = append(valid, annotation)
+		}
+	}
+	if len(valid) == 0 {
+		return []MessageContentBlock{{Type: "text", Text: &text}}
+	}
+	sort.SliceStable(valid, func(a, b int) bool {
+		return valid[a].URLCitation.StartIndex < valid[b].URLCitation.StartIndex
+	})
+
+	var blocks []MessageContentBlock
+	appendText := func(start, end int, citations []Citation) {
+		if start >= end {
+			return
+		}
+		segment := string(runes[start:end])
+		blocks = append(blocks, MessageContentBlock{Type: "text", Text: &segment, Citations: citations})
+	}
+
+	pos := 0
+	for idx := 0; idx < len(valid); {
+		start, end := max(valid[idx].URLCitation.StartIndex, pos), valid[idx].URLCitation.EndIndex
+		// 合并与当前块重叠的引用
+		group := []model.Annotation{valid[idx]}
+		for idx++; idx < len(valid) && valid[idx].URLCitation.StartIndex < end; idx++ {
+			end = max(end, valid[idx].URLCitation.EndIndex)
+			group = append(group, valid[idx])
+		}
+		if start >= end {
+			continue
+		}
+		appendText(pos, start, nil)
+		citedText := string(runes[start:end])
+		citations := make([]Citation, 0, len(group))
+		for _, annotation := range group {
+			citations = append(citations, *citationFromAnnotation(annotation, citedText))
+		}
+		appendText(start, end, citations)
+		pos = end
+	}
+	appendText(pos, len(runes), nil)
+	return blocks
+}
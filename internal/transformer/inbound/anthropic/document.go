@@ -0,0 +1,37 @@
+package anthropic
+
+import (
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/samber/lo"
+)
+
+// convertDocumentToLLMPart converts the anthropic document block to the internal file part.
+// Plain text documents are converted to text parts, since not all providers accept text files.
+func convertDocumentToLLMPart(block MessageContentBlock) (model.MessageContentPart, bool) {
+	source := block.Source
+	if source == nil {
+		return model.MessageContentPart{}, false
+	}
+	part := model.MessageContentPart{
+		Type:         "file",
+		CacheControl: convertToLLMCacheControl(block.CacheControl),
+	}
+	filename := lo.FromPtr(block.Title)
+
+	switch source.Type {
+	case "base64":
+		mediaType := lo.Ternary(source.MediaType != "", source.MediaType, "application/pdf")
+		part.File = &model.File{Filename: filename, FileData: "data:" + mediaType + ";base64," + source.Data}
+	case "url":
+		part.File = &model.File{Filename: filename, FileURL: source.URL}
+	case "text":
+		text := source.Data
+		if filename != "" {
+			text = filename + "\n\n" + text
+		}
+		return model.MessageContentPart{Type: "text", Text: &text, CacheControl: part.CacheControl}, true
+	default:
+		return model.MessageContentPart{}, false
+	}
+	return part, true
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 
 	"github.com/bestruirui/octopus/internal/transformer/model"
 	"github.com/bestruirui/octopus/internal/utils/tokenizer"
@@ -25,6 +26,8 @@ type MessagesInbound struct {
 	stopReason                *string
 	toolCallIndices           map[int]bool // Track which tool call indices we've seen
 	inputToken                int64
+	// streamText 已输出的文本，用于生成引用的 cited_text
+	streamText []rune
 
 	// Stream chunks storage for aggregation
 	streamChunks []*model.InternalLLMResponse
@@ -150,6 +153,15 @@ func (i *MessagesInbound) TransformRequest(ctx context.Context, body []byte) (*m
 							}
 						}
 
+						i.inputToken += int64(tokenizer.CountImageTokens(part.ImageURL.URL, "", chatReq.Model))
+						contentParts = append(contentParts, part)
+						hasContent = true
+					}
+				case "document":
+					if part, ok := convertDocumentToLLMPart(block); ok {
+						if part.Text != nil {
+							i.inputToken += int64(tokenizer.CountTokens(*part.Text, chatReq.Model))
+						}
 						contentParts = append(contentParts, part)
 						hasContent = true
 					}
@@ -250,7 +262,14 @@ func (i *MessagesInbound) TransformRequest(ctx context.Context, body []byte) (*m
 	// Convert tools
 	if len(anthropicReq.Tools) > 0 {
 		tools := make([]model.Tool, 0, len(anthropicReq.Tools))
+		countTools := make([]tokenizer.Tool, 0, len(anthropicReq.Tools))
 		for _, tool := range anthropicReq.Tools {
+			if tool.IsServerTool() {
+				if llmTool, ok := convertToLLMBuiltinTool(tool); ok {
+					tools = append(tools, llmTool)
+				}
+				continue
+			}
 			llmTool := model.Tool{
 				Type: "function",
 				Function: model.Function{
@@ -261,11 +280,9 @@ func (i *MessagesInbound) TransformRequest(ctx context.Context, body []byte) (*m
 				CacheControl: convertToLLMCacheControl(tool.CacheControl),
 			}
 			tools = append(tools, llmTool)
-			i.inputToken += int64(tokenizer.CountTokens(tool.Name, chatReq.Model))
-			i.inputToken += int64(tokenizer.CountTokens(tool.Description, chatReq.Model))
-			i.inputToken += int64(tokenizer.CountTokens(string(tool.InputSchema), chatReq.Model))
+			countTools = append(countTools, tokenizer.Tool{Name: tool.Name, Description: tool.Description, Parameters: string(tool.InputSchema)})
 		}
-		i.inputToken += int64(len(tools) * 3)
+		i.inputToken += int64(tokenizer.CountToolTokens(countTools, chatReq.Model))
 
 		chatReq.Tools = tools
 	}
@@ -285,7 +302,7 @@ func (i *MessagesInbound) TransformRequest(ctx context.Context, body []byte) (*m
 
 	// Convert thinking configuration to reasoning effort and preserve budget
 	if anthropicReq.Thinking != nil && anthropicReq.Thinking.Type == "enabled" {
-		chatReq.ReasoningEffort = thinkingBudgetToReasoningEffort(anthropicReq.Thinking.BudgetTokens)
+		chatReq.ReasoningEffort = thinkingBudgetToReasoningEffort(chatReq.Model, anthropicReq.Thinking.BudgetTokens)
 		chatReq.ReasoningBudget = lo.ToPtr(anthropicReq.Thinking.BudgetTokens)
 	}
 	return chatReq, nil
@@ -334,10 +351,7 @@ func (i *MessagesInbound) TransformResponse(ctx context.Context, response *model
 
 			// Handle regular content
 			if message.Content.Content != nil && *message.Content.Content != "" {
-				contentBlocks = append(contentBlocks, MessageContentBlock{
-					Type: "text",
-					Text: message.Content.Content,
-				})
+				contentBlocks = append(contentBlocks, splitTextByAnnotations(*message.Content.Content, message.Annotations)...)
 			} else if len(message.Content.MultipleContent) > 0 {
 				for _, part := range message.Content.MultipleContent {
 					switch part.Type {
@@ -631,6 +645,34 @@ func (i *MessagesInbound) TransformStream(ctx context.Context, stream *model.Int
 				return nil, fmt.Errorf("failed to marshal content_block_delta event: %w", err)
 			}
 			events = append(events, formatSSEEvent("content_block_delta", data))
+			i.streamText = append(i.streamText, []rune(*choice.Delta.Content.Content)...)
+		}
+
+		// Handle annotations, citations can only be attached to the current text block
+		if choice.Delta != nil && len(choice.Delta.Annotations) > 0 && i.hasTextContentStarted {
+			for _, annotation := range choice.Delta.Annotations {
+				var citedText string
+				if c := annotation.URLCitation; c != nil && c.StartIndex >= 0 && c.StartIndex < c.EndIndex && c.EndIndex <= len(i.streamText) {
+					citedText = string(i.streamText[c.StartIndex:c.EndIndex])
+				}
+				citation := citationFromAnnotation(annotation, citedText)
+				if citation == nil {
+					continue
+				}
+				citationEvent := StreamEvent{
+					Type:  "content_block_delta",
+					Index: &i.contentIndex,
+					Delta: &StreamDelta{
+						Type:     lo.ToPtr("citations_delta"),
+						Citation: citation,
+					},
+				}
+				data, err := json.Marshal(citationEvent)
+				if err != nil {
+					return nil, fmt.Errorf("failed to marshal citations_delta event: %w", err)
+				}
+				events = append(events, formatSSEEvent("content_block_delta", data))
+			}
 		}
 
 		// Handle tool calls
@@ -985,6 +1027,35 @@ func mergeToolCall(toolCalls []model.ToolCall, delta model.ToolCall) []model.Too
 	return append(toolCalls, delta)
 }
 
+// StreamHeartbeat 返回 Anthropic 的 ping 事件，用于等待首个 Token 时保持连接
+func (i *MessagesInbound) StreamHeartbeat() []byte {
+	return formatSSEEvent("ping", []byte(`{"type":"ping"}`))
+}
+
+// StreamError 返回 Anthropic 的 error 事件
+func (i *MessagesInbound) StreamError(statusCode int, message string) []byte {
+	errorType := "api_error"
+	switch statusCode {
+	case http.StatusBadRequest:
+		errorType = "invalid_request_error"
+	case http.StatusUnauthorized:
+		errorType = "authentication_error"
+	case http.StatusForbidden:
+		errorType = "permission_error"
+	case http.StatusNotFound:
+		errorType = "not_found_error"
+	case http.StatusTooManyRequests:
+		errorType = "rate_limit_error"
+	case 529:
+		errorType = "overloaded_error"
+	}
+	data, _ := json.Marshal(map[string]any{
+		"type":  "error",
+		"error": map[string]string{"type": errorType, "message": message},
+	})
+	return formatSSEEvent("error", data)
+}
+
 // formatSSEEvent 格式化为完整的 SSE 事件格式
 func formatSSEEvent(eventType string, data []byte) []byte {
 	return []byte(fmt.Sprintf("event:%s\ndata:%s\n\n", eventType, string(data)))
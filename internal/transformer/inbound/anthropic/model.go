@@ -151,12 +151,56 @@ type ToolChoice struct {
 // Tool represents a tool definition for Anthropic API.
 type Tool struct {
 	// Ensure the omitempty, otherwise it will be sent empty string to the API, will cause some providers ignore the tool.
-	// For now, we only support function (client tool or custom tool in anthropic) tool, so we can just omit the type.
-	// Type         string          `json:"type,omitempty"`
+	// Empty or "custom" for function (client tool or custom tool in anthropic) tool,
+	// otherwise server tool, e.g. "web_search_20250305", "code_execution_20250522".
+	Type         string          `json:"type,omitempty"`
 	Name         string          `json:"name"`
 	Description  string          `json:"description"`
 	InputSchema  json.RawMessage `json:"input_schema"`
 	CacheControl *CacheControl   `json:"cache_control,omitempty"`
+
+	// Web search server tool fields
+	MaxUses        *int64        `json:"max_uses,omitempty"`
+	AllowedDomains []string      `json:"allowed_domains,omitempty"`
+	BlockedDomains []string      `json:"blocked_domains,omitempty"`
+	UserLocation   *UserLocation `json:"user_location,omitempty"`
+}
+
+// IsServerTool returns true if the tool is executed by anthropic, e.g. web search and code execution.
+func (t Tool) IsServerTool() bool {
+	return t.Type != "" && t.Type != "custom"
+}
+
+func (t Tool) MarshalJSON() ([]byte, error) {
+	if t.IsServerTool() {
+		// Server tools do not accept description and input_schema.
+		return json.Marshal(struct {
+			Type           string        `json:"type"`
+			Name           string        `json:"name"`
+			CacheControl   *CacheControl `json:"cache_control,omitempty"`
+			MaxUses        *int64        `json:"max_uses,omitempty"`
+			AllowedDomains []string      `json:"allowed_domains,omitempty"`
+			BlockedDomains []string      `json:"blocked_domains,omitempty"`
+			UserLocation   *UserLocation `json:"user_location,omitempty"`
+		}{t.Type, t.Name, t.CacheControl, t.MaxUses, t.AllowedDomains, t.BlockedDomains, t.UserLocation})
+	}
+	// For function tool, we omit the type.
+	return json.Marshal(struct {
+		Name         string          `json:"name"`
+		Description  string          `json:"description"`
+		InputSchema  json.RawMessage `json:"input_schema"`
+		CacheControl *CacheControl   `json:"cache_control,omitempty"`
+	}{t.Name, t.Description, t.InputSchema, t.CacheControl})
+}
+
+// UserLocation is the approximate location of the user for the web search tool.
+type UserLocation struct {
+	// Always "approximate".
+	Type     string `json:"type"`
+	City     string `json:"city,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Country  string `json:"country,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
 }
 
 type CacheControl struct {
@@ -247,7 +291,7 @@ func (c *MessageContent) UnmarshalJSON(data []byte) error {
 
 // MessageContentBlock represents different types of content blocks.
 type MessageContentBlock struct {
-	// Any of "text", "image", "thinking", "redacted_thinking", "tool_use", "server_tool_use", "tool_result".
+	// Any of "text", "image", "document", "thinking", "redacted_thinking", "tool_use", "server_tool_use", "tool_result".
 	Type string `json:"type"`
 
 	// Text will be present if type is "text".
@@ -265,9 +309,12 @@ type MessageContentBlock struct {
 	// Data will be present if type is "redacted_thinking".
 	Data string `json:"data,omitempty"`
 
-	// Image will be present if type is "image".
+	// Source will be present if type is "image" or "document".
 	Source *ImageSource `json:"source,omitempty"`
 
+	// Title is the optional title of the document, will be present if type is "document".
+	Title *string `json:"title,omitempty"`
+
 	// Tool use request
 	// tool_use or server_tool_use
 	ID           string          `json:"id,omitempty"`
@@ -281,22 +328,36 @@ type MessageContentBlock struct {
 	// Type can be "text" or "image".
 	Content *MessageContent `json:"content,omitempty"`
 	IsError *bool           `json:"is_error,omitempty"`
+
+	// Citations will be present if type is "text" and the text is supported by the web search results.
+	Citations []Citation `json:"citations,omitempty"`
+}
+
+// Citation represents a citation of the text block.
+type Citation struct {
+	// Type is the type of citation, e.g. "web_search_result_location".
+	Type           string  `json:"type"`
+	CitedText      string  `json:"cited_text"`
+	URL            string  `json:"url,omitempty"`
+	Title          *string `json:"title,omitempty"`
+	EncryptedIndex string  `json:"encrypted_index,omitempty"`
 }
 
 // ImageSource represents image source for Anthropic.
 type ImageSource struct {
-	// Type is the type of image source.
-	// Available values: base64, url
+	// Type is the type of image or document source.
+	// Available values: base64, url, text (document only)
 	Type string `json:"type"`
-	// MediaType is the media type of image.
-	// Available values: image/png, image/jpeg, image/gif, image/webp
-	MediaType string `json:"media_type"`
+	// MediaType is the media type of image or document.
+	// Available values: image/png, image/jpeg, image/gif, image/webp, application/pdf, text/plain
+	MediaType string `json:"media_type,omitempty"`
 
-	// Data is the image data.
-	// If Type is base64, Data is the base64-encoded image data.
-	Data string `json:"data"`
+	// Data is the image or document data.
+	// If Type is base64, Data is the base64-encoded data.
+	// If Type is text, Data is the plain text of the document.
+	Data string `json:"data,omitempty"`
 
-	// URL is the URL of the image.
+	// URL is the URL of the image or document.
 	// It will be present if Type is url.
 	URL string `json:"url,omitempty"`
 }
@@ -341,6 +402,9 @@ type StreamDelta struct {
 	// Signature will be present if type is "signature_delta".
 	Signature *string `json:"signature,omitempty"`
 
+	// Citation will be present if type is "citations_delta".
+	Citation *Citation `json:"citation,omitempty"`
+
 	// For "message_delta"
 	// Any of "end_turn", "max_tokens", "stop_sequence", "tool_use", "pause_turn",
 	// "refusal".
@@ -3,6 +3,7 @@ package openai
 import (
 	"context"
 	"encoding/json"
+	"net/http"
 
 	"github.com/bestruirui/octopus/internal/transformer/model"
 )
@@ -19,6 +20,7 @@ func (i *ChatInbound) TransformRequest(ctx context.Context, body []byte) (*model
 	if err := json.Unmarshal(body, &request); err != nil {
 		return nil, err
 	}
+	request.ApplyWebSearchOptions()
 	return &request, nil
 }
 
@@ -66,6 +68,26 @@ func (i *ChatInbound) TransformStream(ctx context.Context, stream *model.Interna
 	return []byte("data: " + string(body) + "\n\n"), nil
 }
 
+// StreamError 返回 OpenAI 流式响应中的错误数据块
+func (i *ChatInbound) StreamError(statusCode int, message string) []byte {
+	body, _ := json.Marshal(map[string]any{
+		"error": map[string]any{"message": message, "type": streamErrorType(statusCode), "code": statusCode},
+	})
+	return []byte("data: " + string(body) + "\n\n")
+}
+
+// streamErrorType 按状态码返回 OpenAI 的错误类型
+func streamErrorType(statusCode int) string {
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		return "rate_limit_exceeded"
+	case statusCode < 500:
+		return "invalid_request_error"
+	default:
+		return "server_error"
+	}
+}
+
 // GetInternalResponse returns the complete internal response for logging, statistics, etc.
 // For streaming: aggregates all stored stream chunks into a complete response
 // For non-streaming: returns the stored response
@@ -164,6 +186,9 @@ func (i *ChatInbound) GetInternalResponse(ctx context.Context) (*model.InternalL
 					existingChoice.Message.ToolCalls = mergeToolCall(existingChoice.Message.ToolCalls, toolCall)
 				}
 
+				// Append annotations
+				existingChoice.Message.Annotations = append(existingChoice.Message.Annotations, delta.Annotations...)
+
 				// Set refusal if present
 				if delta.Refusal != "" {
 					existingChoice.Message.Refusal = delta.Refusal
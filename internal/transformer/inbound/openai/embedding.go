@@ -20,6 +20,9 @@ type OpenAIEmbeddingRequest struct {
 	Dimensions     *int64               `json:"dimensions,omitempty"`
 	EncodingFormat *string              `json:"encoding_format,omitempty"`
 	User           *string              `json:"user,omitempty"`
+	// 非 OpenAI 标准参数，透传给支持任务类型的上游
+	TaskType  *string `json:"task_type,omitempty"`
+	InputType *string `json:"input_type,omitempty"`
 }
 
 // OpenAIEmbeddingResponse 是 OpenAI 标准的 embedding 响应格式
@@ -45,6 +48,10 @@ func (i *EmbeddingInbound) TransformRequest(ctx context.Context, body []byte) (*
 	request.EmbeddingDimensions = openAIReq.Dimensions
 	request.EmbeddingEncodingFormat = openAIReq.EncodingFormat
 	request.User = openAIReq.User
+	request.EmbeddingTaskType = openAIReq.TaskType
+	if request.EmbeddingTaskType == nil {
+		request.EmbeddingTaskType = openAIReq.InputType
+	}
 	request.RawAPIFormat = model.APIFormatOpenAIEmbedding
 
 	return &request, nil
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/samber/lo"
 
@@ -34,8 +35,13 @@ type ResponseInbound struct {
 	currentItemID  string
 
 	// Content accumulation
-	accumulatedText      strings.Builder
-	accumulatedReasoning strings.Builder
+	accumulatedText        strings.Builder
+	accumulatedReasoning   strings.Builder
+	accumulatedAnnotations []ResponsesAnnotation
+	// totalTextLen 已输出文本的字符数，messageTextStart 当前消息项的起始字符位置
+	// 内部引用的位置相对于整条回复，转换时需减去当前消息项的起始位置
+	totalTextLen     int
+	messageTextStart int
 
 	// Tool call tracking
 	toolCalls           map[int]*model.ToolCall
@@ -153,6 +159,11 @@ func (i *ResponseInbound) TransformStream(ctx context.Context, stream *model.Int
 			events = append(events, i.handleTextContent(choice.Delta.Content.Content)...)
 		}
 
+		// Handle annotations
+		if choice.Delta != nil && len(choice.Delta.Annotations) > 0 {
+			events = append(events, i.handleAnnotations(choice.Delta.Annotations)...)
+		}
+
 		// Handle tool calls
 		if choice.Delta != nil && len(choice.Delta.ToolCalls) > 0 {
 			events = append(events, i.handleToolCalls(choice.Delta.ToolCalls)...)
@@ -278,6 +289,7 @@ func (i *ResponseInbound) handleTextContent(content *string) [][]byte {
 	if !i.hasMessageItemStarted {
 		i.hasMessageItemStarted = true
 		i.currentItemID = generateItemID()
+		i.messageTextStart = i.totalTextLen
 
 		events = append(events, i.enqueueEvent(&ResponsesStreamEvent{
 			Type:        "response.output_item.added",
@@ -310,6 +322,7 @@ func (i *ResponseInbound) handleTextContent(content *string) [][]byte {
 
 	// Accumulate text content
 	i.accumulatedText.WriteString(*content)
+	i.totalTextLen += utf8.RuneCountInString(*content)
 
 	// Emit output_text.delta
 	events = append(events, i.enqueueEvent(&ResponsesStreamEvent{
@@ -323,6 +336,27 @@ func (i *ResponseInbound) handleTextContent(content *string) [][]byte {
 	return events
 }
 
+func (i *ResponseInbound) handleAnnotations(annotations []model.Annotation) [][]byte {
+	// 引用只能附加在正在输出的文本上
+	if !i.hasContentPartStarted {
+		return nil
+	}
+
+	var events [][]byte
+	for _, annotation := range convertAnnotationsToResponses(annotations, i.messageTextStart) {
+		events = append(events, i.enqueueEvent(&ResponsesStreamEvent{
+			Type:            "response.output_text.annotation.added",
+			ItemID:          &i.currentItemID,
+			OutputIndex:     lo.ToPtr(i.outputIndex),
+			ContentIndex:    &i.contentIndex,
+			AnnotationIndex: lo.ToPtr(len(i.accumulatedAnnotations)),
+			Annotation:      &annotation,
+		}))
+		i.accumulatedAnnotations = append(i.accumulatedAnnotations, annotation)
+	}
+	return events
+}
+
 func (i *ResponseInbound) handleToolCalls(toolCalls []model.ToolCall) [][]byte {
 	var events [][]byte
 
@@ -471,8 +505,9 @@ func (i *ResponseInbound) closeMessageItem() [][]byte {
 		Role:   "assistant",
 		Content: &ResponsesInput{
 			Items: []ResponsesItem{{
-				Type: "output_text",
-				Text: &fullText,
+				Type:        "output_text",
+				Text:        &fullText,
+				Annotations: lo.ToPtr(append([]ResponsesAnnotation{}, i.accumulatedAnnotations...)),
 			}},
 		},
 	}
@@ -486,6 +521,7 @@ func (i *ResponseInbound) closeMessageItem() [][]byte {
 	i.outputIndex++
 	i.contentIndex = 0
 	i.accumulatedText.Reset()
+	i.accumulatedAnnotations = nil
 
 	return events
 }
@@ -515,8 +551,9 @@ func (i *ResponseInbound) closeCurrentContentPart() [][]byte {
 		OutputIndex:  lo.ToPtr(i.outputIndex),
 		ContentIndex: &i.contentIndex,
 		Part: &ResponsesContentPart{
-			Type: "output_text",
-			Text: lo.ToPtr(fullText),
+			Type:        "output_text",
+			Text:        lo.ToPtr(fullText),
+			Annotations: i.accumulatedAnnotations,
 		},
 	}))
 
@@ -693,6 +730,22 @@ func (i *ResponseInbound) GetInternalResponse(ctx context.Context) (*model.Inter
 	return result, nil
 }
 
+// StreamError 返回 Responses 流式响应中的 error 事件
+func (i *ResponseInbound) StreamError(statusCode int, message string) []byte {
+	data, err := json.Marshal(struct {
+		Type           string  `json:"type"`
+		SequenceNumber int     `json:"sequence_number"`
+		Code           string  `json:"code"`
+		Message        string  `json:"message"`
+		Param          *string `json:"param"`
+	}{Type: "error", SequenceNumber: i.sequenceNumber, Code: streamErrorType(statusCode), Message: message})
+	if err != nil {
+		return nil
+	}
+	i.sequenceNumber++
+	return formatSSEData(data)
+}
+
 // formatSSEData formats data as SSE data line
 func formatSSEData(data []byte) []byte {
 	return []byte(fmt.Sprintf("data: %s\n\n", string(data)))
@@ -757,6 +810,12 @@ type ResponsesItem struct {
 	ImageURL *string         `json:"image_url,omitempty"`
 	Detail   *string         `json:"detail,omitempty"`
 
+	// Input file fields
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data,omitempty"`
+	FileURL  string `json:"file_url,omitempty"`
+	FileID   string `json:"file_id,omitempty"`
+
 	// Annotations for output_text content
 	Annotations *[]ResponsesAnnotation `json:"annotations,omitempty"`
 
@@ -841,6 +900,23 @@ type ResponsesTool struct {
 	Quality           string         `json:"quality,omitempty"`
 	Size              string         `json:"size,omitempty"`
 	OutputCompression *int64         `json:"output_compression,omitempty"`
+
+	// Web search fields
+	Filters           *ResponsesWebSearchFilters `json:"filters,omitempty"`
+	SearchContextSize string                     `json:"search_context_size,omitempty"`
+	UserLocation      *ResponsesUserLocation     `json:"user_location,omitempty"`
+}
+
+type ResponsesWebSearchFilters struct {
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+}
+
+type ResponsesUserLocation struct {
+	Type     string `json:"type,omitempty"`
+	City     string `json:"city,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Country  string `json:"country,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
 }
 
 type ResponsesToolChoice struct {
@@ -872,9 +948,11 @@ type ResponsesTextOptions struct {
 }
 
 type ResponsesTextFormat struct {
-	Type   string          `json:"type,omitempty"`
-	Name   string          `json:"name,omitempty"`
-	Schema json.RawMessage `json:"schema,omitempty"`
+	Type        string          `json:"type,omitempty"`
+	Name        string          `json:"name,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"`
+	Strict      *bool           `json:"strict,omitempty"`
 }
 
 type ResponsesReasoning struct {
@@ -927,6 +1005,9 @@ type ResponsesStreamEvent struct {
 	Arguments      string                `json:"arguments,omitempty"`
 	SummaryIndex   *int                  `json:"summary_index,omitempty"`
 	Part           *ResponsesContentPart `json:"part,omitempty"`
+	// Annotation fields for response.output_text.annotation.added
+	AnnotationIndex *int                 `json:"annotation_index,omitempty"`
+	Annotation      *ResponsesAnnotation `json:"annotation,omitempty"`
 }
 
 type ResponsesContentPart struct {
@@ -1001,6 +1082,18 @@ func convertToInternalRequest(req *ResponsesRequest) (*model.InternalLLMRequest,
 		chatReq.ResponseFormat = &model.ResponseFormat{
 			Type: req.Text.Format.Type,
 		}
+		if req.Text.Format.Type == "json_schema" {
+			jsonSchema, err := json.Marshal(model.JSONSchemaFormat{
+				Name:        req.Text.Format.Name,
+				Description: req.Text.Format.Description,
+				Schema:      req.Text.Format.Schema,
+				Strict:      req.Text.Format.Strict,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal json schema: %w", err)
+			}
+			chatReq.ResponseFormat.JSONSchema = jsonSchema
+		}
 	}
 
 	return chatReq, nil
@@ -1097,6 +1190,24 @@ func convertItemToMessage(item *ResponsesItem) (*model.Message, error) {
 		}
 		return nil, nil
 
+	case "input_file":
+		return &model.Message{
+			Role: lo.Ternary(item.Role != "", item.Role, "user"),
+			Content: model.MessageContent{
+				MultipleContent: []model.MessageContentPart{
+					{
+						Type: "file",
+						File: &model.File{
+							Filename: item.Filename,
+							FileData: item.FileData,
+							FileURL:  item.FileURL,
+							FileID:   item.FileID,
+						},
+					},
+				},
+			},
+		}, nil
+
 	case "function_call":
 		return &model.Message{
 			Role: "assistant",
@@ -1169,6 +1280,16 @@ func convertInputToMessageContent(input ResponsesInput) model.MessageContent {
 					},
 				})
 			}
+		case "input_file":
+			parts = append(parts, model.MessageContentPart{
+				Type: "file",
+				File: &model.File{
+					Filename: item.Filename,
+					FileData: item.FileData,
+					FileURL:  item.FileURL,
+					FileID:   item.FileID,
+				},
+			})
 		}
 	}
 
@@ -1230,12 +1351,54 @@ func convertToolsToInternal(tools []ResponsesTool) ([]model.Tool, error) {
 					OutputCompression: tool.OutputCompression,
 				},
 			})
+
+		case "web_search", "web_search_preview", "web_search_2025_08_26", "web_search_preview_2025_03_11":
+			webSearch := &model.WebSearch{SearchContextSize: tool.SearchContextSize}
+			if tool.Filters != nil {
+				webSearch.AllowedDomains = tool.Filters.AllowedDomains
+			}
+			if loc := tool.UserLocation; loc != nil {
+				webSearch.UserLocation = &model.WebSearchUserLocation{
+					City:     loc.City,
+					Region:   loc.Region,
+					Country:  loc.Country,
+					Timezone: loc.Timezone,
+				}
+			}
+			result = append(result, model.Tool{
+				Type:      "web_search",
+				WebSearch: webSearch,
+			})
+
+		case "code_interpreter":
+			result = append(result, model.Tool{
+				Type: "code_execution",
+			})
 		}
 	}
 
 	return result, nil
 }
 
+// convertAnnotationsToResponses 将内部引用转换为 url_citation，offset 为所在文本在整条回复中的起始字符位置
+func convertAnnotationsToResponses(annotations []model.Annotation, offset int) []ResponsesAnnotation {
+	result := make([]ResponsesAnnotation, 0, len(annotations))
+	for _, annotation := range annotations {
+		citation := annotation.URLCitation
+		if annotation.Type != "url_citation" || citation == nil {
+			continue
+		}
+		result = append(result, ResponsesAnnotation{
+			Type:       "url_citation",
+			StartIndex: lo.ToPtr(max(citation.StartIndex-offset, 0)),
+			EndIndex:   lo.ToPtr(max(citation.EndIndex-offset, 0)),
+			URL:        lo.ToPtr(citation.URL),
+			Title:      lo.ToPtr(citation.Title),
+		})
+	}
+	return result
+}
+
 func convertToResponsesAPIResponse(resp *model.InternalLLMResponse) *ResponsesResponse {
 	result := &ResponsesResponse{
 		Object:    "response",
@@ -1303,7 +1466,7 @@ func convertToResponsesAPIResponse(resp *model.InternalLLMResponse) *ResponsesRe
 						{
 							Type:        "output_text",
 							Text:        &text,
-							Annotations: &[]ResponsesAnnotation{},
+							Annotations: lo.ToPtr(convertAnnotationsToResponses(message.Annotations, 0)),
 						},
 					},
 				},
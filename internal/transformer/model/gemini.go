@@ -45,6 +45,23 @@ type GeminiPart struct {
 
 	// ThoughtSignature is an opaque signature for the thought
 	ThoughtSignature string `json:"thoughtSignature,omitempty"`
+
+	// ExecutableCode and CodeExecutionResult are generated by the code execution tool
+	ExecutableCode      *GeminiExecutableCode      `json:"executableCode,omitempty"`
+	CodeExecutionResult *GeminiCodeExecutionResult `json:"codeExecutionResult,omitempty"`
+}
+
+// GeminiExecutableCode represents code generated by the model to be executed
+type GeminiExecutableCode struct {
+	Language string `json:"language,omitempty"`
+	Code     string `json:"code"`
+}
+
+// GeminiCodeExecutionResult represents the result of executing the code
+type GeminiCodeExecutionResult struct {
+	// Outcome is one of OUTCOME_OK, OUTCOME_FAILED, OUTCOME_DEADLINE_EXCEEDED
+	Outcome string `json:"outcome,omitempty"`
+	Output  string `json:"output,omitempty"`
 }
 
 // GeminiBlob represents inline binary data
@@ -81,6 +98,7 @@ type GeminiFunctionResponse struct {
 type GeminiTool struct {
 	FunctionDeclarations []*GeminiFunctionDeclaration `json:"functionDeclarations,omitempty"`
 	CodeExecution        *GeminiCodeExecution         `json:"codeExecution,omitempty"`
+	GoogleSearch         *GeminiGoogleSearch          `json:"googleSearch,omitempty"`
 }
 
 // GeminiFunctionDeclaration describes a function that can be called
@@ -93,31 +111,25 @@ type GeminiFunctionDeclaration struct {
 // GeminiCodeExecution represents code execution capability
 type GeminiCodeExecution struct{}
 
+// GeminiGoogleSearch represents Google Search grounding capability
+type GeminiGoogleSearch struct{}
+
 // GeminiGenerationConfig controls generation parameters
 type GeminiGenerationConfig struct {
-	Temperature        *float64      `json:"temperature,omitempty"`
-	TopP               *float64      `json:"topP,omitempty"`
-	TopK               *int          `json:"topK,omitempty"`
-	CandidateCount     int           `json:"candidateCount,omitempty"`
-	MaxOutputTokens    int           `json:"maxOutputTokens,omitempty"`
-	StopSequences      []string      `json:"stopSequences,omitempty"`
-	ResponseMimeType   string        `json:"responseMimeType,omitempty"`
-	ResponseSchema     *GeminiSchema `json:"responseSchema,omitempty"`
-	ResponseModalities []string      `json:"responseModalities,omitempty"`
+	Temperature        *float64       `json:"temperature,omitempty"`
+	TopP               *float64       `json:"topP,omitempty"`
+	TopK               *int           `json:"topK,omitempty"`
+	CandidateCount     int            `json:"candidateCount,omitempty"`
+	MaxOutputTokens    int            `json:"maxOutputTokens,omitempty"`
+	StopSequences      []string       `json:"stopSequences,omitempty"`
+	ResponseMimeType   string         `json:"responseMimeType,omitempty"`
+	ResponseSchema     map[string]any `json:"responseSchema,omitempty"`
+	ResponseModalities []string       `json:"responseModalities,omitempty"`
 
 	// ThinkingConfig is the thinking features configuration
 	ThinkingConfig *GeminiThinkingConfig `json:"thinkingConfig,omitempty"`
 }
 
-// GeminiSchema for structured output
-type GeminiSchema struct {
-	Type       string                   `json:"type"`
-	Properties map[string]*GeminiSchema `json:"properties,omitempty"`
-	Items      *GeminiSchema            `json:"items,omitempty"`
-	Required   []string                 `json:"required,omitempty"`
-	Enum       []string                 `json:"enum,omitempty"`
-}
-
 // GeminiThinkingConfig is the thinking features configuration
 type GeminiThinkingConfig struct {
 	// IncludeThoughts indicates whether to include thoughts in the response
@@ -150,6 +162,39 @@ type GeminiCandidate struct {
 	FinishReason  *string               `json:"finishReason,omitempty"`
 	Index         int                   `json:"index"`
 	SafetyRatings []*GeminiSafetyRating `json:"safetyRatings,omitempty"`
+
+	// GroundingMetadata is returned when the Google Search tool is used
+	GroundingMetadata *GeminiGroundingMetadata `json:"groundingMetadata,omitempty"`
+}
+
+// GeminiGroundingMetadata contains the sources used to ground the response
+type GeminiGroundingMetadata struct {
+	WebSearchQueries  []string                  `json:"webSearchQueries,omitempty"`
+	GroundingChunks   []*GeminiGroundingChunk   `json:"groundingChunks,omitempty"`
+	GroundingSupports []*GeminiGroundingSupport `json:"groundingSupports,omitempty"`
+}
+
+// GeminiGroundingChunk is a source of the grounding
+type GeminiGroundingChunk struct {
+	Web *GeminiGroundingWeb `json:"web,omitempty"`
+}
+
+type GeminiGroundingWeb struct {
+	URI   string `json:"uri"`
+	Title string `json:"title,omitempty"`
+}
+
+// GeminiGroundingSupport links a segment of the response to the grounding chunks
+type GeminiGroundingSupport struct {
+	Segment               *GeminiSegment `json:"segment,omitempty"`
+	GroundingChunkIndices []int          `json:"groundingChunkIndices,omitempty"`
+}
+
+// GeminiSegment is a segment of the response text, indices are byte offsets
+type GeminiSegment struct {
+	StartIndex int    `json:"startIndex,omitempty"`
+	EndIndex   int    `json:"endIndex,omitempty"`
+	Text       string `json:"text,omitempty"`
 }
 
 // GeminiSafetyRating represents content safety evaluation
@@ -2,6 +2,8 @@ package model
 
 import (
 	"context"
+	"io"
+	"iter"
 	"net/http"
 )
 
@@ -32,6 +34,38 @@ type Outbound interface {
 	TransformStream(ctx context.Context, eventData []byte) (*InternalLLMResponse, error)
 }
 
+// StreamHeartbeat 为入站的可选接口，实现后等待首个 Token 时可发送协议自身的心跳事件
+type StreamHeartbeat interface {
+	// 返回一条完整的 SSE 心跳事件
+	StreamHeartbeat() []byte
+}
+
+// StreamError 为入站的可选接口，实现后在已向客户端发送 SSE 响应头后，按入站协议返回一条错误事件
+type StreamError interface {
+	StreamError(statusCode int, message string) []byte
+}
+
+// OutboundConfigurable 为出站的可选接口，实现后在构建请求前接收渠道的附加配置
+type OutboundConfigurable interface {
+	SetChannelOptions(options *ChannelOptions)
+}
+
+// OutboundHTTPClient 为出站的可选接口，构建请求时需要额外访问网络（如换取令牌）的出站实现后接收渠道的 HTTP 客户端
+type OutboundHTTPClient interface {
+	SetHTTPClient(client *http.Client)
+}
+
+// StreamDecoder 为出站的可选接口，上游流式响应不是 SSE 时实现，将响应体解码为逐条事件数据
+// 解码出的每条数据会交给 TransformStream 处理
+type StreamDecoder interface {
+	DecodeStream(body io.Reader) iter.Seq2[[]byte, error]
+}
+
+// OutboundTransport 为出站的可选接口，实现后请求不经过网络，由出站自行生成上游响应
+type OutboundTransport interface {
+	RoundTrip(req *http.Request) (*http.Response, error)
+}
+
 /*
 请求流程
 非流式
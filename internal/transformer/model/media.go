@@ -0,0 +1,94 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/bestruirui/octopus/internal/utils/media"
+)
+
+// RemoteMedia 需要内联的远程内容类型
+type RemoteMedia int
+
+const (
+	// RemoteMediaImage image_url 中的远程图片
+	RemoteMediaImage RemoteMedia = 1 << iota
+	// RemoteMediaFile file 中的远程文档
+	RemoteMediaFile
+)
+
+// InlineRemoteMedia 下载消息中的远程图片和文档并替换为 data URL
+// 只替换消息的副本，调用方需先复制请求，重试其他渠道时原请求保持不变
+func (r *InternalLLMRequest) InlineRemoteMedia(ctx context.Context, kinds RemoteMedia) error {
+	cloned := false
+	for i := range r.Messages {
+		partsCloned := false
+		for j, part := range r.Messages[i].Content.MultipleContent {
+			remoteURL := part.remoteURL(kinds)
+			if remoteURL == "" {
+				continue
+			}
+			fetched, err := media.Fetch(ctx, remoteURL)
+			if err != nil {
+				return fmt.Errorf("failed to inline remote media: %w", err)
+			}
+			if !cloned {
+				r.Messages = slices.Clone(r.Messages)
+				cloned = true
+			}
+			if !partsCloned {
+				r.Messages[i].Content.MultipleContent = slices.Clone(r.Messages[i].Content.MultipleContent)
+				partsCloned = true
+			}
+			r.Messages[i].Content.MultipleContent[j] = part.inline(remoteURL, fetched)
+		}
+	}
+	return nil
+}
+
+func (p MessageContentPart) remoteURL(kinds RemoteMedia) string {
+	switch {
+	case p.Type == "image_url" && kinds&RemoteMediaImage != 0 && p.ImageURL != nil && media.IsRemoteURL(p.ImageURL.URL):
+		return p.ImageURL.URL
+	case p.Type == "file" && kinds&RemoteMediaFile != 0 && p.File != nil && p.File.FileData == "" && media.IsRemoteURL(p.File.FileURL):
+		return p.File.FileURL
+	}
+	return ""
+}
+
+// inline 返回替换为 data URL 的内容，image_url 实际为 PDF 时转换为 file
+func (p MessageContentPart) inline(remoteURL string, fetched *media.Media) MessageContentPart {
+	if p.Type == "image_url" && strings.HasPrefix(fetched.MimeType, "image/") {
+		imageURL := *p.ImageURL
+		imageURL.URL = fetched.DataURL()
+		p.ImageURL = &imageURL
+		return p
+	}
+
+	var file File
+	if p.File != nil {
+		file = *p.File
+	}
+	if file.Filename == "" {
+		file.Filename = filenameFromURL(remoteURL)
+	}
+	file.FileData = fetched.DataURL()
+	file.FileURL = ""
+	return MessageContentPart{Type: "file", File: &file, CacheControl: p.CacheControl}
+}
+
+func filenameFromURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(parsed.Path)
+	if name == "/" || name == "." {
+		return ""
+	}
+	return name
+}
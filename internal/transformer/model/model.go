@@ -42,6 +42,9 @@ type InternalLLMRequest struct {
 	// EmbeddingEncodingFormat is the format of the embedding output.
 	// Can be "float" or "base64". Defaults to "float".
 	EmbeddingEncodingFormat *string `json:"embedding_encoding_format,omitempty"`
+	// EmbeddingTaskType is the intended use of the embedding, passed through to providers that support it.
+	// e.g. Gemini "RETRIEVAL_QUERY", Cohere / Voyage "search_query".
+	EmbeddingTaskType *string `json:"embedding_task_type,omitempty"`
 
 	// Model is the model ID used to generate the response.
 	Model string `json:"model" validator:"required"`
@@ -77,8 +80,8 @@ type InternalLLMRequest struct {
 	// How many chat completion choices to generate for each input message. Note that
 	// you will be charged based on the number of generated tokens across all of the
 	// choices. Keep `n` as `1` to minimize costs.
-	// NOTE: Not supported, always 1.
-	// N *int64 `json:"n,omitempty"`
+	// 上游不支持时由中转拆分为多个并发请求后合并
+	N *int64 `json:"n,omitempty"`
 
 	// Number between -2.0 and 2.0. Positive values penalize new tokens based on
 	// whether they appear in the text so far, increasing the model's likelihood to
@@ -179,6 +182,10 @@ type InternalLLMRequest struct {
 	// Help fields， will not be sent to the llm service.
 	ReasoningBudget *int64 `json:"-"`
 
+	// ReasoningMapping is the reasoning effort mapping rule matched by the model and channel type.
+	// Help fields， will not be sent to the llm service.
+	ReasoningMapping *ReasoningMapping `json:"-"`
+
 	// EnableThinking is used by Alibaba Qwen models to enable thinking/reasoning output.
 	EnableThinking *bool `json:"enable_thinking,omitempty"`
 
@@ -206,6 +213,10 @@ type InternalLLMRequest struct {
 	Tools             []Tool      `json:"tools,omitempty"`
 	ToolChoice        *ToolChoice `json:"tool_choice,omitempty"`
 
+	// WebSearchOptions is the OpenAI Chat Completions web search configuration.
+	// Inbound converts it to a web_search tool, the OpenAI chat outbound converts it back.
+	WebSearchOptions *WebSearchOptions `json:"web_search_options,omitempty"`
+
 	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
 
 	// Help fields， will not be sent to the llm service.
@@ -235,6 +246,9 @@ type InternalLLMRequest struct {
 	Query url.Values `json:"-"`
 }
 
+// MaxChoices 单次请求 n 的上限，与 OpenAI 一致
+const MaxChoices = 128
+
 func (r *InternalLLMRequest) Validate() error {
 	if r.Model == "" {
 		return errors.New("model is required")
@@ -263,6 +277,9 @@ func (r *InternalLLMRequest) Validate() error {
 	if isChatRequest && len(r.Messages) == 0 {
 		return errors.New("messages are required")
 	}
+	if r.N != nil && (*r.N < 1 || *r.N > MaxChoices) {
+		return fmt.Errorf("n must be between 1 and %d", MaxChoices)
+	}
 
 	return nil
 }
@@ -287,6 +304,29 @@ func (r *InternalLLMRequest) ClearHelpFields() {
 	r.Include = nil
 }
 
+// ApplyWebSearchOptions 将 OpenAI Chat 的 web_search_options 转换为 web_search 内置工具
+func (r *InternalLLMRequest) ApplyWebSearchOptions() {
+	if r.WebSearchOptions == nil {
+		return
+	}
+	webSearch := &WebSearch{SearchContextSize: r.WebSearchOptions.SearchContextSize}
+	if location := r.WebSearchOptions.UserLocation; location != nil && location.Approximate != nil {
+		webSearch.UserLocation = location.Approximate
+	}
+	r.Tools = append(r.Tools, Tool{Type: "web_search", WebSearch: webSearch})
+	r.WebSearchOptions = nil
+}
+
+// BuiltinTool 返回指定类型的内置工具，不存在时返回 nil
+func (r *InternalLLMRequest) BuiltinTool(toolType string) *Tool {
+	for i := range r.Tools {
+		if r.Tools[i].Type == toolType {
+			return &r.Tools[i]
+		}
+	}
+	return nil
+}
+
 func (r *InternalLLMRequest) IsImageGenerationRequest() bool {
 	return len(r.Modalities) > 0 && slices.Contains(r.Modalities, "image")
 }
@@ -361,6 +401,9 @@ type Message struct {
 	ToolCallIsError *bool      `json:"-"`
 	ToolCalls       []ToolCall `json:"tool_calls,omitempty"`
 
+	// Annotations of the message content, e.g. the citations of the web search.
+	Annotations []Annotation `json:"annotations,omitempty"`
+
 	// Images is used by some providers (e.g., Gemini via OpenAI compat) for image generation responses.
 	// Images will be merged into Content.MultipleContent during response processing.
 	Images []MessageContentPart `json:"images,omitempty"`
@@ -488,16 +531,21 @@ type Audio struct {
 
 type File struct {
 	// The filename of the file.
-	Filename string `json:"filename"`
-	// The base64 encoded data of the file.
-	FileData string `json:"file_data"`
+	Filename string `json:"filename,omitempty"`
+	// The base64 encoded data of the file, as a data URL.
+	FileData string `json:"file_data,omitempty"`
+	// The ID of an uploaded file.
+	FileID string `json:"file_id,omitempty"`
+	// FileURL is the remote URL of the file, e.g. from Responses input_file or Anthropic document url source.
+	// Outbounds that cannot reference remote files inline it via InlineRemoteMedia.
+	FileURL string `json:"file_url,omitempty"`
 }
 
 // ResponseFormat specifies the format of the response.
 type ResponseFormat struct {
 	// Any of "json_schema", "json_object", "text".
 	Type string `json:"type"`
-	// TODO: Schema
+	// JSONSchema is the json_schema of the response format, see JSONSchemaFormat.
 	JSONSchema json.RawMessage `json:"json_schema,omitempty"`
 }
 
@@ -651,6 +699,49 @@ func (u *Usage) GetPromptTokens() *int64 {
 	return &u.PromptTokens
 }
 
+// Add 累加另一次请求的用量，用于合并拆分后并发请求的结果
+func (u *Usage) Add(other *Usage) {
+	if other == nil {
+		return
+	}
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+	u.CacheCreationInputTokens += other.CacheCreationInputTokens
+	u.AnthropicUsage = u.AnthropicUsage || other.AnthropicUsage
+
+	if other.PromptTokensDetails != nil {
+		if u.PromptTokensDetails == nil {
+			u.PromptTokensDetails = &PromptTokensDetails{}
+		}
+		u.PromptTokensDetails.AudioTokens += other.PromptTokensDetails.AudioTokens
+		u.PromptTokensDetails.CachedTokens += other.PromptTokensDetails.CachedTokens
+	}
+	if other.CompletionTokensDetails != nil {
+		if u.CompletionTokensDetails == nil {
+			u.CompletionTokensDetails = &CompletionTokensDetails{}
+		}
+		u.CompletionTokensDetails.AudioTokens += other.CompletionTokensDetails.AudioTokens
+		u.CompletionTokensDetails.ReasoningTokens += other.CompletionTokensDetails.ReasoningTokens
+		u.CompletionTokensDetails.AcceptedPredictionTokens += other.CompletionTokensDetails.AcceptedPredictionTokens
+		u.CompletionTokensDetails.RejectedPredictionTokens += other.CompletionTokensDetails.RejectedPredictionTokens
+	}
+	u.PromptModalityTokenDetails = addModalityTokens(u.PromptModalityTokenDetails, other.PromptModalityTokenDetails)
+	u.CompletionModalityTokenDetails = addModalityTokens(u.CompletionModalityTokenDetails, other.CompletionModalityTokenDetails)
+}
+
+func addModalityTokens(total, other []ModalityTokenCount) []ModalityTokenCount {
+	for _, count := range other {
+		index := slices.IndexFunc(total, func(c ModalityTokenCount) bool { return c.Modality == count.Modality })
+		if index < 0 {
+			total = append(total, count)
+			continue
+		}
+		total[index].TokenCount += count.TokenCount
+	}
+	return total
+}
+
 // CompletionTokensDetails Breakdown of tokens used in a completion.
 type CompletionTokensDetails struct {
 	AudioTokens              int64 `json:"audio_tokens"`
@@ -719,10 +810,12 @@ type ModalityTokenCount struct {
 // Tool represents a function tool.
 type Tool struct {
 	// Type is the type of the tool.
-	// Any of "function", "image_generation".
+	// Any of "function", "image_generation", "web_search", "code_execution".
+	// web_search 和 code_execution 为服务商内置工具，由各出站映射为对应的格式
 	Type            string           `json:"type"`
 	Function        Function         `json:"function"`
 	ImageGeneration *ImageGeneration `json:"image_generation,omitempty"`
+	WebSearch       *WebSearch       `json:"web_search,omitempty"`
 
 	// CacheControl is used for provider-specific cache control (e.g., Anthropic).
 	// This field is not serialized in JSON.
@@ -737,6 +830,11 @@ type CacheControl struct {
 	TTL  string `json:"-"`
 }
 
+// IsBuiltin 是否为服务商内置工具（联网搜索、代码执行）
+func (t Tool) IsBuiltin() bool {
+	return t.Type == "web_search" || t.Type == "code_execution"
+}
+
 type toolJSONMarshaller Tool
 
 func (t Tool) MarshalJSON() ([]byte, error) {
@@ -744,6 +842,7 @@ func (t Tool) MarshalJSON() ([]byte, error) {
 	m := toolJSONMarshaller(t)
 	// ImageGeneration is not a valid field for chat completion, so we should remove it from the request.
 	m.ImageGeneration = nil
+	m.WebSearch = nil
 
 	return json.Marshal(m)
 }
@@ -860,6 +959,56 @@ type ImageGeneration struct {
 	Watermark bool `json:"watermark,omitempty"`
 }
 
+// WebSearch 内置联网搜索工具的参数，合并了各服务商支持的字段
+// 出站只取目标服务商支持的部分，其余字段忽略
+type WebSearch struct {
+	// MaxUses limits the number of searches per request (Anthropic).
+	MaxUses *int64 `json:"max_uses,omitempty"`
+	// AllowedDomains restricts the search results to these domains.
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+	// BlockedDomains excludes these domains from the search results (Anthropic).
+	BlockedDomains []string `json:"blocked_domains,omitempty"`
+	// One of low, medium, high (OpenAI).
+	SearchContextSize string `json:"search_context_size,omitempty"`
+	// UserLocation is the approximate location of the user.
+	UserLocation *WebSearchUserLocation `json:"user_location,omitempty"`
+}
+
+type WebSearchUserLocation struct {
+	City     string `json:"city,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Country  string `json:"country,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// WebSearchOptions is the web_search_options of the OpenAI Chat Completions API.
+type WebSearchOptions struct {
+	SearchContextSize string                        `json:"search_context_size,omitempty"`
+	UserLocation      *WebSearchOptionsUserLocation `json:"user_location,omitempty"`
+}
+
+type WebSearchOptionsUserLocation struct {
+	// Always "approximate".
+	Type        string                 `json:"type"`
+	Approximate *WebSearchUserLocation `json:"approximate,omitempty"`
+}
+
+// Annotation is the annotation of the message content.
+type Annotation struct {
+	// Always "url_citation".
+	Type        string       `json:"type"`
+	URLCitation *URLCitation `json:"url_citation,omitempty"`
+}
+
+// URLCitation is a citation of a web resource.
+// StartIndex and EndIndex are the character (rune) offsets of the cited text in the message content.
+type URLCitation struct {
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+}
+
 // EmbeddingInput represents the input for embedding requests.
 // It can be a single string or an array of strings.
 type EmbeddingInput struct {
@@ -879,6 +1028,14 @@ func (i EmbeddingInput) MarshalJSON() ([]byte, error) {
 	return []byte("null"), nil
 }
 
+// Texts returns the input as a list of texts.
+func (i EmbeddingInput) Texts() []string {
+	if i.Single != nil {
+		return []string{*i.Single}
+	}
+	return i.Multiple
+}
+
 func (i *EmbeddingInput) UnmarshalJSON(data []byte) error {
 	var str string
 
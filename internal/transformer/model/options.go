@@ -0,0 +1,136 @@
+package model
+
+import (
+	"slices"
+
+	"github.com/bestruirui/octopus/internal/utils/xstrings"
+)
+
+// ChannelOptions 渠道的附加配置，仅部分出站类型需要
+type ChannelOptions struct {
+	// 通用
+
+	// EmulateTools 通过提示词模拟工具调用的模型，支持 * 通配符，用于不支持函数调用的模型
+	EmulateTools []string `json:"emulate_tools,omitempty"`
+	// ThinkTags 推理内容以 <think>...</think> 内联在正文开头的模型，支持 * 通配符
+	// 仅 OpenAI Chat 兼容渠道生效，内置的 defaultThinkTagModels 始终启用
+	ThinkTags []string `json:"think_tags,omitempty"`
+
+	// Azure OpenAI
+
+	// APIVersion 请求附带的 api-version，为空时使用出站的默认版本
+	APIVersion string `json:"api_version,omitempty"`
+	// API 上游使用的接口: chat 或 responses, 默认 chat
+	API string `json:"api,omitempty"`
+	// Deployments 模型名到部署名的映射，未配置的模型直接以模型名作为部署名
+	Deployments map[string]string `json:"deployments,omitempty"`
+
+	// Vertex AI
+
+	// Region 区域，如 us-central1、global，默认 us-central1
+	Region string `json:"region,omitempty"`
+	// ProjectID 项目 ID，为空时使用服务账号中的 project_id
+	ProjectID string `json:"project_id,omitempty"`
+	// TokenURL 换取访问令牌的地址，为空时使用服务账号中的 token_uri
+	TokenURL string `json:"token_url,omitempty"`
+
+	// Ollama
+
+	// KeepAlive 模型在内存中保留的时间，如 "5m"、-1
+	KeepAlive any `json:"keep_alive,omitempty"`
+	// ModelOptions 默认透传给 Ollama 的 options，如 num_ctx
+	ModelOptions map[string]any `json:"model_options,omitempty"`
+
+	// 自定义渠道
+
+	// Custom 自定义渠道的请求模板与响应提取规则
+	Custom *CustomOptions `json:"custom,omitempty"`
+
+	// 模拟渠道
+
+	// Mock 模拟渠道的延迟、输出和错误注入配置
+	Mock *MockOptions `json:"mock,omitempty"`
+}
+
+// defaultThinkTagModels 通过兼容接口部署时常以 <think> 标签输出推理内容的开源推理模型
+var defaultThinkTagModels = []string{"*deepseek-r1*", "*qwq*", "*qwen3*"}
+
+// ExtractsThinkTags 判断是否需要从该模型的正文中提取 <think> 标签内的推理内容
+func (o *ChannelOptions) ExtractsThinkTags(modelName string) bool {
+	match := func(pattern string) bool {
+		return xstrings.MatchWildcard(pattern, modelName)
+	}
+	if slices.ContainsFunc(defaultThinkTagModels, match) {
+		return true
+	}
+	return o != nil && slices.ContainsFunc(o.ThinkTags, match)
+}
+
+// EmulatesTools 判断渠道是否需要为该模型模拟工具调用
+func (o *ChannelOptions) EmulatesTools(modelName string) bool {
+	if o == nil {
+		return false
+	}
+	return slices.ContainsFunc(o.EmulateTools, func(pattern string) bool {
+		return xstrings.MatchWildcard(pattern, modelName)
+	})
+}
+
+// CustomOptions 自定义渠道配置
+// 模板使用 text/template 语法，可用字段见 custom 出站的 templateData
+type CustomOptions struct {
+	// Method 请求方法，默认 POST
+	Method string `json:"method,omitempty"`
+	// URL 请求地址模板，默认 {{.BaseURL}}
+	URL string `json:"url,omitempty"`
+	// Headers 请求头模板，如 {"Authorization": "Bearer {{.Key}}"}
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body 请求体模板，为空时发送 OpenAI Chat 格式的请求体
+	Body string `json:"body,omitempty"`
+	// StreamFormat 流式响应格式: sse 或 ndjson, 默认 sse
+	StreamFormat string `json:"stream_format,omitempty"`
+	// StreamDone 流结束标记，事件数据等于该值时结束，默认 [DONE]
+	StreamDone string `json:"stream_done,omitempty"`
+	// Response 非流式响应的提取规则
+	Response CustomExtract `json:"response"`
+	// Stream 流式响应每个事件的提取规则
+	Stream CustomExtract `json:"stream"`
+}
+
+// CustomExtract 从上游 JSON 中提取字段的路径，形如 choices.0.message.content 或 $.output[0].text
+type CustomExtract struct {
+	ID               string `json:"id,omitempty"`
+	Content          string `json:"content,omitempty"`
+	Reasoning        string `json:"reasoning,omitempty"`
+	FinishReason     string `json:"finish_reason,omitempty"`
+	PromptTokens     string `json:"prompt_tokens,omitempty"`
+	CompletionTokens string `json:"completion_tokens,omitempty"`
+	TotalTokens      string `json:"total_tokens,omitempty"`
+	// Error 错误信息路径，提取到非空值时视为上游错误
+	Error string `json:"error,omitempty"`
+	// ToolCalls 工具调用数组路径，以下三个路径相对于数组中的每个元素
+	ToolCalls         string `json:"tool_calls,omitempty"`
+	ToolCallID        string `json:"tool_call_id,omitempty"`
+	ToolCallName      string `json:"tool_call_name,omitempty"`
+	ToolCallArguments string `json:"tool_call_arguments,omitempty"`
+}
+
+// MockOptions 模拟渠道配置，错误注入概率取值 0-1
+type MockOptions struct {
+	// LatencyMs 返回响应头前的延迟（毫秒）
+	LatencyMs int `json:"latency_ms,omitempty"`
+	// FirstTokenDelayMs 流式响应首个 Token 前的额外延迟（毫秒）
+	FirstTokenDelayMs int `json:"first_token_delay_ms,omitempty"`
+	// TokensPerSecond 流式输出速度，0 表示不限速
+	TokensPerSecond float64 `json:"tokens_per_second,omitempty"`
+	// Response 固定返回的内容，为空时回显最后一条用户消息
+	Response string `json:"response,omitempty"`
+	// RateLimitRate 返回 429 的概率
+	RateLimitRate float64 `json:"rate_limit_rate,omitempty"`
+	// ServerErrorRate 返回 500 的概率
+	ServerErrorRate float64 `json:"server_error_rate,omitempty"`
+	// TimeoutRate 一直不返回响应直到请求被取消的概率
+	TimeoutRate float64 `json:"timeout_rate,omitempty"`
+	// DropRate 流式输出中途断开的概率
+	DropRate float64 `json:"drop_rate,omitempty"`
+}
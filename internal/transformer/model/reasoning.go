@@ -0,0 +1,140 @@
+package model
+
+import (
+	"slices"
+	"sync/atomic"
+
+	"github.com/bestruirui/octopus/internal/utils/xstrings"
+)
+
+// reasoningEffortLevels 推理强度从低到高的顺序，用于由思考预算反推推理强度
+var reasoningEffortLevels = []string{"minimal", "low", "medium", "high", "xhigh"}
+
+// ReasoningMapping 推理强度与思考预算的映射规则
+// 出站按模型和渠道类型匹配规则，将 reasoning_effort 转换为上游的思考预算或开关；
+// 入站（如 Anthropic 的 budget_tokens）按渠道类型为空的规则反推推理强度
+type ReasoningMapping struct {
+	// ModelPattern 模型名匹配规则，支持 * 通配符，不区分大小写，为空匹配所有模型
+	ModelPattern string `json:"model_pattern"`
+	// ChannelType 适用的渠道类型，为空匹配所有渠道类型及入站
+	ChannelType *int `json:"channel_type"`
+	// Budgets 推理强度到思考预算（Token）的映射
+	// 0 表示关闭思考（如 none、minimal），-1 表示开启思考但由上游动态决定预算
+	Budgets map[string]int64 `json:"budgets" gorm:"serializer:json"`
+	// DefaultBudget 未在 Budgets 中列出的推理强度使用的预算
+	DefaultBudget int64 `json:"default_budget"`
+	// Unsupported 上游不支持推理强度参数，出站时丢弃 reasoning_effort，仅保留思考开关
+	Unsupported bool `json:"unsupported"`
+	// EnableThinking 请求推理时附加 enable_thinking 开关，预算为 0 时关闭（如通义千问）
+	EnableThinking bool `json:"enable_thinking"`
+}
+
+// defaultReasoningMapping 未匹配任何规则时使用的映射
+var defaultReasoningMapping = ReasoningMapping{
+	Budgets: map[string]int64{
+		"none":    0,
+		"minimal": 0,
+		"low":     5000,
+		"medium":  15000,
+		"high":    30000,
+	},
+	DefaultBudget: 15000,
+}
+
+var (
+	// reasoningMappings 管理员配置的规则，按优先级排列
+	reasoningMappings atomic.Pointer[[]ReasoningMapping]
+	// builtinReasoningMappings 出站注册的内置规则，排在配置的规则之后
+	builtinReasoningMappings atomic.Pointer[[]ReasoningMapping]
+)
+
+// SetReasoningMappings 替换管理员配置的映射规则，规则按顺序匹配
+func SetReasoningMappings(mappings []ReasoningMapping) {
+	reasoningMappings.Store(&mappings)
+}
+
+// SetBuiltinReasoningMappings 设置内置的映射规则，在配置的规则均未匹配时使用
+func SetBuiltinReasoningMappings(mappings []ReasoningMapping) {
+	builtinReasoningMappings.Store(&mappings)
+}
+
+// BuiltinReasoningMappings 返回内置的映射规则，最后一条为兜底规则
+func BuiltinReasoningMappings() []ReasoningMapping {
+	var result []ReasoningMapping
+	if builtin := builtinReasoningMappings.Load(); builtin != nil {
+		result = append(result, *builtin...)
+	}
+	return append(result, defaultReasoningMapping)
+}
+
+// LookupReasoningMapping 返回第一条匹配模型和渠道类型的规则
+// channelType 小于 0 表示入站，仅匹配渠道类型为空的规则
+func LookupReasoningMapping(modelName string, channelType int) *ReasoningMapping {
+	for _, mappings := range []*[]ReasoningMapping{reasoningMappings.Load(), builtinReasoningMappings.Load()} {
+		if mappings == nil {
+			continue
+		}
+		for i := range *mappings {
+			if (*mappings)[i].Match(modelName, channelType) {
+				return &(*mappings)[i]
+			}
+		}
+	}
+	return &defaultReasoningMapping
+}
+
+// IsReasoningEffort 判断是否为有效的推理强度，none 表示关闭推理
+func IsReasoningEffort(effort string) bool {
+	return effort == "none" || slices.Contains(reasoningEffortLevels, effort)
+}
+
+// Match 判断规则是否适用于模型和渠道类型
+func (m *ReasoningMapping) Match(modelName string, channelType int) bool {
+	if m.ChannelType != nil && *m.ChannelType != channelType {
+		return false
+	}
+	return m.ModelPattern == "" || xstrings.MatchWildcard(m.ModelPattern, modelName)
+}
+
+// Budget 返回推理强度对应的思考预算
+func (m *ReasoningMapping) Budget(effort string) int64 {
+	if budget, ok := m.Budgets[effort]; ok {
+		return budget
+	}
+	return m.DefaultBudget
+}
+
+// Effort 返回预算不小于 budget 的最低推理强度，超过所有预算时返回最高的推理强度
+func (m *ReasoningMapping) Effort(budget int64) string {
+	var highest string
+	for _, level := range reasoningEffortLevels {
+		levelBudget, ok := m.Budgets[level]
+		if !ok || levelBudget <= 0 {
+			continue
+		}
+		if budget <= levelBudget {
+			return level
+		}
+		highest = level
+	}
+	if highest == "" {
+		return "medium"
+	}
+	return highest
+}
+
+// GetReasoningMapping 返回请求所用的映射规则，未由转发流程设置时按模型匹配入站规则
+func (r *InternalLLMRequest) GetReasoningMapping() *ReasoningMapping {
+	if r.ReasoningMapping != nil {
+		return r.ReasoningMapping
+	}
+	return LookupReasoningMapping(r.Model, -1)
+}
+
+// ReasoningBudgetTokens 返回请求的思考预算，显式指定的预算优先于推理强度的映射
+func (r *InternalLLMRequest) ReasoningBudgetTokens() int64 {
+	if r.ReasoningBudget != nil {
+		return *r.ReasoningBudget
+	}
+	return r.GetReasoningMapping().Budget(r.ReasoningEffort)
+}
@@ -0,0 +1,42 @@
+package model
+
+import "testing"
+
+func TestReasoningMapping_Effort(t *testing.T) {
+	mapping := defaultReasoningMapping
+	tests := []struct {
+		budget   int64
+		expected string
+	}{
+		{1024, "low"},
+		{5000, "low"},
+		{10000, "medium"},
+		{30000, "high"},
+		{100000, "high"},
+	}
+	for _, tt := range tests {
+		if got := mapping.Effort(tt.budget); got != tt.expected {
+			t.Errorf("Effort(%d) = %q, want %q", tt.budget, got, tt.expected)
+		}
+	}
+}
+
+func TestLookupReasoningMapping(t *testing.T) {
+	channelType := 2
+	SetReasoningMappings([]ReasoningMapping{
+		{ModelPattern: "qwen3-*", Budgets: map[string]int64{"low": 2048}, EnableThinking: true},
+		{ChannelType: &channelType, DefaultBudget: 4096},
+	})
+	defer SetReasoningMappings(nil)
+
+	if m := LookupReasoningMapping("Qwen3-32B", 0); !m.EnableThinking || m.Budget("low") != 2048 {
+		t.Errorf("expected qwen3 rule, got %+v", m)
+	}
+	if m := LookupReasoningMapping("claude-sonnet-4", channelType); m.Budget("high") != 4096 {
+		t.Errorf("expected channel type rule, got %+v", m)
+	}
+	// 入站仅匹配渠道类型为空的规则
+	if m := LookupReasoningMapping("claude-sonnet-4", -1); m != &defaultReasoningMapping {
+		t.Errorf("expected default rule, got %+v", m)
+	}
+}
@@ -0,0 +1,44 @@
+package model
+
+import "encoding/json"
+
+// JSONSchemaFormat is the json_schema of the response format.
+type JSONSchemaFormat struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"`
+	Strict      *bool           `json:"strict,omitempty"`
+}
+
+// anyObjectSchema is the schema used to emulate json_object, any JSON object is accepted.
+var anyObjectSchema = json.RawMessage(`{"type":"object"}`)
+
+// GetJSONSchema returns the parsed json_schema, nil if the format is not json_schema or the schema is invalid.
+func (f *ResponseFormat) GetJSONSchema() *JSONSchemaFormat {
+	if f == nil || f.Type != "json_schema" || len(f.JSONSchema) == 0 {
+		return nil
+	}
+	var format JSONSchemaFormat
+	if err := json.Unmarshal(f.JSONSchema, &format); err != nil {
+		return nil
+	}
+	return &format
+}
+
+// StructuredOutputSchema returns the schema the response content must follow.
+// json_schema returns its schema, json_object returns a schema accepting any object, otherwise nil.
+func (r *InternalLLMRequest) StructuredOutputSchema() json.RawMessage {
+	if r.ResponseFormat == nil {
+		return nil
+	}
+	switch r.ResponseFormat.Type {
+	case "json_schema":
+		if format := r.ResponseFormat.GetJSONSchema(); format != nil && len(format.Schema) > 0 {
+			return format.Schema
+		}
+		return anyObjectSchema
+	case "json_object":
+		return anyObjectSchema
+	}
+	return nil
+}
@@ -0,0 +1,383 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+const (
+	toolCallOpenTag      = "<tool_call>"
+	toolCallCloseTag     = "</tool_call>"
+	toolResponseOpenTag  = "<tool_response>"
+	toolResponseCloseTag = "</tool_response>"
+)
+
+const toolPromptTemplate = `# Tools
+
+You may call one or more of the following tools to help with the user's request. The tools are described as JSON below:
+<tools>
+%s
+</tools>
+
+To call a tool, reply with a <tool_call> block that contains a JSON object with the tool name and its arguments, for example:
+<tool_call>
+{"name": "tool_name", "arguments": {"argument": "value"}}
+</tool_call>
+Write one block per call to call several tools. After the tool calls stop replying and wait, the results are sent back to you inside <tool_response> blocks. Never write <tool_response> blocks yourself.`
+
+// emulatedToolCall 提示词中工具调用块的内容
+type emulatedToolCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// EmulateTools 返回用提示词模拟函数工具的请求副本，用于不支持函数调用的模型
+// 工具定义渲染到系统提示词中，历史消息中的工具调用和结果转换为 <tool_call>、<tool_response> 文本
+// 模型回复中的工具调用块由 ToolCallParser 解析
+func (r *InternalLLMRequest) EmulateTools() *InternalLLMRequest {
+	emulated := *r
+	emulated.Tools = slices.DeleteFunc(slices.Clone(r.Tools), func(tool Tool) bool { return tool.Type == "function" })
+	if len(emulated.Tools) == 0 {
+		emulated.Tools = nil
+	}
+	emulated.ToolChoice = nil
+	emulated.ParallelToolCalls = nil
+	emulated.Messages = emulateToolMessages(r.Messages)
+
+	if prompt := r.toolPrompt(); prompt != "" {
+		emulated.Messages = prependSystemPrompt(emulated.Messages, prompt)
+	}
+	return &emulated
+}
+
+// toolPrompt 根据函数工具和 tool_choice 生成系统提示词，tool_choice 为 none 时返回空
+func (r *InternalLLMRequest) toolPrompt() string {
+	var choice string
+	if r.ToolChoice != nil {
+		switch {
+		case r.ToolChoice.ToolChoice != nil:
+			choice = *r.ToolChoice.ToolChoice
+		case r.ToolChoice.NamedToolChoice != nil:
+			choice = r.ToolChoice.NamedToolChoice.Function.Name
+		}
+	}
+	if choice == "none" {
+		return ""
+	}
+
+	var definitions []string
+	for _, tool := range r.Tools {
+		if tool.Type != "function" {
+			continue
+		}
+		definition, err := json.Marshal(tool.Function)
+		if err != nil {
+			continue
+		}
+		definitions = append(definitions, string(definition))
+	}
+	if len(definitions) == 0 {
+		return ""
+	}
+
+	prompt := fmt.Sprintf(toolPromptTemplate, strings.Join(definitions, "\n"))
+	switch choice {
+	case "", "auto":
+	case "required":
+		prompt += "\nYou must call at least one tool in this reply."
+	default:
+		prompt += fmt.Sprintf("\nYou must call the %s tool in this reply.", choice)
+	}
+	if r.ParallelToolCalls != nil && !*r.ParallelToolCalls {
+		prompt += "\nCall at most one tool in this reply."
+	}
+	return prompt
+}
+
+// emulateToolMessages 将历史中的工具调用转为文本，连续的工具结果合并为一条用户消息
+func emulateToolMessages(messages []Message) []Message {
+	toolNames := make(map[string]string)
+	result := make([]Message, 0, len(messages))
+	for _, msg := range messages {
+		switch {
+		case msg.Role == "assistant" && len(msg.ToolCalls) > 0:
+			var sb strings.Builder
+			sb.WriteString(contentText(msg.Content))
+			for _, toolCall := range msg.ToolCalls {
+				toolNames[toolCall.ID] = toolCall.Function.Name
+				if sb.Len() > 0 {
+					sb.WriteString("\n")
+				}
+				sb.WriteString(renderToolCall(toolCall))
+			}
+			text := sb.String()
+			msg.Content = MessageContent{Content: &text}
+			msg.ToolCalls = nil
+			result = append(result, msg)
+		case msg.Role == "tool":
+			name := toolNames[lo.FromPtr(msg.ToolCallID)]
+			if name == "" && msg.ToolCallName != nil {
+				name = *msg.ToolCallName
+			}
+			response, _ := json.Marshal(map[string]string{"name": name, "content": contentText(msg.Content)})
+			text := toolResponseOpenTag + "\n" + string(response) + "\n" + toolResponseCloseTag
+
+			// 与上一条由工具结果转换的用户消息合并
+			if last := len(result) - 1; last >= 0 && result[last].Role == "user" && result[last].ToolCallID != nil {
+				merged := *result[last].Content.Content + "\n" + text
+				result[last].Content = MessageContent{Content: &merged}
+				continue
+			}
+			result = append(result, Message{Role: "user", Content: MessageContent{Content: &text}, ToolCallID: msg.ToolCallID})
+		default:
+			result = append(result, msg)
+		}
+	}
+	// ToolCallID 仅用于合并，不发送给上游
+	for i := range result {
+		if result[i].Role == "user" {
+			result[i].ToolCallID = nil
+		}
+	}
+	return result
+}
+
+func renderToolCall(toolCall ToolCall) string {
+	call := emulatedToolCall{Name: toolCall.Function.Name}
+	if json.Valid([]byte(toolCall.Function.Arguments)) {
+		call.Arguments = json.RawMessage(toolCall.Function.Arguments)
+	} else {
+		call.Arguments, _ = json.Marshal(toolCall.Function.Arguments)
+	}
+	data, _ := json.Marshal(call)
+	return toolCallOpenTag + "\n" + string(data) + "\n" + toolCallCloseTag
+}
+
+// prependSystemPrompt 将提示词追加到第一条系统消息，没有系统消息时插入到最前面
+func prependSystemPrompt(messages []Message, prompt string) []Message {
+	for i, msg := range messages {
+		if msg.Role != "system" && msg.Role != "developer" {
+			continue
+		}
+		switch {
+		case msg.Content.Content != nil:
+			text := *msg.Content.Content + "\n\n" + prompt
+			messages[i].Content = MessageContent{Content: &text}
+		default:
+			parts := slices.Clone(msg.Content.MultipleContent)
+			messages[i].Content = MessageContent{MultipleContent: append(parts, MessageContentPart{Type: "text", Text: &prompt})}
+		}
+		return messages
+	}
+	return append([]Message{{Role: "system", Content: MessageContent{Content: &prompt}}}, messages...)
+}
+
+// contentText 拼接消息中的文本内容
+func contentText(content MessageContent) string {
+	if content.Content != nil {
+		return *content.Content
+	}
+	var sb strings.Builder
+	for _, part := range content.MultipleContent {
+		if part.Type == "text" && part.Text != nil {
+			sb.WriteString(*part.Text)
+		}
+	}
+	return sb.String()
+}
+
+// ToolCallParser 从模拟工具调用的回复中解析 <tool_call> 块并转换为 ToolCalls
+// 流式响应按 choice 保存解析状态，每次请求使用新的实例
+type ToolCallParser struct {
+	choices map[int]*toolCallState
+}
+
+// toolCallState 单个 choice 的流式解析状态
+// 标签可能被拆分到多个数据块中，无法确定的部分暂存到下一个数据块
+type toolCallState struct {
+	inCall  bool
+	pending string
+	calls   int
+}
+
+// ParseResponse 解析非流式响应
+func (p *ToolCallParser) ParseResponse(resp *InternalLLMResponse) {
+	if resp == nil {
+		return
+	}
+	for i := range resp.Choices {
+		choice := &resp.Choices[i]
+		if choice.Message == nil || len(choice.Message.ToolCalls) > 0 {
+			continue
+		}
+		text := contentText(choice.Message.Content)
+		if !strings.Contains(text, toolCallOpenTag) {
+			continue
+		}
+		state := &toolCallState{}
+		content, calls := state.process(text)
+		flushContent, flushCalls := state.flush()
+		content += flushContent
+		calls = append(calls, flushCalls...)
+		if len(calls) == 0 {
+			continue
+		}
+		setToolCallContent(choice.Message, strings.TrimSpace(content))
+		choice.Message.ToolCalls = calls
+		choice.FinishReason = lo.ToPtr("tool_calls")
+	}
+}
+
+// ParseStream 解析流式响应的数据块，输出结束时补全剩余内容并将 finish_reason 改为 tool_calls
+func (p *ToolCallParser) ParseStream(resp *InternalLLMResponse) {
+	if resp == nil {
+		return
+	}
+	for i := range resp.Choices {
+		choice := &resp.Choices[i]
+		if p.choices == nil {
+			p.choices = make(map[int]*toolCallState)
+		}
+		state, ok := p.choices[choice.Index]
+		if !ok {
+			state = &toolCallState{}
+			p.choices[choice.Index] = state
+		}
+
+		var content string
+		var calls []ToolCall
+		if choice.Delta != nil && choice.Delta.Content.Content != nil {
+			content, calls = state.process(*choice.Delta.Content.Content)
+		}
+		if choice.FinishReason != nil {
+			flushContent, flushCalls := state.flush()
+			content += flushContent
+			calls = append(calls, flushCalls...)
+			if state.calls > 0 {
+				choice.FinishReason = lo.ToPtr("tool_calls")
+			}
+		}
+		// 工具调用之间的换行不输出
+		if state.calls > 0 && strings.TrimSpace(content) == "" {
+			content = ""
+		}
+		if choice.Delta == nil {
+			if content == "" && len(calls) == 0 {
+				continue
+			}
+			choice.Delta = &Message{Role: "assistant"}
+		}
+		if content != "" {
+			choice.Delta.Content.Content = &content
+		} else {
+			choice.Delta.Content.Content = nil
+		}
+		choice.Delta.ToolCalls = append(choice.Delta.ToolCalls, calls...)
+	}
+}
+
+// process 处理一段正文，返回应输出的正文和解析出的工具调用
+func (s *toolCallState) process(delta string) (content string, calls []ToolCall) {
+	s.pending += delta
+	for {
+		if !s.inCall {
+			if index := strings.Index(s.pending, toolCallOpenTag); index >= 0 {
+				content += s.pending[:index]
+				s.pending = s.pending[index+len(toolCallOpenTag):]
+				s.inCall = true
+				continue
+			}
+			// 保留可能是 <tool_call> 开头部分的结尾
+			keep := tagPartialSuffix(s.pending, toolCallOpenTag)
+			content += s.pending[:len(s.pending)-keep]
+			s.pending = s.pending[len(s.pending)-keep:]
+			return content, calls
+		}
+
+		index := strings.Index(s.pending, toolCallCloseTag)
+		if index < 0 {
+			return content, calls
+		}
+		block := s.pending[:index]
+		s.pending = s.pending[index+len(toolCallCloseTag):]
+		s.inCall = false
+		if call, ok := s.parseCall(block); ok {
+			calls = append(calls, call)
+		} else {
+			content += toolCallOpenTag + block + toolCallCloseTag
+		}
+	}
+}
+
+// flush 在输出结束时返回暂存的内容，未闭合的工具调用块能解析时同样视为工具调用
+func (s *toolCallState) flush() (content string, calls []ToolCall) {
+	text := s.pending
+	s.pending = ""
+	if !s.inCall {
+		return text, nil
+	}
+	s.inCall = false
+	if call, ok := s.parseCall(text); ok {
+		return "", []ToolCall{call}
+	}
+	return toolCallOpenTag + text, nil
+}
+
+// parseCall 解析工具调用块中的 JSON，arguments 为字符串时按 JSON 字符串解码
+func (s *toolCallState) parseCall(block string) (ToolCall, bool) {
+	block = strings.TrimSpace(block)
+	block = strings.TrimPrefix(block, "```json")
+	block = strings.Trim(block, "`\n ")
+
+	var call emulatedToolCall
+	if err := json.Unmarshal([]byte(block), &call); err != nil || call.Name == "" {
+		return ToolCall{}, false
+	}
+	arguments := "{}"
+	if len(call.Arguments) > 0 && string(call.Arguments) != "null" {
+		arguments = string(call.Arguments)
+		var encoded string
+		if json.Unmarshal(call.Arguments, &encoded) == nil {
+			arguments = encoded
+		}
+	}
+	index := s.calls
+	s.calls++
+	return ToolCall{
+		ID:       newToolCallID(),
+		Type:     "function",
+		Function: FunctionCall{Name: call.Name, Arguments: arguments},
+		Index:    index,
+	}, true
+}
+
+// newToolCallID 生成随机的工具调用 ID，同一工具在多轮对话中的调用 ID 不会重复
+func newToolCallID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "call_" + hex.EncodeToString(b)
+}
+
+func setToolCallContent(message *Message, text string) {
+	if text == "" {
+		message.Content = MessageContent{}
+		return
+	}
+	message.Content = MessageContent{Content: &text}
+}
+
+// tagPartialSuffix 返回 s 结尾与 tag 开头相同的最大长度
+func tagPartialSuffix(s, tag string) int {
+	for n := min(len(s), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
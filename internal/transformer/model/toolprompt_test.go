@@ -0,0 +1,98 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+)
+
+func TestEmulateTools(t *testing.T) {
+	req := &InternalLLMRequest{
+		Model: "tiny",
+		Messages: []Message{
+			{Role: "user", Content: MessageContent{Content: lo.ToPtr("weather?")}},
+			{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Type: "function", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}}}},
+			{Role: "tool", ToolCallID: lo.ToPtr("call_1"), Content: MessageContent{Content: lo.ToPtr("sunny")}},
+		},
+		Tools:      []Tool{{Type: "function", Function: Function{Name: "get_weather", Parameters: []byte(`{"type":"object"}`)}}},
+		ToolChoice: &ToolChoice{ToolChoice: lo.ToPtr("required")},
+	}
+
+	emulated := req.EmulateTools()
+	if emulated.Tools != nil || emulated.ToolChoice != nil {
+		t.Fatalf("expected tools to be removed, got %+v", emulated.Tools)
+	}
+	if len(emulated.Messages) != 4 || emulated.Messages[0].Role != "system" {
+		t.Fatalf("expected system prompt to be prepended, got %d messages", len(emulated.Messages))
+	}
+	if prompt := *emulated.Messages[0].Content.Content; !strings.Contains(prompt, `"name":"get_weather"`) || !strings.Contains(prompt, "must call at least one tool") {
+		t.Errorf("unexpected prompt: %s", prompt)
+	}
+	if text := *emulated.Messages[2].Content.Content; !strings.Contains(text, `<tool_call>`+"\n"+`{"name":"get_weather","arguments":{"city":"Paris"}}`) {
+		t.Errorf("unexpected assistant message: %s", text)
+	}
+	if msg := emulated.Messages[3]; msg.Role != "user" || msg.ToolCallID != nil || !strings.Contains(*msg.Content.Content, `{"content":"sunny","name":"get_weather"}`) {
+		t.Errorf("unexpected tool response: %+v", msg)
+	}
+	// 原请求保持不变
+	if len(req.Messages) != 3 || len(req.Messages[1].ToolCalls) != 1 || req.Tools == nil {
+		t.Error("original request was modified")
+	}
+}
+
+func TestToolCallParser_ParseResponse(t *testing.T) {
+	resp := &InternalLLMResponse{Choices: []Choice{{
+		Message:      &Message{Role: "assistant", Content: MessageContent{Content: lo.ToPtr("Let me check.\n<tool_call>\n{\"name\": \"get_weather\", \"arguments\": {\"city\": \"Paris\"}}\n</tool_call>")}},
+		FinishReason: lo.ToPtr("stop"),
+	}}}
+	(&ToolCallParser{}).ParseResponse(resp)
+
+	choice := resp.Choices[0]
+	if *choice.FinishReason != "tool_calls" || len(choice.Message.ToolCalls) != 1 {
+		t.Fatalf("expected one tool call, got %+v", choice)
+	}
+	if call := choice.Message.ToolCalls[0]; call.Function.Name != "get_weather" || call.Function.Arguments != `{"city": "Paris"}` {
+		t.Errorf("unexpected tool call: %+v", call)
+	}
+	if *choice.Message.Content.Content != "Let me check." {
+		t.Errorf("unexpected content: %q", *choice.Message.Content.Content)
+	}
+
+	// 后续轮次调用同一工具时 ID 不能与历史中的重复
+	next := &InternalLLMResponse{Choices: []Choice{{
+		Message: &Message{Role: "assistant", Content: MessageContent{Content: lo.ToPtr("<tool_call>{\"name\": \"get_weather\", \"arguments\": {}}</tool_call>")}},
+	}}}
+	(&ToolCallParser{}).ParseResponse(next)
+	if id := next.Choices[0].Message.ToolCalls[0].ID; id == choice.Message.ToolCalls[0].ID || !strings.HasPrefix(id, "call_") {
+		t.Errorf("tool call id %q is not unique", id)
+	}
+}
+
+func TestToolCallParser_ParseStream(t *testing.T) {
+	deltas := []string{"Sure", " <tool", "_call>\n{\"name\":\"a\",", "\"arguments\":\"{\\\"x\\\":1}\"}</tool_call>\n<tool_call>{\"name\":\"b\"}", "</tool_c", "all>"}
+	parser := &ToolCallParser{}
+	var content string
+	var calls []ToolCall
+	for i, delta := range deltas {
+		chunk := &InternalLLMResponse{Choices: []Choice{{Delta: &Message{Content: MessageContent{Content: lo.ToPtr(delta)}}}}}
+		if i == len(deltas)-1 {
+			chunk.Choices[0].FinishReason = lo.ToPtr("stop")
+		}
+		parser.ParseStream(chunk)
+		if c := chunk.Choices[0].Delta.Content.Content; c != nil {
+			content += *c
+		}
+		calls = append(calls, chunk.Choices[0].Delta.ToolCalls...)
+		if i == len(deltas)-1 && *chunk.Choices[0].FinishReason != "tool_calls" {
+			t.Errorf("expected finish reason tool_calls, got %s", *chunk.Choices[0].FinishReason)
+		}
+	}
+
+	if content != "Sure " {
+		t.Errorf("unexpected content: %q", content)
+	}
+	if len(calls) != 2 || calls[0].Function.Arguments != `{"x":1}` || calls[1].Function.Name != "b" || calls[1].Function.Arguments != "{}" || calls[1].Index != 1 {
+		t.Errorf("unexpected tool calls: %+v", calls)
+	}
+}
@@ -0,0 +1,53 @@
+package authropic
+
+import (
+	"encoding/base64"
+	"strings"
+
+	anthropicModel "github.com/bestruirui/octopus/internal/transformer/inbound/anthropic"
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/bestruirui/octopus/internal/utils/xurl"
+)
+
+// convertFileToBlock 将 file 内容转换为 document 块，PDF 使用 base64 或 url 来源，纯文本使用 text 来源
+// 图片文件转换为 image 块，上传文件的 file_id 无法跨服务商使用，直接丢弃
+func convertFileToBlock(part model.MessageContentPart) *anthropicModel.MessageContentBlock {
+	file := part.File
+	if file == nil {
+		return nil
+	}
+	block := &anthropicModel.MessageContentBlock{
+		Type:         "document",
+		CacheControl: convertCacheControl(part.CacheControl),
+	}
+	if file.Filename != "" {
+		block.Title = &file.Filename
+	}
+
+	switch {
+	case file.FileData != "":
+		parsed := xurl.ParseDataURL(file.FileData)
+		if parsed == nil || !parsed.IsBase64 {
+			return nil
+		}
+		switch {
+		case strings.HasPrefix(parsed.MediaType, "image/"):
+			block.Type = "image"
+			block.Title = nil
+			block.Source = &anthropicModel.ImageSource{Type: "base64", MediaType: parsed.MediaType, Data: parsed.Data}
+		case parsed.MediaType == "text/plain":
+			text, err := base64.StdEncoding.DecodeString(parsed.Data)
+			if err != nil {
+				return nil
+			}
+			block.Source = &anthropicModel.ImageSource{Type: "text", MediaType: parsed.MediaType, Data: string(text)}
+		default:
+			block.Source = &anthropicModel.ImageSource{Type: "base64", MediaType: parsed.MediaType, Data: parsed.Data}
+		}
+	case file.FileURL != "":
+		block.Source = &anthropicModel.ImageSource{Type: "url", URL: file.FileURL}
+	default:
+		return nil
+	}
+	return block
+}
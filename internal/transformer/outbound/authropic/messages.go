@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/samber/lo"
 
@@ -17,6 +18,13 @@ import (
 	"github.com/bestruirui/octopus/internal/utils/xurl"
 )
 
+const (
+	// Anthropic 内置工具的版本
+	webSearchToolType     = "web_search_20250305"
+	codeExecutionToolType = "code_execution_20250522"
+	codeExecutionBeta     = "code-execution-2025-05-22"
+)
+
 type MessageOutbound struct {
 	// Stream state tracking
 	streamID    string
@@ -25,6 +33,17 @@ type MessageOutbound struct {
 	toolIndex   int
 	toolCalls   map[int]*model.ToolCall
 	initialized bool
+
+	// Built-in tool state tracking
+	// inServerTool 当前内容块是否为服务端工具调用，其参数增量不属于函数调用
+	inServerTool bool
+	// streamTextLen 已输出文本的字符数，blockStart 当前文本块的起始位置
+	streamTextLen  int
+	blockStart     int
+	blockCitations []anthropicModel.Citation
+
+	// inStructuredOutput 当前内容块为模拟结构化输出的工具调用，其参数作为文本输出
+	inStructuredOutput bool
 }
 
 func (o *MessageOutbound) TransformRequest(ctx context.Context, request *model.InternalLLMRequest, baseUrl, key string) (*http.Request, error) {
@@ -33,7 +52,7 @@ func (o *MessageOutbound) TransformRequest(ctx context.Context, request *model.I
 	}
 
 	// Convert to Anthropic request format
-	anthropicReq := convertToAnthropicRequest(request)
+	anthropicReq := ConvertToAnthropicRequest(request)
 
 	body, err := json.Marshal(anthropicReq)
 	if err != nil {
@@ -55,6 +74,9 @@ func (o *MessageOutbound) TransformRequest(ctx context.Context, request *model.I
 	}
 	req.Header.Set("Anthropic-Version", "2023-06-01")
 	req.Header.Set("X-API-Key", key)
+	if request.BuiltinTool("code_execution") != nil {
+		req.Header.Set("Anthropic-Beta", codeExecutionBeta)
+	}
 
 	// Parse and set URL
 	parsedUrl, err := url.Parse(strings.TrimSuffix(baseUrl, "/"))
@@ -169,6 +191,10 @@ func (o *MessageOutbound) TransformStream(ctx context.Context, eventData []byte)
 		if streamEvent.ContentBlock != nil {
 			switch streamEvent.ContentBlock.Type {
 			case "tool_use":
+				if isStructuredOutputTool(streamEvent.ContentBlock.Name) {
+					o.inStructuredOutput = true
+					return nil, nil
+				}
 				o.toolIndex++
 				toolCall := model.ToolCall{
 					Index: o.toolIndex,
@@ -180,6 +206,7 @@ func (o *MessageOutbound) TransformStream(ctx context.Context, eventData []byte)
 					},
 				}
 				o.toolCalls[o.toolIndex] = &toolCall
+				o.inServerTool = false
 
 				resp.Choices = []model.Choice{
 					{
@@ -190,7 +217,14 @@ func (o *MessageOutbound) TransformStream(ctx context.Context, eventData []byte)
 						},
 					},
 				}
-			case "text", "thinking":
+			case "text":
+				o.blockStart = o.streamTextLen
+				o.blockCitations = nil
+				return nil, nil
+			case "server_tool_use":
+				o.inServerTool = true
+				return nil, nil
+			case "thinking":
 				// These are handled in content_block_delta
 				return nil, nil
 			default:
@@ -213,8 +247,26 @@ func (o *MessageOutbound) TransformStream(ctx context.Context, eventData []byte)
 					choice.Delta.Content = model.MessageContent{
 						Content: streamEvent.Delta.Text,
 					}
+					o.streamTextLen += utf8.RuneCountInString(*streamEvent.Delta.Text)
+				}
+			case "citations_delta":
+				// 引用在文本块结束时统一输出，此时才能确定被引用文本的范围
+				if streamEvent.Delta.Citation != nil {
+					o.blockCitations = append(o.blockCitations, *streamEvent.Delta.Citation)
 				}
+				return nil, nil
 			case "input_json_delta":
+				if o.inServerTool {
+					return nil, nil
+				}
+				if o.inStructuredOutput {
+					if streamEvent.Delta.PartialJSON == nil || *streamEvent.Delta.PartialJSON == "" {
+						return nil, nil
+					}
+					choice.Delta.Content = model.MessageContent{Content: streamEvent.Delta.PartialJSON}
+					o.streamTextLen += utf8.RuneCountInString(*streamEvent.Delta.PartialJSON)
+					break
+				}
 				if streamEvent.Delta.PartialJSON != nil && o.toolIndex >= 0 {
 					choice.Delta.ToolCalls = []model.ToolCall{
 						{
@@ -254,6 +306,10 @@ func (o *MessageOutbound) TransformStream(ctx context.Context, eventData []byte)
 
 		if streamEvent.Delta != nil && streamEvent.Delta.StopReason != nil {
 			finishReason := convertStopReason(streamEvent.Delta.StopReason)
+			// 仅调用了模拟结构化输出的工具时按正常结束处理
+			if *streamEvent.Delta.StopReason == "tool_use" && len(o.toolCalls) == 0 {
+				finishReason = lo.ToPtr("stop")
+			}
 			resp.Choices = []model.Choice{
 				{
 					Index:        0,
@@ -268,7 +324,24 @@ func (o *MessageOutbound) TransformStream(ctx context.Context, eventData []byte)
 			resp.Usage = o.streamUsage
 		}
 
-	case "content_block_stop", "ping":
+	case "content_block_stop":
+		o.inStructuredOutput = false
+		annotations := convertCitations(o.blockCitations, o.blockStart, o.streamTextLen)
+		o.blockCitations = nil
+		if len(annotations) == 0 {
+			return nil, nil
+		}
+		resp.Choices = []model.Choice{
+			{
+				Index: 0,
+				Delta: &model.Message{
+					Role:        "assistant",
+					Annotations: annotations,
+				},
+			},
+		}
+
+	case "ping":
 		return nil, nil
 
 	default:
@@ -278,8 +351,8 @@ func (o *MessageOutbound) TransformStream(ctx context.Context, eventData []byte)
 	return resp, nil
 }
 
-// convertToAnthropicRequest converts internal LLM request to Anthropic format
-func convertToAnthropicRequest(req *model.InternalLLMRequest) *anthropicModel.MessageRequest {
+// ConvertToAnthropicRequest converts internal LLM request to Anthropic format
+func ConvertToAnthropicRequest(req *model.InternalLLMRequest) *anthropicModel.MessageRequest {
 	result := &anthropicModel.MessageRequest{
 		Model:       req.Model,
 		Temperature: req.Temperature,
@@ -306,14 +379,18 @@ func convertToAnthropicRequest(req *model.InternalLLMRequest) *anthropicModel.Me
 		result.StopSequences = convertStopSequences(req.Stop)
 	}
 
-	// Convert thinking/reasoning
-	if req.ReasoningEffort != "" {
-		result.Thinking = &anthropicModel.Thinking{
-			Type:         "enabled",
-			BudgetTokens: getThinkingBudget(req.ReasoningEffort, req.ReasoningBudget),
+	// Convert thinking/reasoning, budget 0 means thinking disabled
+	if req.ReasoningEffort != "" || req.ReasoningBudget != nil {
+		if budget := getThinkingBudget(req); budget != 0 {
+			result.Thinking = &anthropicModel.Thinking{
+				Type:         "enabled",
+				BudgetTokens: budget,
+			}
 		}
 	}
 
+	applyStructuredOutput(req, result)
+
 	return result
 }
 
@@ -622,6 +699,10 @@ func convertMultiplePartContent(msg model.Message) anthropicModel.MessageContent
 					blocks = append(blocks, *block)
 				}
 			}
+		case "file":
+			if block := convertFileToBlock(part); block != nil {
+				blocks = append(blocks, *block)
+			}
 		}
 	}
 
@@ -656,6 +737,10 @@ func convertImageURLToBlock(part model.MessageContentPart) *anthropicModel.Messa
 
 	url := part.ImageURL.URL
 	if parsed := xurl.ParseDataURL(url); parsed != nil {
+		// 部分客户端通过 image_url 发送 PDF
+		if parsed.MediaType == "application/pdf" {
+			return convertFileToBlock(model.MessageContentPart{Type: "file", File: &model.File{FileData: url}, CacheControl: part.CacheControl})
+		}
 		return &anthropicModel.MessageContentBlock{
 			Type: "image",
 			Source: &anthropicModel.ImageSource{
@@ -680,6 +765,17 @@ func convertImageURLToBlock(part model.MessageContentPart) *anthropicModel.Messa
 func convertTools(tools []model.Tool) []anthropicModel.Tool {
 	result := make([]anthropicModel.Tool, 0, len(tools))
 	for _, tool := range tools {
+		switch tool.Type {
+		case "web_search":
+			result = append(result, convertWebSearchTool(tool))
+			continue
+		case "code_execution":
+			result = append(result, anthropicModel.Tool{
+				Type: codeExecutionToolType,
+				Name: "code_execution",
+			})
+			continue
+		}
 		if tool.Type != "function" {
 			continue
 		}
@@ -693,6 +789,52 @@ func convertTools(tools []model.Tool) []anthropicModel.Tool {
 	return result
 }
 
+func convertWebSearchTool(tool model.Tool) anthropicModel.Tool {
+	result := anthropicModel.Tool{
+		Type: webSearchToolType,
+		Name: "web_search",
+	}
+	if ws := tool.WebSearch; ws != nil {
+		result.MaxUses = ws.MaxUses
+		// allowed_domains 与 blocked_domains 不能同时使用，优先保留 allowed_domains
+		if len(ws.AllowedDomains) > 0 {
+			result.AllowedDomains = ws.AllowedDomains
+		} else {
+			result.BlockedDomains = ws.BlockedDomains
+		}
+		if loc := ws.UserLocation; loc != nil {
+			result.UserLocation = &anthropicModel.UserLocation{
+				Type:     "approximate",
+				City:     loc.City,
+				Region:   loc.Region,
+				Country:  loc.Country,
+				Timezone: loc.Timezone,
+			}
+		}
+	}
+	return result
+}
+
+// convertCitations 将文本块的联网搜索引用转换为内部引用，start、end 为文本块在整条回复中的字符范围
+func convertCitations(citations []anthropicModel.Citation, start, end int) []model.Annotation {
+	var result []model.Annotation
+	for _, citation := range citations {
+		if citation.Type != "web_search_result_location" || citation.URL == "" {
+			continue
+		}
+		result = append(result, model.Annotation{
+			Type: "url_citation",
+			URLCitation: &model.URLCitation{
+				StartIndex: start,
+				EndIndex:   end,
+				URL:        citation.URL,
+				Title:      lo.FromPtr(citation.Title),
+			},
+		})
+	}
+	return result
+}
+
 func convertStopSequences(stop *model.Stop) []string {
 	if stop == nil {
 		return nil
@@ -716,21 +858,13 @@ func convertCacheControl(cc *model.CacheControl) *anthropicModel.CacheControl {
 	}
 }
 
-func getThinkingBudget(effort string, budget *int64) int64 {
-	if budget != nil {
-		return *budget
-	}
-
-	switch effort {
-	case "low":
+// getThinkingBudget 返回思考预算，Anthropic 不支持动态预算，-1 时使用最小预算
+func getThinkingBudget(req *model.InternalLLMRequest) int64 {
+	budget := req.ReasoningBudgetTokens()
+	if budget < 0 {
 		return 1024
-	case "medium":
-		return 8192
-	case "high":
-		return 32768
-	default:
-		return 8192
 	}
+	return budget
 }
 
 // Response conversion functions
@@ -755,12 +889,17 @@ func convertToLLMResponse(resp *anthropicModel.Message) *model.InternalLLMRespon
 		thinkingSignature *string
 		toolCalls         []model.ToolCall
 		textParts         []string
+		annotations       []model.Annotation
+		textLen           int
 	)
 
 	for _, block := range resp.Content {
 		switch block.Type {
 		case "text":
 			if block.Text != nil && *block.Text != "" {
+				start := textLen
+				textLen += utf8.RuneCountInString(*block.Text)
+				annotations = append(annotations, convertCitations(block.Citations, start, textLen)...)
 				textParts = append(textParts, *block.Text)
 				content.MultipleContent = append(content.MultipleContent, model.MessageContentPart{
 					Type: "text",
@@ -768,6 +907,16 @@ func convertToLLMResponse(resp *anthropicModel.Message) *model.InternalLLMRespon
 				})
 			}
 		case "tool_use":
+			if isStructuredOutputTool(block.Name) {
+				text := string(block.Input)
+				textLen += utf8.RuneCountInString(text)
+				textParts = append(textParts, text)
+				content.MultipleContent = append(content.MultipleContent, model.MessageContentPart{
+					Type: "text",
+					Text: &text,
+				})
+				continue
+			}
 			if block.ID != "" && block.Name != nil {
 				input := "{}"
 				if len(block.Input) > 0 {
@@ -801,6 +950,7 @@ func convertToLLMResponse(resp *anthropicModel.Message) *model.InternalLLMRespon
 		Role:               resp.Role,
 		Content:            content,
 		ToolCalls:          toolCalls,
+		Annotations:        annotations,
 		ReasoningContent:   thinkingText,
 		ReasoningSignature: thinkingSignature,
 	}
@@ -810,6 +960,10 @@ func convertToLLMResponse(resp *anthropicModel.Message) *model.InternalLLMRespon
 		Message:      message,
 		FinishReason: convertStopReason(resp.StopReason),
 	}
+	// 仅调用了模拟结构化输出的工具时按正常结束处理
+	if resp.StopReason != nil && *resp.StopReason == "tool_use" && len(toolCalls) == 0 {
+		choice.FinishReason = lo.ToPtr("stop")
+	}
 
 	result.Choices = []model.Choice{choice}
 	result.Usage = convertAnthropicUsage(resp.Usage)
@@ -0,0 +1,55 @@
+package authropic
+
+import (
+	anthropicModel "github.com/bestruirui/octopus/internal/transformer/inbound/anthropic"
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/samber/lo"
+)
+
+// structuredOutputToolName 模拟结构化输出使用的工具名，调用该工具的参数即为回复内容
+const structuredOutputToolName = "structured_output"
+
+const (
+	structuredOutputToolDescription = "Respond with the final answer by calling this tool. The input is the answer and must follow the schema exactly."
+	structuredOutputInstruction     = "When you have the final answer, call the structured_output tool with it instead of replying with text."
+)
+
+// applyStructuredOutput Anthropic 不支持 response_format，添加以 schema 为参数的工具并强制调用来模拟
+// 开启思考或同时提供了其他工具时不能强制调用，改为在系统提示中要求调用
+func applyStructuredOutput(req *model.InternalLLMRequest, result *anthropicModel.MessageRequest) {
+	schema := req.StructuredOutputSchema()
+	if schema == nil {
+		return
+	}
+	description := structuredOutputToolDescription
+	if format := req.ResponseFormat.GetJSONSchema(); format != nil && format.Description != "" {
+		description = format.Description + "\n\n" + description
+	}
+	result.Tools = append(result.Tools, anthropicModel.Tool{
+		Name:        structuredOutputToolName,
+		Description: description,
+		InputSchema: schema,
+	})
+
+	if len(req.Tools) == 0 && result.Thinking == nil {
+		result.ToolChoice = &anthropicModel.ToolChoice{Type: "tool", Name: lo.ToPtr(structuredOutputToolName)}
+		return
+	}
+	appendSystemPrompt(result, structuredOutputInstruction)
+}
+
+func appendSystemPrompt(result *anthropicModel.MessageRequest, text string) {
+	switch {
+	case result.System == nil:
+		result.System = &anthropicModel.SystemPrompt{Prompt: lo.ToPtr(text)}
+	case result.System.Prompt != nil:
+		result.System.Prompt = lo.ToPtr(*result.System.Prompt + "\n\n" + text)
+	default:
+		result.System.MultiplePrompts = append(result.System.MultiplePrompts, anthropicModel.SystemPromptPart{Type: "text", Text: text})
+	}
+}
+
+// isStructuredOutputTool 判断是否为模拟结构化输出的工具调用
+func isStructuredOutputTool(name *string) bool {
+	return name != nil && *name == structuredOutputToolName
+}
@@ -0,0 +1,102 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/bestruirui/octopus/internal/transformer/outbound/openai"
+)
+
+const (
+	// DefaultAPIVersion chat 和 embeddings 默认使用的 api-version
+	DefaultAPIVersion = "2024-10-21"
+	// DefaultResponsesAPIVersion responses 默认使用的 api-version
+	DefaultResponsesAPIVersion = "2025-04-01-preview"
+
+	APIChat      = "chat"
+	APIResponses = "responses"
+)
+
+// OpenAIOutbound Azure OpenAI 出站
+// 请求体与 OpenAI 一致，复用 openai 出站构建请求后改写为部署路径、api-version 和 api-key 认证
+type OpenAIOutbound struct {
+	options *model.ChannelOptions
+	inner   model.Outbound
+}
+
+func (o *OpenAIOutbound) SetChannelOptions(options *model.ChannelOptions) {
+	o.options = options
+}
+
+func (o *OpenAIOutbound) TransformRequest(ctx context.Context, request *model.InternalLLMRequest, baseUrl, key string) (*http.Request, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request is nil")
+	}
+	endpoint := strings.TrimSuffix(strings.TrimSuffix(baseUrl, "/"), "/openai")
+
+	// 在副本上将模型名替换为部署名，不修改调用方的请求
+	deployment := Deployment(o.options, request.Model)
+	r := *request
+	r.Model = deployment
+	request = &r
+
+	var innerBaseUrl, apiVersion string
+	switch {
+	case request.IsEmbeddingRequest():
+		o.inner = &openai.EmbeddingOutbound{}
+		innerBaseUrl = endpoint + "/openai/deployments/" + url.PathEscape(deployment)
+		apiVersion = DefaultAPIVersion
+	case o.options != nil && o.options.API == APIResponses:
+		o.inner = &openai.ResponseOutbound{}
+		innerBaseUrl = endpoint + "/openai"
+		apiVersion = DefaultResponsesAPIVersion
+	default:
+		chat := &openai.ChatOutbound{}
+		chat.SetChannelOptions(o.options)
+		o.inner = chat
+		innerBaseUrl = endpoint + "/openai/deployments/" + url.PathEscape(deployment)
+		apiVersion = DefaultAPIVersion
+	}
+	if o.options != nil && o.options.APIVersion != "" {
+		apiVersion = o.options.APIVersion
+	}
+
+	req, err := o.inner.TransformRequest(ctx, request, innerBaseUrl, key)
+	if err != nil {
+		return nil, err
+	}
+	query := req.URL.Query()
+	query.Set("api-version", apiVersion)
+	req.URL.RawQuery = query.Encode()
+	req.Header.Del("Authorization")
+	req.Header.Set("api-key", key)
+	return req, nil
+}
+
+func (o *OpenAIOutbound) TransformResponse(ctx context.Context, response *http.Response) (*model.InternalLLMResponse, error) {
+	if o.inner == nil {
+		return nil, fmt.Errorf("request has not been transformed")
+	}
+	return o.inner.TransformResponse(ctx, response)
+}
+
+func (o *OpenAIOutbound) TransformStream(ctx context.Context, eventData []byte) (*model.InternalLLMResponse, error) {
+	if o.inner == nil {
+		return nil, fmt.Errorf("request has not been transformed")
+	}
+	return o.inner.TransformStream(ctx, eventData)
+}
+
+// Deployment 返回模型对应的部署名，未配置映射时使用模型名
+func Deployment(options *model.ChannelOptions, modelName string) string {
+	if options != nil {
+		if deployment, ok := options.Deployments[modelName]; ok && deployment != "" {
+			return deployment
+		}
+	}
+	return modelName
+}
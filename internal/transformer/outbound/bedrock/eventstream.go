@@ -0,0 +1,151 @@
+package bedrock
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"iter"
+)
+
+// maxEventStreamMessageSize AWS event stream 单条消息的最大长度
+const maxEventStreamMessageSize = 16 * 1024 * 1024
+
+// eventStreamMessage AWS event stream 的单条消息
+type eventStreamMessage struct {
+	Headers map[string]string
+	Payload []byte
+}
+
+// readEventStreamMessage 读取一条 AWS event stream 消息
+// 格式: total length(4) | headers length(4) | prelude crc(4) | headers | payload | message crc(4)
+func readEventStreamMessage(r io.Reader) (*eventStreamMessage, error) {
+	prelude := make([]byte, 12)
+	if _, err := io.ReadFull(r, prelude); err != nil {
+		return nil, err
+	}
+	totalLen := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[0:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, errors.New("event stream prelude checksum mismatch")
+	}
+	if totalLen < 16+headersLen || totalLen > maxEventStreamMessageSize {
+		return nil, fmt.Errorf("invalid event stream message length %d", totalLen)
+	}
+
+	message := make([]byte, totalLen)
+	copy(message, prelude)
+	if _, err := io.ReadFull(r, message[12:]); err != nil {
+		return nil, fmt.Errorf("failed to read event stream message: %w", err)
+	}
+	if crc32.ChecksumIEEE(message[:totalLen-4]) != binary.BigEndian.Uint32(message[totalLen-4:]) {
+		return nil, errors.New("event stream message checksum mismatch")
+	}
+
+	headers, err := parseEventStreamHeaders(message[12 : 12+headersLen])
+	if err != nil {
+		return nil, err
+	}
+	return &eventStreamMessage{
+		Headers: headers,
+		Payload: message[12+headersLen : totalLen-4],
+	}, nil
+}
+
+// parseEventStreamHeaders 解析消息头，只保留字符串类型的值
+func parseEventStreamHeaders(data []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	for len(data) > 0 {
+		nameLen := int(data[0])
+		if len(data) < 1+nameLen+1 {
+			return nil, errors.New("invalid event stream header")
+		}
+		name := string(data[1 : 1+nameLen])
+		valueType := data[1+nameLen]
+		data = data[2+nameLen:]
+
+		var size int
+		switch valueType {
+		case 0, 1: // bool true / false
+			size = 0
+		case 2: // byte
+			size = 1
+		case 3: // short
+			size = 2
+		case 4: // int
+			size = 4
+		case 5, 8: // long / timestamp
+			size = 8
+		case 9: // uuid
+			size = 16
+		case 6, 7: // bytes / string
+			if len(data) < 2 {
+				return nil, errors.New("invalid event stream header")
+			}
+			size = int(binary.BigEndian.Uint16(data[0:2]))
+			data = data[2:]
+		default:
+			return nil, fmt.Errorf("unknown event stream header type %d", valueType)
+		}
+		if len(data) < size {
+			return nil, errors.New("invalid event stream header")
+		}
+		if valueType == 7 {
+			headers[name] = string(data[:size])
+		}
+		data = data[size:]
+	}
+	return headers, nil
+}
+
+// decodeEventStream 将 InvokeModelWithResponseStream 的响应体解码为 Anthropic 流式事件
+// chunk 事件的 payload 为 {"bytes": base64(event json)}，exception 消息转为错误
+func decodeEventStream(body io.Reader) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		for {
+			msg, err := readEventStreamMessage(body)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			switch msg.Headers[":message-type"] {
+			case "event":
+				if msg.Headers[":event-type"] != "chunk" {
+					continue
+				}
+				var chunk struct {
+					Bytes string `json:"bytes"`
+				}
+				if err := json.Unmarshal(msg.Payload, &chunk); err != nil {
+					yield(nil, fmt.Errorf("failed to unmarshal bedrock chunk: %w", err))
+					return
+				}
+				data, err := base64.StdEncoding.DecodeString(chunk.Bytes)
+				if err != nil {
+					yield(nil, fmt.Errorf("failed to decode bedrock chunk: %w", err))
+					return
+				}
+				if !yield(data, nil) {
+					return
+				}
+			case "exception":
+				var exception struct {
+					Message string `json:"message"`
+				}
+				_ = json.Unmarshal(msg.Payload, &exception)
+				yield(nil, fmt.Errorf("bedrock %s: %s", msg.Headers[":exception-type"], exception.Message))
+				return
+			case "error":
+				yield(nil, fmt.Errorf("bedrock %s: %s", msg.Headers[":error-code"], msg.Headers[":error-message"]))
+				return
+			}
+		}
+	}
+}
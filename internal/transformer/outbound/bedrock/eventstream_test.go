@@ -0,0 +1,72 @@
+package bedrock
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+func encodeEventStreamMessage(headers map[string]string, payload []byte) []byte {
+	var h bytes.Buffer
+	for name, value := range headers {
+		h.WriteByte(byte(len(name)))
+		h.WriteString(name)
+		h.WriteByte(7)
+		binary.Write(&h, binary.BigEndian, uint16(len(value)))
+		h.WriteString(value)
+	}
+
+	var msg bytes.Buffer
+	binary.Write(&msg, binary.BigEndian, uint32(16+h.Len()+len(payload)))
+	binary.Write(&msg, binary.BigEndian, uint32(h.Len()))
+	binary.Write(&msg, binary.BigEndian, crc32.ChecksumIEEE(msg.Bytes()))
+	msg.Write(h.Bytes())
+	msg.Write(payload)
+	binary.Write(&msg, binary.BigEndian, crc32.ChecksumIEEE(msg.Bytes()))
+	return msg.Bytes()
+}
+
+func TestDecodeEventStream(t *testing.T) {
+	event := `{"type":"message_stop"}`
+	var body bytes.Buffer
+	body.Write(encodeEventStreamMessage(
+		map[string]string{":message-type": "event", ":event-type": "chunk"},
+		[]byte(`{"bytes":"`+base64.StdEncoding.EncodeToString([]byte(event))+`"}`),
+	))
+	body.Write(encodeEventStreamMessage(
+		map[string]string{":message-type": "exception", ":exception-type": "throttlingException"},
+		[]byte(`{"message":"Too many requests"}`),
+	))
+
+	var events []string
+	var lastErr error
+	for data, err := range decodeEventStream(&body) {
+		if err != nil {
+			lastErr = err
+			break
+		}
+		events = append(events, string(data))
+	}
+
+	if len(events) != 1 || events[0] != event {
+		t.Fatalf("expected [%s], got %v", event, events)
+	}
+	if lastErr == nil || lastErr.Error() != "bedrock throttlingException: Too many requests" {
+		t.Fatalf("unexpected error: %v", lastErr)
+	}
+}
+
+func TestDecodeEventStreamChecksumMismatch(t *testing.T) {
+	msg := encodeEventStreamMessage(map[string]string{":message-type": "event"}, []byte(`{}`))
+	msg[len(msg)-1] ^= 0xff
+
+	for _, err := range decodeEventStream(bytes.NewReader(msg)) {
+		if err == nil {
+			t.Fatal("expected checksum error")
+		}
+		return
+	}
+	t.Fatal("expected checksum error")
+}
@@ -0,0 +1,102 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	anthropicModel "github.com/bestruirui/octopus/internal/transformer/inbound/anthropic"
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/bestruirui/octopus/internal/transformer/outbound/authropic"
+	"github.com/samber/lo"
+)
+
+// anthropicVersion Bedrock 上 Anthropic 模型要求的 anthropic_version
+const anthropicVersion = "bedrock-2023-05-31"
+
+// MessagesOutbound AWS Bedrock 上的 Anthropic 模型
+// 请求体复用 Anthropic Messages 格式，通过 InvokeModel / InvokeModelWithResponseStream 调用并使用 SigV4 签名
+type MessagesOutbound struct {
+	inner authropic.MessageOutbound
+}
+
+func (o *MessagesOutbound) TransformRequest(ctx context.Context, request *model.InternalLLMRequest, baseUrl, key string) (*http.Request, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request is nil")
+	}
+	cred, err := parseCredentials(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bedrock 不支持 url 类型的图片和文档，下载后内联
+	copied := *request
+	request = &copied
+	if err := request.InlineRemoteMedia(ctx, model.RemoteMediaImage|model.RemoteMediaFile); err != nil {
+		return nil, err
+	}
+
+	// 模型和是否流式由请求路径决定，不在请求体中
+	anthropicReq := authropic.ConvertToAnthropicRequest(request)
+	anthropicReq.Model = ""
+	anthropicReq.Stream = nil
+	anthropicReq.AnthropicVersion = anthropicVersion
+	// Bedrock 不支持 Anthropic 的服务端工具（联网搜索、代码执行）
+	anthropicReq.Tools = lo.Reject(anthropicReq.Tools, func(tool anthropicModel.Tool, _ int) bool {
+		return tool.IsServerTool()
+	})
+
+	body, err := json.Marshal(anthropicReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal anthropic request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	action := "invoke"
+	if request.Stream != nil && *request.Stream {
+		action = "invoke-with-response-stream"
+		req.Header.Set("Accept", "application/vnd.amazon.eventstream")
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	// 未填写 Base URL 时使用密钥中区域的默认地址
+	if baseUrl == "" {
+		baseUrl = "https://bedrock-runtime." + cred.Region + ".amazonaws.com"
+	}
+	parsedUrl, err := url.Parse(strings.TrimSuffix(baseUrl, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse base url: %w", err)
+	}
+	parsedUrl.RawPath = parsedUrl.EscapedPath() + "/model/" + awsURIEncode(request.Model) + "/" + action
+	parsedUrl.Path = parsedUrl.Path + "/model/" + request.Model + "/" + action
+	req.URL = parsedUrl
+	req.Host = parsedUrl.Host
+
+	signRequest(req, body, cred, "bedrock", time.Now())
+	return req, nil
+}
+
+func (o *MessagesOutbound) TransformResponse(ctx context.Context, response *http.Response) (*model.InternalLLMResponse, error) {
+	return o.inner.TransformResponse(ctx, response)
+}
+
+func (o *MessagesOutbound) TransformStream(ctx context.Context, eventData []byte) (*model.InternalLLMResponse, error) {
+	return o.inner.TransformStream(ctx, eventData)
+}
+
+func (o *MessagesOutbound) DecodeStream(body io.Reader) iter.Seq2[[]byte, error] {
+	return decodeEventStream(body)
+}
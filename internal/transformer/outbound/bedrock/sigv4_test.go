@@ -0,0 +1,119 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/samber/lo"
+)
+
+var authorizationRegex = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=([^/]+)/(\d{8})/([^/]+)/([^/]+)/aws4_request, SignedHeaders=([a-z0-9;-]+), Signature=([0-9a-f]{64})$`)
+
+// verifySigV4 按服务端收到的请求重新计算签名并与 Authorization 头比较
+func verifySigV4(r *http.Request, body []byte, secretAccessKey string) error {
+	m := authorizationRegex.FindStringSubmatch(r.Header.Get("Authorization"))
+	if m == nil {
+		return fmt.Errorf("malformed authorization header: %q", r.Header.Get("Authorization"))
+	}
+	date, region, service, signedHeaders, signature := m[2], m[3], m[4], m[5], m[6]
+	if r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(body) {
+		return fmt.Errorf("payload hash mismatch")
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	if !strings.HasPrefix(amzDate, date) {
+		return fmt.Errorf("x-amz-date %s does not match credential scope date %s", amzDate, date)
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	segments := strings.Split(r.URL.EscapedPath(), "/")
+	for i, segment := range segments {
+		segments[i] = awsURIEncode(segment)
+	}
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		strings.Join(segments, "/"),
+		canonicalQuery(r.URL),
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	if expected := hex.EncodeToString(hmacSHA256(key, stringToSign)); expected != signature {
+		return fmt.Errorf("signature mismatch: expected %s, got %s", expected, signature)
+	}
+	return nil
+}
+
+func TestSignRequest(t *testing.T) {
+	const secret = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	var gotPath, gotSignedHeaders string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := verifySigV4(r, body, secret); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		gotPath = r.URL.EscapedPath()
+		gotSignedHeaders = authorizationRegex.FindStringSubmatch(r.Header.Get("Authorization"))[5]
+	}))
+	defer server.Close()
+
+	send := func(tamper func(req *http.Request)) (int, string) {
+		request := &model.InternalLLMRequest{
+			Model:    "anthropic.claude-3-5-sonnet-20240620-v1:0",
+			Messages: []model.Message{{Role: "user", Content: model.MessageContent{Content: lo.ToPtr("hello")}}},
+			Stream:   lo.ToPtr(true),
+		}
+		req, err := (&MessagesOutbound{}).TransformRequest(context.Background(), request, server.URL+"/", "AKIDEXAMPLE:"+secret+":us-east-1:session-token")
+		if err != nil {
+			t.Fatalf("TransformRequest: %v", err)
+		}
+		// 转发时复制的客户端请求头不参与签名
+		req.Header.Set("User-Agent", "octopus-test")
+		if tamper != nil {
+			tamper(req)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if status, body := send(nil); status != http.StatusOK {
+		t.Fatalf("signature rejected: %d %s", status, body)
+	}
+	if gotPath != "/model/anthropic.claude-3-5-sonnet-20240620-v1%3A0/invoke-with-response-stream" {
+		t.Errorf("unexpected path: %s", gotPath)
+	}
+	if gotSignedHeaders != "host;x-amz-content-sha256;x-amz-date;x-amz-security-token" {
+		t.Errorf("unexpected signed headers: %s", gotSignedHeaders)
+	}
+
+	if status, _ := send(func(req *http.Request) { req.Header.Set("X-Amz-Security-Token", "other-token") }); status != http.StatusForbidden {
+		t.Errorf("tampered signed header accepted: %d", status)
+	}
+}
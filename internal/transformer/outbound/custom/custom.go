@@ -0,0 +1,400 @@
+package custom
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/tmaxmax/go-sse"
+)
+
+const (
+	streamFormatSSE    = "sse"
+	streamFormatNDJSON = "ndjson"
+	defaultStreamDone  = "[DONE]"
+	// maxEventSize 单个 SSE 事件的最大长度
+	maxEventSize = 32 * 1024 * 1024
+)
+
+// Outbound 自定义渠道
+// 通过模板构建请求，并按提取规则从上游 JSON 中取出内容、工具调用和用量，无需为每个上游编写代码
+type Outbound struct {
+	options *model.CustomOptions
+
+	model string
+
+	// Stream state tracking
+	streamID      string
+	streamCreated int64
+	toolCallIndex int
+}
+
+// templateData 模板中可用的字段
+type templateData struct {
+	BaseURL  string
+	Key      string
+	Model    string
+	Stream   bool
+	Request  *model.InternalLLMRequest
+	Messages []model.Message
+	// System 所有 system/developer 消息的文本，以换行拼接
+	System string
+	// Prompt 最后一条 user 消息的文本
+	Prompt string
+}
+
+var templateFuncs = template.FuncMap{
+	// json 将值编码为 JSON，用于在请求体模板中嵌入字符串、数组等
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	},
+	// text 提取消息内容中的文本
+	"text": contentText,
+}
+
+func (o *Outbound) SetChannelOptions(options *model.ChannelOptions) {
+	if options != nil {
+		o.options = options.Custom
+	}
+}
+
+func (o *Outbound) TransformRequest(ctx context.Context, request *model.InternalLLMRequest, baseUrl, key string) (*http.Request, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request is nil")
+	}
+	if o.options == nil {
+		return nil, fmt.Errorf("custom channel options are not configured")
+	}
+	request.ClearHelpFields()
+	o.model = request.Model
+
+	data := newTemplateData(request, strings.TrimSuffix(baseUrl, "/"), key)
+
+	urlTemplate := o.options.URL
+	if urlTemplate == "" {
+		urlTemplate = "{{.BaseURL}}"
+	}
+	requestUrl, err := render("url", urlTemplate, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	if o.options.Body == "" {
+		body, err = json.Marshal(request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+	} else {
+		rendered, err := render("body", o.options.Body, data)
+		if err != nil {
+			return nil, err
+		}
+		body = []byte(rendered)
+	}
+
+	method := strings.ToUpper(o.options.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSpace(requestUrl), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if data.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+	for name, valueTemplate := range o.options.Headers {
+		value, err := render("header "+name, valueTemplate, data)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(name, value)
+	}
+	return req, nil
+}
+
+func (o *Outbound) TransformResponse(ctx context.Context, response *http.Response) (*model.InternalLLMResponse, error) {
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if len(body) == 0 {
+		return nil, fmt.Errorf("response body is empty")
+	}
+
+	root, err := decodeJSON(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal custom response: %w", err)
+	}
+	rules := &o.options.Response
+	if message, ok := lookupString(root, rules.Error); ok && message != "" {
+		return nil, &model.ResponseError{Detail: model.ErrorDetail{Message: message}}
+	}
+
+	message := extractMessage(root, rules)
+	id, _ := lookupString(root, rules.ID)
+	if id == "" {
+		id = fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+	}
+	return &model.InternalLLMResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   o.model,
+		Choices: []model.Choice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: extractFinishReason(root, rules, len(message.ToolCalls) > 0),
+		}},
+		Usage: extractUsage(root, rules),
+	}, nil
+}
+
+func (o *Outbound) TransformStream(ctx context.Context, eventData []byte) (*model.InternalLLMResponse, error) {
+	if bytes.HasPrefix(eventData, []byte("[DONE]")) {
+		return &model.InternalLLMResponse{
+			Object: "[DONE]",
+		}, nil
+	}
+	if len(bytes.TrimSpace(eventData)) == 0 {
+		return nil, nil
+	}
+
+	root, err := decodeJSON(eventData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal custom stream chunk: %w", err)
+	}
+	rules := &o.options.Stream
+	if message, ok := lookupString(root, rules.Error); ok && message != "" {
+		return nil, &model.ResponseError{Detail: model.ErrorDetail{Message: message}}
+	}
+
+	if o.streamID == "" {
+		o.streamID, _ = lookupString(root, rules.ID)
+		if o.streamID == "" {
+			o.streamID = fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+		}
+		o.streamCreated = time.Now().Unix()
+	}
+
+	delta := extractMessage(root, rules)
+	delta.Role = ""
+	// 没有 ID 和名称的工具调用视为上一个工具调用的参数增量
+	for i := range delta.ToolCalls {
+		if delta.ToolCalls[i].ID != "" || delta.ToolCalls[i].Function.Name != "" || o.toolCallIndex == 0 {
+			delta.ToolCalls[i].Index = o.toolCallIndex
+			o.toolCallIndex++
+		} else {
+			delta.ToolCalls[i].Index = o.toolCallIndex - 1
+		}
+	}
+
+	return &model.InternalLLMResponse{
+		ID:      o.streamID,
+		Object:  "chat.completion.chunk",
+		Created: o.streamCreated,
+		Model:   o.model,
+		Choices: []model.Choice{{
+			Index:        0,
+			Delta:        delta,
+			FinishReason: extractFinishReason(root, rules, o.toolCallIndex > 0),
+		}},
+		Usage: extractUsage(root, rules),
+	}, nil
+}
+
+// DecodeStream 按配置的格式读取流式响应，遇到结束标记或读取结束时追加 [DONE]
+func (o *Outbound) DecodeStream(body io.Reader) iter.Seq2[[]byte, error] {
+	done := defaultStreamDone
+	format := streamFormatSSE
+	if o.options != nil {
+		if o.options.StreamDone != "" {
+			done = o.options.StreamDone
+		}
+		if o.options.StreamFormat != "" {
+			format = strings.ToLower(o.options.StreamFormat)
+		}
+	}
+
+	return func(yield func([]byte, error) bool) {
+		emit := func(data []byte) (bool, bool) {
+			if string(bytes.TrimSpace(data)) == done {
+				yield([]byte("[DONE]"), nil)
+				return false, true
+			}
+			return yield(data, nil), false
+		}
+
+		if format == streamFormatNDJSON {
+			reader := bufio.NewReader(body)
+			for {
+				line, err := reader.ReadBytes('\n')
+				if line = bytes.TrimSpace(line); len(line) > 0 {
+					if next, finished := emit(line); !next || finished {
+						return
+					}
+				}
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+			}
+		} else {
+			for ev, err := range sse.Read(body, &sse.ReadConfig{MaxEventSize: maxEventSize}) {
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+				if next, finished := emit([]byte(ev.Data)); !next || finished {
+					return
+				}
+			}
+		}
+		yield([]byte("[DONE]"), nil)
+	}
+}
+
+func newTemplateData(request *model.InternalLLMRequest, baseUrl, key string) *templateData {
+	data := &templateData{
+		BaseURL:  baseUrl,
+		Key:      key,
+		Model:    request.Model,
+		Stream:   request.Stream != nil && *request.Stream,
+		Request:  request,
+		Messages: request.Messages,
+	}
+	var system []string
+	for _, message := range request.Messages {
+		switch message.Role {
+		case "system", "developer":
+			system = append(system, contentText(message.Content))
+		case "user":
+			data.Prompt = contentText(message.Content)
+		}
+	}
+	data.System = strings.Join(system, "\n")
+	return data
+}
+
+func render(name, text string, data *templateData) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s template: %w", name, err)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute %s template: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// contentText 拼接消息内容中的文本部分
+func contentText(content model.MessageContent) string {
+	if content.Content != nil {
+		return *content.Content
+	}
+	var parts []string
+	for _, part := range content.MultipleContent {
+		if part.Type == "text" && part.Text != nil {
+			parts = append(parts, *part.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func extractMessage(root any, rules *model.CustomExtract) *model.Message {
+	message := &model.Message{Role: "assistant"}
+	if content, ok := lookupString(root, rules.Content); ok {
+		message.Content = model.MessageContent{Content: &content}
+	}
+	if reasoning, ok := lookupString(root, rules.Reasoning); ok && reasoning != "" {
+		message.SetReasoningContent(reasoning)
+	}
+
+	value, ok := lookup(root, rules.ToolCalls)
+	if !ok {
+		return message
+	}
+	items, isArray := value.([]any)
+	if !isArray {
+		items = []any{value}
+	}
+	for i, item := range items {
+		id, _ := lookupString(item, rules.ToolCallID)
+		name, _ := lookupString(item, rules.ToolCallName)
+		arguments, _ := lookupString(item, rules.ToolCallArguments)
+		if id == "" && name == "" && arguments == "" {
+			continue
+		}
+		message.ToolCalls = append(message.ToolCalls, model.ToolCall{
+			ID:    id,
+			Type:  "function",
+			Index: i,
+			Function: model.FunctionCall{
+				Name:      name,
+				Arguments: arguments,
+			},
+		})
+	}
+	return message
+}
+
+// extractFinishReason 将常见的结束原因归一化为 OpenAI 的取值
+func extractFinishReason(root any, rules *model.CustomExtract, hasToolCalls bool) *string {
+	reason, ok := lookupString(root, rules.FinishReason)
+	if !ok || reason == "" {
+		return nil
+	}
+	switch strings.ToLower(reason) {
+	case "stop", "end_turn", "stop_sequence", "complete", "completed", "finished", "eos":
+		reason = "stop"
+		if hasToolCalls {
+			reason = "tool_calls"
+		}
+	case "length", "max_tokens", "max_output_tokens":
+		reason = "length"
+	case "tool_calls", "tool_use", "function_call":
+		reason = "tool_calls"
+	case "content_filter", "safety":
+		reason = "content_filter"
+	}
+	return &reason
+}
+
+func extractUsage(root any, rules *model.CustomExtract) *model.Usage {
+	prompt, hasPrompt := lookupInt(root, rules.PromptTokens)
+	completion, hasCompletion := lookupInt(root, rules.CompletionTokens)
+	total, hasTotal := lookupInt(root, rules.TotalTokens)
+	if !hasPrompt && !hasCompletion && !hasTotal {
+		return nil
+	}
+	if !hasTotal {
+		total = prompt + completion
+	}
+	return &model.Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		TotalTokens:      total,
+	}
+}
@@ -0,0 +1,98 @@
+package custom
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bestruirui/octopus/internal/transformer/model"
+)
+
+func newTestOutbound() *Outbound {
+	o := &Outbound{}
+	o.SetChannelOptions(&model.ChannelOptions{Custom: &model.CustomOptions{
+		URL:     "{{.BaseURL}}/v1/generate/{{.Model}}",
+		Headers: map[string]string{"X-Api-Token": "{{.Key}}"},
+		Body:    `{"prompt": {{json .Prompt}}, "system": {{json .System}}, "stream": {{.Stream}}}`,
+		Response: model.CustomExtract{
+			Content:           "$.output[0].text",
+			FinishReason:      "output.0.stop",
+			PromptTokens:      "meta.tokens.in",
+			CompletionTokens:  "meta.tokens.out",
+			ToolCalls:         "calls",
+			ToolCallName:      "fn",
+			ToolCallArguments: "args",
+		},
+		Stream: model.CustomExtract{Content: "delta"},
+	}})
+	return o
+}
+
+func TestTransformRequest(t *testing.T) {
+	system, prompt := "be brief", "hello"
+	request := &model.InternalLLMRequest{
+		Model: "m1",
+		Messages: []model.Message{
+			{Role: "system", Content: model.MessageContent{Content: &system}},
+			{Role: "user", Content: model.MessageContent{Content: &prompt}},
+		},
+	}
+
+	req, err := newTestOutbound().TransformRequest(context.Background(), request, "https://example.com/", "secret")
+	if err != nil {
+		t.Fatalf("TransformRequest: %v", err)
+	}
+	if got := req.URL.String(); got != "https://example.com/v1/generate/m1" {
+		t.Errorf("url = %q", got)
+	}
+	if got := req.Header.Get("X-Api-Token"); got != "secret" {
+		t.Errorf("header = %q", got)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if want := `{"prompt": "hello", "system": "be brief", "stream": false}`; string(body) != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+}
+
+func TestTransformResponse(t *testing.T) {
+	o := newTestOutbound()
+	o.model = "m1"
+	body := `{"output":[{"text":"hi","stop":"end_turn"}],"meta":{"tokens":{"in":3,"out":2}},"calls":[{"fn":"lookup","args":{"q":1}}]}`
+
+	resp, err := o.TransformResponse(context.Background(), &http.Response{Body: io.NopCloser(strings.NewReader(body))})
+	if err != nil {
+		t.Fatalf("TransformResponse: %v", err)
+	}
+	message := resp.Choices[0].Message
+	if message.Content.Content == nil || *message.Content.Content != "hi" {
+		t.Errorf("content = %v", message.Content.Content)
+	}
+	if len(message.ToolCalls) != 1 || message.ToolCalls[0].Function.Name != "lookup" || message.ToolCalls[0].Function.Arguments != `{"q":1}` {
+		t.Errorf("tool calls = %+v", message.ToolCalls)
+	}
+	if reason := resp.Choices[0].FinishReason; reason == nil || *reason != "tool_calls" {
+		t.Errorf("finish reason = %v", reason)
+	}
+	if resp.Usage == nil || resp.Usage.PromptTokens != 3 || resp.Usage.CompletionTokens != 2 || resp.Usage.TotalTokens != 5 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestDecodeStreamNDJSON(t *testing.T) {
+	o := newTestOutbound()
+	o.options.StreamFormat = "ndjson"
+	o.options.StreamDone = `{"end":true}`
+
+	var events []string
+	for data, err := range o.DecodeStream(strings.NewReader("{\"delta\":\"a\"}\n\n{\"delta\":\"b\"}\n{\"end\":true}\n{\"delta\":\"c\"}\n")) {
+		if err != nil {
+			t.Fatalf("DecodeStream: %v", err)
+		}
+		events = append(events, string(data))
+	}
+	if want := []string{`{"delta":"a"}`, `{"delta":"b"}`, "[DONE]"}; strings.Join(events, "|") != strings.Join(want, "|") {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
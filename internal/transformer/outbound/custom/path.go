@@ -0,0 +1,116 @@
+package custom
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// splitPath 拆分提取路径
+// 路径以 . 分隔，数组下标可写作 .0 或 [0]，可选的 $ 前缀表示根节点
+func splitPath(path string) []string {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+	path = strings.ReplaceAll(path, "[", ".")
+	path = strings.ReplaceAll(path, "]", "")
+
+	var segments []string
+	for _, segment := range strings.Split(path, ".") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// lookup 按路径从解析后的 JSON 中取值，路径为空或不存在时返回 false
+func lookup(root any, path string) (any, bool) {
+	if strings.TrimSpace(path) == "" {
+		return nil, false
+	}
+	current := root
+	for _, segment := range splitPath(path) {
+		switch node := current.(type) {
+		case map[string]any:
+			value, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil {
+				return nil, false
+			}
+			if index < 0 {
+				index += len(node)
+			}
+			if index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	if current == nil {
+		return nil, false
+	}
+	return current, true
+}
+
+// lookupString 取值并转换为字符串，非字符串的值按 JSON 编码
+func lookupString(root any, path string) (string, bool) {
+	value, ok := lookup(root, path)
+	if !ok {
+		return "", false
+	}
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(data), true
+	}
+}
+
+// lookupInt 取值并转换为整数，兼容数字字符串
+func lookupInt(root any, path string) (int64, bool) {
+	value, ok := lookup(root, path)
+	if !ok {
+		return 0, false
+	}
+	var text string
+	switch v := value.(type) {
+	case json.Number:
+		text = v.String()
+	case string:
+		text = v
+	default:
+		return 0, false
+	}
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return n, true
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return int64(f), true
+	}
+	return 0, false
+}
+
+// decodeJSON 解析 JSON，数字保留为 json.Number 以免丢失精度
+func decodeJSON(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var root any
+	if err := decoder.Decode(&root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
@@ -0,0 +1,176 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bestruirui/octopus/internal/transformer/model"
+)
+
+// refer: https://ai.google.dev/api/embeddings
+
+// MaxEmbeddingBatchSize batchEmbedContents 单次请求的最大条数，超出的输入由中转拆分为多个请求
+const MaxEmbeddingBatchSize = 100
+
+type embedContentRequest struct {
+	Model                string       `json:"model,omitempty"`
+	Content              embedContent `json:"content"`
+	TaskType             *string      `json:"taskType,omitempty"`
+	OutputDimensionality *int64       `json:"outputDimensionality,omitempty"`
+}
+
+type embedContent struct {
+	Parts []embedPart `json:"parts"`
+}
+
+type embedPart struct {
+	Text string `json:"text"`
+}
+
+type batchEmbedContentsRequest struct {
+	Requests []embedContentRequest `json:"requests"`
+}
+
+type contentEmbedding struct {
+	Values []float64 `json:"values"`
+}
+
+// embedContentResponse 同时兼容 embedContent 与 batchEmbedContents 的响应
+type embedContentResponse struct {
+	Embedding  *contentEmbedding  `json:"embedding,omitempty"`
+	Embeddings []contentEmbedding `json:"embeddings,omitempty"`
+}
+
+// EmbeddingOutbound Gemini 原生 embedding
+// 单条输入使用 embedContent，多条输入合并为 batchEmbedContents，超过 MaxEmbeddingBatchSize 条时由中转拆分为多个请求
+type EmbeddingOutbound struct {
+	model          string
+	encodingFormat string
+}
+
+func (o *EmbeddingOutbound) TransformRequest(ctx context.Context, request *model.InternalLLMRequest, baseUrl, key string) (*http.Request, error) {
+	if !request.IsEmbeddingRequest() {
+		return nil, errors.New("not an embedding request")
+	}
+	texts := request.EmbeddingInput.Texts()
+	if len(texts) == 0 {
+		return nil, errors.New("embedding input is empty")
+	}
+
+	o.model = request.Model
+	if request.EmbeddingEncodingFormat != nil {
+		o.encodingFormat = *request.EmbeddingEncodingFormat
+	}
+
+	modelName := request.Model
+	if !strings.Contains(modelName, "/") {
+		modelName = "models/" + modelName
+	}
+
+	var body any
+	method := "embedContent"
+	if request.EmbeddingInput.Single != nil {
+		body = embedContentRequest{
+			Content:              embedContent{Parts: []embedPart{{Text: texts[0]}}},
+			TaskType:             request.EmbeddingTaskType,
+			OutputDimensionality: request.EmbeddingDimensions,
+		}
+	} else {
+		method = "batchEmbedContents"
+		batch := batchEmbedContentsRequest{Requests: make([]embedContentRequest, 0, len(texts))}
+		for _, text := range texts {
+			batch.Requests = append(batch.Requests, embedContentRequest{
+				Model:                modelName,
+				Content:              embedContent{Parts: []embedPart{{Text: text}}},
+				TaskType:             request.EmbeddingTaskType,
+				OutputDimensionality: request.EmbeddingDimensions,
+			})
+		}
+		body = batch
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal gemini embedding request: %w", err)
+	}
+
+	parsedUrl, err := url.Parse(strings.TrimSuffix(baseUrl, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse base url: %w", err)
+	}
+	parsedUrl.Path = fmt.Sprintf("%s/%s:%s", parsedUrl.Path, modelName, method)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, parsedUrl.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Goog-Api-Key", key)
+	return req, nil
+}
+
+func (o *EmbeddingOutbound) TransformResponse(ctx context.Context, response *http.Response) (*model.InternalLLMResponse, error) {
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if len(body) == 0 {
+		return nil, fmt.Errorf("response body is empty")
+	}
+
+	var embedResp embedContentResponse
+	if err := json.Unmarshal(body, &embedResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal gemini embedding response: %w", err)
+	}
+
+	embeddings := embedResp.Embeddings
+	if embedResp.Embedding != nil {
+		embeddings = []contentEmbedding{*embedResp.Embedding}
+	}
+
+	data := make([]model.EmbeddingObject, 0, len(embeddings))
+	for i, embedding := range embeddings {
+		obj := model.EmbeddingObject{Object: "embedding", Index: i}
+		if o.encodingFormat == "base64" {
+			encoded := encodeFloat32Base64(embedding.Values)
+			obj.Embedding.Base64String = &encoded
+		} else {
+			obj.Embedding.FloatArray = embedding.Values
+		}
+		data = append(data, obj)
+	}
+
+	// Gemini embedding 不返回用量，由转发层估算
+	return &model.InternalLLMResponse{
+		Object:        "list",
+		Created:       time.Now().Unix(),
+		Model:         o.model,
+		EmbeddingData: data,
+	}, nil
+}
+
+func (o *EmbeddingOutbound) TransformStream(ctx context.Context, eventData []byte) (*model.InternalLLMResponse, error) {
+	// Embedding API does not support streaming
+	return nil, errors.New("streaming is not supported for embedding API")
+}
+
+// encodeFloat32Base64 按 OpenAI encoding_format=base64 的格式编码：float32 小端序后 base64
+func encodeFloat32Base64(values []float64) string {
+	buf := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(v)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
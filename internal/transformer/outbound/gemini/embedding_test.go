@@ -0,0 +1,71 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/samber/lo"
+)
+
+func TestEmbeddingTransformRequest(t *testing.T) {
+	o := &EmbeddingOutbound{}
+	req, err := o.TransformRequest(context.Background(), &model.InternalLLMRequest{
+		Model:               "gemini-embedding-001",
+		EmbeddingInput:      &model.EmbeddingInput{Multiple: []string{"a", "b"}},
+		EmbeddingDimensions: lo.ToPtr(int64(768)),
+	}, "https://generativelanguage.googleapis.com/v1beta/", "test-key")
+	if err != nil {
+		t.Fatalf("TransformRequest: %v", err)
+	}
+	if req.URL.String() != "https://generativelanguage.googleapis.com/v1beta/models/gemini-embedding-001:batchEmbedContents" {
+		t.Errorf("unexpected url: %s", req.URL)
+	}
+	if req.Header.Get("X-Goog-Api-Key") != "test-key" {
+		t.Errorf("missing api key header")
+	}
+	var batch batchEmbedContentsRequest
+	body, _ := io.ReadAll(req.Body)
+	if err := json.Unmarshal(body, &batch); err != nil {
+		t.Fatalf("invalid body: %v", err)
+	}
+	if len(batch.Requests) != 2 || batch.Requests[1].Model != "models/gemini-embedding-001" || batch.Requests[1].Content.Parts[0].Text != "b" || *batch.Requests[1].OutputDimensionality != 768 {
+		t.Errorf("unexpected batch body: %s", body)
+	}
+
+	req, err = o.TransformRequest(context.Background(), &model.InternalLLMRequest{
+		Model:          "gemini-embedding-001",
+		EmbeddingInput: &model.EmbeddingInput{Single: lo.ToPtr("hello")},
+	}, "https://generativelanguage.googleapis.com/v1beta", "test-key")
+	if err != nil {
+		t.Fatalf("TransformRequest: %v", err)
+	}
+	body, _ = io.ReadAll(req.Body)
+	if !strings.HasSuffix(req.URL.Path, "/models/gemini-embedding-001:embedContent") || string(body) != `{"content":{"parts":[{"text":"hello"}]}}` {
+		t.Errorf("unexpected single request: %s %s", req.URL, body)
+	}
+}
+
+func TestEmbeddingTransformResponse(t *testing.T) {
+	o := &EmbeddingOutbound{model: "gemini-embedding-001", encodingFormat: "base64"}
+	resp, err := o.TransformResponse(context.Background(), &http.Response{
+		Body: io.NopCloser(strings.NewReader(`{"embeddings":[{"values":[1,0]},{"values":[0.5]}]}`)),
+	})
+	if err != nil {
+		t.Fatalf("TransformResponse: %v", err)
+	}
+	if len(resp.EmbeddingData) != 2 || resp.EmbeddingData[1].Index != 1 || resp.Model != "gemini-embedding-001" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	// float32 小端序：1.0 = 0x3f800000，0.0 = 0，0.5 = 0x3f000000
+	if got := *resp.EmbeddingData[0].Embedding.Base64String; got != "AACAPwAAAAA=" {
+		t.Errorf("unexpected base64 embedding: %s", got)
+	}
+	if got := *resp.EmbeddingData[1].Embedding.Base64String; got != "AAAAPw==" {
+		t.Errorf("unexpected base64 embedding: %s", got)
+	}
+}
@@ -0,0 +1,84 @@
+package gemini
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bestruirui/octopus/internal/transformer/model"
+)
+
+// convertBuiltinTools 将内置工具映射为 Gemini 的 googleSearch / codeExecution
+func convertBuiltinTools(tools []model.Tool) []*model.GeminiTool {
+	var result []*model.GeminiTool
+	for _, tool := range tools {
+		switch tool.Type {
+		case "web_search":
+			result = append(result, &model.GeminiTool{GoogleSearch: &model.GeminiGoogleSearch{}})
+		case "code_execution":
+			result = append(result, &model.GeminiTool{CodeExecution: &model.GeminiCodeExecution{}})
+		}
+	}
+	return result
+}
+
+// convertGroundingMetadata 将搜索来源转换为内部引用
+// segment 的位置为 text 中的字节偏移，转换为字符偏移
+func convertGroundingMetadata(metadata *model.GeminiGroundingMetadata, text string) []model.Annotation {
+	if metadata == nil {
+		return nil
+	}
+	var annotations []model.Annotation
+	for _, support := range metadata.GroundingSupports {
+		if support == nil || support.Segment == nil {
+			continue
+		}
+		start := byteToRuneIndex(text, support.Segment.StartIndex)
+		end := byteToRuneIndex(text, support.Segment.EndIndex)
+		if start >= end {
+			continue
+		}
+		for _, index := range support.GroundingChunkIndices {
+			if index < 0 || index >= len(metadata.GroundingChunks) {
+				continue
+			}
+			chunk := metadata.GroundingChunks[index]
+			if chunk == nil || chunk.Web == nil || chunk.Web.URI == "" {
+				continue
+			}
+			annotations = append(annotations, model.Annotation{
+				Type: "url_citation",
+				URLCitation: &model.URLCitation{
+					StartIndex: start,
+					EndIndex:   end,
+					URL:        chunk.Web.URI,
+					Title:      chunk.Web.Title,
+				},
+			})
+		}
+	}
+	return annotations
+}
+
+func byteToRuneIndex(text string, index int) int {
+	index = min(max(index, 0), len(text))
+	return utf8.RuneCountInString(text[:index])
+}
+
+// codeExecutionText 将代码执行的代码和结果渲染为 Markdown 文本，避免内容丢失
+func codeExecutionText(part *model.GeminiPart) string {
+	if part.ExecutableCode != nil {
+		return fmt.Sprintf("\n```%s\n%s\n```\n", languageTag(part.ExecutableCode.Language), part.ExecutableCode.Code)
+	}
+	if part.CodeExecutionResult != nil && part.CodeExecutionResult.Output != "" {
+		return fmt.Sprintf("\n```\n%s\n```\n", part.CodeExecutionResult.Output)
+	}
+	return ""
+}
+
+func languageTag(language string) string {
+	if language == "LANGUAGE_UNSPECIFIED" {
+		return ""
+	}
+	return strings.ToLower(language)
+}
@@ -16,9 +16,19 @@ import (
 	"github.com/samber/lo"
 )
 
-type MessagesOutbound struct{}
+type MessagesOutbound struct {
+	// streamText 每个候选已输出的文本，搜索来源的位置相对于完整的回复文本
+	streamText map[int]*strings.Builder
+}
 
 func (o *MessagesOutbound) TransformRequest(ctx context.Context, request *model.InternalLLMRequest, baseUrl, key string) (*http.Request, error) {
+	// Gemini 不支持引用远程图片和文档，下载后内联
+	copied := *request
+	request = &copied
+	if err := request.InlineRemoteMedia(ctx, model.RemoteMediaImage|model.RemoteMediaFile); err != nil {
+		return nil, err
+	}
+
 	// Convert internal request to Gemini format
 	geminiReq := convertLLMToGeminiRequest(request)
 
@@ -100,27 +110,40 @@ func (o *MessagesOutbound) TransformStream(ctx context.Context, eventData []byte
 	}
 
 	// Convert to internal format
-	return convertGeminiToLLMResponse(&geminiResp, true), nil
+	resp := convertGeminiToLLMResponse(&geminiResp, true)
+	o.attachStreamGrounding(&geminiResp, resp)
+	return resp, nil
 }
 
-// Helper functions
+// attachStreamGrounding 记录已输出的文本，并将搜索来源转换为引用附加到对应候选的增量中
+func (o *MessagesOutbound) attachStreamGrounding(geminiResp *model.GeminiGenerateContentResponse, resp *model.InternalLLMResponse) {
+	if o.streamText == nil {
+		o.streamText = make(map[int]*strings.Builder)
+	}
+	for i := range resp.Choices {
+		choice := &resp.Choices[i]
+		text, ok := o.streamText[choice.Index]
+		if !ok {
+			text = &strings.Builder{}
+			o.streamText[choice.Index] = text
+		}
+		if choice.Delta != nil && choice.Delta.Content.Content != nil {
+			text.WriteString(*choice.Delta.Content.Content)
+		}
 
-// reasoningToThinkingBudget maps reasoning effort levels to thinking budget in tokens
-// https://ai.google.dev/gemini-api/docs/thinking
-func reasoningToThinkingBudget(effort string) int32 {
-	switch strings.ToLower(effort) {
-	case "low":
-		return 1024
-	case "medium":
-		return 4096
-	case "high":
-		return 24576
-	default:
-		// 防御性：未知值走动态
-		return -1
+		annotations := convertGroundingMetadata(geminiResp.Candidates[i].GroundingMetadata, text.String())
+		if len(annotations) == 0 {
+			continue
+		}
+		if choice.Delta == nil {
+			choice.Delta = &model.Message{Role: "assistant"}
+		}
+		choice.Delta.Annotations = annotations
 	}
 }
 
+// Helper functions
+
 func audioTypeToMimeType(format string) string {
 	switch format {
 	case "wav":
@@ -273,6 +296,10 @@ func convertLLMToGeminiRequest(request *model.InternalLLMRequest) *model.GeminiG
 		config.TopP = request.TopP
 		hasConfig = true
 	}
+	if request.N != nil {
+		config.CandidateCount = int(*request.N)
+		hasConfig = true
+	}
 	// TopK is stored in metadata if present
 	if topKStr, ok := request.TransformerMetadata["gemini_top_k"]; ok {
 		var topK int
@@ -288,12 +315,13 @@ func convertLLMToGeminiRequest(request *model.InternalLLMRequest) *model.GeminiG
 		hasConfig = true
 	}
 
-	if request.ReasoningEffort != "" {
-		budget := reasoningToThinkingBudget(request.ReasoningEffort)
+	if request.ReasoningEffort != "" || request.ReasoningBudget != nil {
+		// 预算为 0 时关闭思考，-1 时由模型动态决定
+		budget := int32(request.ReasoningBudgetTokens())
 
 		config.ThinkingConfig = &model.GeminiThinkingConfig{
 			ThinkingBudget:  &budget,
-			IncludeThoughts: true,
+			IncludeThoughts: budget != 0,
 		}
 		hasConfig = true
 	}
@@ -306,7 +334,14 @@ func convertLLMToGeminiRequest(request *model.InternalLLMRequest) *model.GeminiG
 			hasConfig = true
 		case "json_schema":
 			config.ResponseMimeType = "application/json"
-			// TODO: Convert JSON schema to Gemini schema format if schema is provided
+			// 转换为 Gemini 支持的 OpenAPI 子集
+			if format := request.ResponseFormat.GetJSONSchema(); format != nil && len(format.Schema) > 0 {
+				var schema map[string]any
+				if err := json.Unmarshal(format.Schema, &schema); err == nil {
+					cleanGeminiSchema(schema)
+					config.ResponseSchema = schema
+				}
+			}
 			hasConfig = true
 		case "text":
 			config.ResponseMimeType = "text/plain"
@@ -366,6 +401,7 @@ func convertLLMToGeminiRequest(request *model.InternalLLMRequest) *model.GeminiG
 		if len(functionDeclarations) > 0 {
 			geminiReq.Tools = []*model.GeminiTool{{FunctionDeclarations: functionDeclarations}}
 		}
+		geminiReq.Tools = append(geminiReq.Tools, convertBuiltinTools(request.Tools)...)
 	}
 
 	// Convert tool choice to Gemini toolConfig.functionCallingConfig
@@ -469,10 +505,9 @@ func convertGeminiToLLMResponse(geminiResp *model.GeminiGenerateContentResponse,
 					if part.Text != "" && reasoningContent == nil {
 						reasoningContent = &part.Text
 					}
-				} else if part.Text != "" {
-					textParts = append(textParts, part.Text)
+				} else if text := part.Text + codeExecutionText(part); text != "" {
+					textParts = append(textParts, text)
 					// Also add to content parts for multimodal response
-					text := part.Text
 					contentParts = append(contentParts, model.MessageContentPart{
 						Type: "text",
 						Text: &text,
@@ -517,6 +552,11 @@ func convertGeminiToLLMResponse(geminiResp *model.GeminiGenerateContentResponse,
 				}
 			}
 
+			// Stream grounding is attached by the outbound with the accumulated text
+			if !isStream {
+				msg.Annotations = convertGroundingMetadata(candidate.GroundingMetadata, strings.Join(textParts, ""))
+			}
+
 			// Set reasoning content
 			if reasoningContent != nil {
 				msg.ReasoningContent = reasoningContent
@@ -0,0 +1,300 @@
+package mock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
+
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/bestruirui/octopus/internal/transformer/outbound/openai"
+	"github.com/bestruirui/octopus/internal/utils/tokenizer"
+	"github.com/samber/lo"
+)
+
+// Outbound 模拟渠道，不访问网络，按配置生成 OpenAI Chat 格式的响应
+// 用于压测及验证分组的负载均衡、重试和超时行为
+type Outbound struct {
+	// 响应的解析复用 OpenAI Chat 出站
+	openai.ChatOutbound
+
+	options *model.MockOptions
+	request *model.InternalLLMRequest
+}
+
+type failure int
+
+const (
+	failureNone failure = iota
+	failureRateLimit
+	failureServerError
+	failureTimeout
+	failureDrop
+)
+
+func (o *Outbound) SetChannelOptions(options *model.ChannelOptions) {
+	if options != nil {
+		o.options = options.Mock
+	}
+}
+
+func (o *Outbound) TransformRequest(ctx context.Context, request *model.InternalLLMRequest, baseUrl, key string) (*http.Request, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request is nil")
+	}
+	if !request.IsChatRequest() {
+		return nil, fmt.Errorf("mock channel only supports chat requests")
+	}
+	o.request = request
+	if o.options == nil {
+		o.options = &model.MockOptions{}
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "mock://octopus/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// RoundTrip 生成模拟响应，并触发 httptrace 回调以便连接、响应头超时按真实请求的方式生效
+func (o *Outbound) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if o.request == nil {
+		return nil, fmt.Errorf("request has not been transformed")
+	}
+	if trace := httptrace.ContextClientTrace(ctx); trace != nil {
+		if trace.GotConn != nil {
+			trace.GotConn(httptrace.GotConnInfo{})
+		}
+		if trace.WroteRequest != nil {
+			trace.WroteRequest(httptrace.WroteRequestInfo{})
+		}
+	}
+
+	fail := o.pickFailure()
+	if fail == failureTimeout {
+		<-ctx.Done()
+		return nil, context.Cause(ctx)
+	}
+	if err := sleep(ctx, time.Duration(o.options.LatencyMs)*time.Millisecond); err != nil {
+		return nil, err
+	}
+
+	switch fail {
+	case failureRateLimit:
+		return errorResponse(req, http.StatusTooManyRequests, "rate_limit_exceeded", "mock rate limit"), nil
+	case failureServerError:
+		return errorResponse(req, http.StatusInternalServerError, "server_error", "mock server error"), nil
+	}
+
+	content := o.options.Response
+	if content == "" {
+		content = lastUserText(o.request)
+	}
+	usage := o.usage(content)
+	id := fmt.Sprintf("chatcmpl-mock-%d", time.Now().UnixNano())
+
+	if o.request.Stream == nil || !*o.request.Stream {
+		body, err := json.Marshal(model.InternalLLMResponse{
+			ID:      id,
+			Object:  "chat.completion",
+			Created: time.Now().Unix(),
+			Model:   o.request.Model,
+			Choices: []model.Choice{{
+				Message:      &model.Message{Role: "assistant", Content: model.MessageContent{Content: &content}},
+				FinishReason: lo.ToPtr("stop"),
+			}},
+			Usage: usage,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return newResponse(req, http.StatusOK, "application/json", io.NopCloser(bytes.NewReader(body))), nil
+	}
+
+	reader, writer := io.Pipe()
+	go o.writeStream(ctx, writer, id, content, usage, fail == failureDrop)
+	return newResponse(req, http.StatusOK, "text/event-stream", reader), nil
+}
+
+// writeStream 按配置的速度逐个输出 Token，断开注入时在输出一半后中断
+func (o *Outbound) writeStream(ctx context.Context, writer *io.PipeWriter, id, content string, usage *model.Usage, drop bool) {
+	created := time.Now().Unix()
+	chunk := func(delta *model.Message, finishReason *string, usage *model.Usage) error {
+		choices := []model.Choice{{Delta: delta, FinishReason: finishReason}}
+		if delta == nil && finishReason == nil {
+			choices = []model.Choice{}
+		}
+		data, err := json.Marshal(model.InternalLLMResponse{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   o.request.Model,
+			Choices: choices,
+			Usage:   usage,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(writer, "data: %s\n\n", data)
+		return err
+	}
+
+	err := func() error {
+		if err := sleep(ctx, time.Duration(o.options.FirstTokenDelayMs)*time.Millisecond); err != nil {
+			return err
+		}
+		if err := chunk(&model.Message{Role: "assistant"}, nil, nil); err != nil {
+			return err
+		}
+
+		var interval time.Duration
+		if o.options.TokensPerSecond > 0 {
+			interval = time.Duration(float64(time.Second) / o.options.TokensPerSecond)
+		}
+		tokens := splitTokens(content)
+		for i, token := range tokens {
+			if drop && i >= len(tokens)/2 {
+				return io.ErrUnexpectedEOF
+			}
+			if i > 0 {
+				if err := sleep(ctx, interval); err != nil {
+					return err
+				}
+			}
+			if err := chunk(&model.Message{Content: model.MessageContent{Content: &token}}, nil, nil); err != nil {
+				return err
+			}
+		}
+		if drop {
+			return io.ErrUnexpectedEOF
+		}
+
+		if err := chunk(&model.Message{}, lo.ToPtr("stop"), nil); err != nil {
+			return err
+		}
+		if err := chunk(nil, nil, usage); err != nil {
+			return err
+		}
+		_, err := io.WriteString(writer, "data: [DONE]\n\n")
+		return err
+	}()
+	writer.CloseWithError(err)
+}
+
+// pickFailure 按配置的概率抽取本次请求注入的错误
+func (o *Outbound) pickFailure() failure {
+	r := rand.Float64()
+	for _, candidate := range []struct {
+		rate    float64
+		failure failure
+	}{
+		{o.options.RateLimitRate, failureRateLimit},
+		{o.options.ServerErrorRate, failureServerError},
+		{o.options.TimeoutRate, failureTimeout},
+		{o.options.DropRate, failureDrop},
+	} {
+		if r < candidate.rate {
+			return candidate.failure
+		}
+		r -= candidate.rate
+	}
+	return failureNone
+}
+
+func (o *Outbound) usage(content string) *model.Usage {
+	var promptTokens int64
+	for _, message := range o.request.Messages {
+		promptTokens += int64(tokenizer.CountTokens(messageText(message.Content), o.request.Model))
+	}
+	completionTokens := int64(tokenizer.CountTokens(content, o.request.Model))
+	return &model.Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-timer.C:
+		return nil
+	}
+}
+
+// splitTokens 按单词切分输出，空白字符归入前一个片段，以近似逐 Token 输出
+func splitTokens(content string) []string {
+	var tokens []string
+	start := 0
+	for i, r := range content {
+		if r == ' ' || r == '\n' {
+			tokens = append(tokens, content[start:i+1])
+			start = i + 1
+		}
+	}
+	if start < len(content) {
+		tokens = append(tokens, content[start:])
+	}
+	return tokens
+}
+
+func lastUserText(request *model.InternalLLMRequest) string {
+	for i := len(request.Messages) - 1; i >= 0; i-- {
+		if request.Messages[i].Role == "user" {
+			return messageText(request.Messages[i].Content)
+		}
+	}
+	return ""
+}
+
+func messageText(content model.MessageContent) string {
+	if content.Content != nil {
+		return *content.Content
+	}
+	var parts []string
+	for _, part := range content.MultipleContent {
+		if part.Type == "text" && part.Text != nil {
+			parts = append(parts, *part.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func errorResponse(req *http.Request, statusCode int, errorType, message string) *http.Response {
+	body, _ := json.Marshal(map[string]any{
+		"error": map[string]any{"type": errorType, "message": message},
+	})
+	return newResponse(req, statusCode, "application/json", io.NopCloser(bytes.NewReader(body)))
+}
+
+func newResponse(req *http.Request, statusCode int, contentType string, body io.ReadCloser) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode: statusCode,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{contentType}},
+		Body:       body,
+		Request:    req,
+	}
+}
@@ -0,0 +1,89 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/samber/lo"
+	"github.com/tmaxmax/go-sse"
+)
+
+func newRequest(stream bool) *model.InternalLLMRequest {
+	return &model.InternalLLMRequest{
+		Model:    "mock",
+		Stream:   lo.ToPtr(stream),
+		Messages: []model.Message{{Role: "user", Content: model.MessageContent{Content: lo.ToPtr("hello mock world")}}},
+	}
+}
+
+func roundTrip(t *testing.T, o *Outbound, request *model.InternalLLMRequest) *http.Response {
+	t.Helper()
+	req, err := o.TransformRequest(context.Background(), request, "", "")
+	if err != nil {
+		t.Fatalf("TransformRequest: %v", err)
+	}
+	resp, err := o.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	return resp
+}
+
+func TestEchoResponse(t *testing.T) {
+	o := &Outbound{}
+	resp := roundTrip(t, o, newRequest(false))
+	defer resp.Body.Close()
+
+	internal, err := o.TransformResponse(context.Background(), resp)
+	if err != nil {
+		t.Fatalf("TransformResponse: %v", err)
+	}
+	if got := *internal.Choices[0].Message.Content.Content; got != "hello mock world" {
+		t.Errorf("content = %q", got)
+	}
+	if internal.Usage == nil || internal.Usage.CompletionTokens == 0 {
+		t.Errorf("usage = %+v", internal.Usage)
+	}
+}
+
+func TestInjectedErrors(t *testing.T) {
+	o := &Outbound{}
+	o.SetChannelOptions(&model.ChannelOptions{Mock: &model.MockOptions{RateLimitRate: 1}})
+	resp := roundTrip(t, o, newRequest(false))
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", resp.StatusCode)
+	}
+
+	o = &Outbound{}
+	o.SetChannelOptions(&model.ChannelOptions{Mock: &model.MockOptions{DropRate: 1, Response: "one two three four"}})
+	resp = roundTrip(t, o, newRequest(true))
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var readErr error
+	for ev, err := range sse.Read(resp.Body, nil) {
+		if err != nil {
+			readErr = err
+			break
+		}
+		chunk, err := o.TransformStream(context.Background(), []byte(ev.Data))
+		if err != nil {
+			t.Fatalf("TransformStream: %v", err)
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content.Content != nil {
+			content.WriteString(*chunk.Choices[0].Delta.Content.Content)
+		}
+	}
+	if !errors.Is(readErr, io.ErrUnexpectedEOF) {
+		t.Errorf("read error = %v, want unexpected EOF", readErr)
+	}
+	if got := content.String(); got != "one two " {
+		t.Errorf("content before drop = %q", got)
+	}
+}
@@ -0,0 +1,448 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/bestruirui/octopus/internal/utils/xurl"
+	"github.com/samber/lo"
+)
+
+// Outbound Ollama 原生接口，chat 请求使用 /api/chat，embedding 请求使用 /api/embed
+// 流式响应为 NDJSON，每行一个 JSON 对象
+type Outbound struct {
+	options   *model.ChannelOptions
+	embedding bool
+
+	// Stream state tracking
+	streamID      string
+	streamCreated int64
+	toolCallIndex int
+}
+
+func (o *Outbound) SetChannelOptions(options *model.ChannelOptions) {
+	o.options = options
+}
+
+func (o *Outbound) TransformRequest(ctx context.Context, request *model.InternalLLMRequest, baseUrl, key string) (*http.Request, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request is nil")
+	}
+
+	var extra extraBody
+	if len(request.ExtraBody) > 0 {
+		if err := json.Unmarshal(request.ExtraBody, &extra); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal extra body: %w", err)
+		}
+	}
+
+	var body any
+	path := "/api/chat"
+	if request.IsEmbeddingRequest() {
+		o.embedding = true
+		path = "/api/embed"
+		body = o.convertEmbedRequest(request, &extra)
+	} else {
+		// Ollama 只接受 base64 图片，远程图片下载后内联
+		copied := *request
+		request = &copied
+		if err := request.InlineRemoteMedia(ctx, model.RemoteMediaImage); err != nil {
+			return nil, err
+		}
+		body = o.convertChatRequest(request, &extra)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ollama request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	// Ollama 本身不需要认证，部署在鉴权代理后时使用 Bearer
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	parsedUrl, err := url.Parse(strings.TrimSuffix(baseUrl, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse base url: %w", err)
+	}
+	parsedUrl.Path = parsedUrl.Path + path
+	req.URL = parsedUrl
+	return req, nil
+}
+
+func (o *Outbound) TransformResponse(ctx context.Context, response *http.Response) (*model.InternalLLMResponse, error) {
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if len(body) == 0 {
+		return nil, fmt.Errorf("response body is empty")
+	}
+
+	if o.embedding {
+		var embedResp EmbedResponse
+		if err := json.Unmarshal(body, &embedResp); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal ollama embed response: %w", err)
+		}
+		if embedResp.Error != "" {
+			return nil, &model.ResponseError{Detail: model.ErrorDetail{Message: embedResp.Error}}
+		}
+		return convertEmbedResponse(&embedResp), nil
+	}
+
+	var chatResp ChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ollama chat response: %w", err)
+	}
+	if chatResp.Error != "" {
+		return nil, &model.ResponseError{Detail: model.ErrorDetail{Message: chatResp.Error}}
+	}
+
+	message := convertResponseMessage(&chatResp.Message, 0)
+	return &model.InternalLLMResponse{
+		ID:      fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano()),
+		Object:  "chat.completion",
+		Created: parseCreatedAt(chatResp.CreatedAt),
+		Model:   chatResp.Model,
+		Choices: []model.Choice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: convertDoneReason(chatResp.DoneReason, len(message.ToolCalls) > 0),
+		}},
+		Usage: convertUsage(&chatResp),
+	}, nil
+}
+
+func (o *Outbound) TransformStream(ctx context.Context, eventData []byte) (*model.InternalLLMResponse, error) {
+	if bytes.HasPrefix(eventData, []byte("[DONE]")) {
+		return &model.InternalLLMResponse{
+			Object: "[DONE]",
+		}, nil
+	}
+	if len(bytes.TrimSpace(eventData)) == 0 {
+		return nil, nil
+	}
+
+	var chunk ChatResponse
+	if err := json.Unmarshal(eventData, &chunk); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ollama stream chunk: %w", err)
+	}
+	if chunk.Error != "" {
+		return nil, &model.ResponseError{Detail: model.ErrorDetail{Message: chunk.Error}}
+	}
+
+	if o.streamID == "" {
+		o.streamID = fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+		o.streamCreated = parseCreatedAt(chunk.CreatedAt)
+	}
+
+	delta := convertResponseMessage(&chunk.Message, o.toolCallIndex)
+	o.toolCallIndex += len(delta.ToolCalls)
+	choice := model.Choice{Index: 0, Delta: delta}
+	resp := &model.InternalLLMResponse{
+		ID:      o.streamID,
+		Object:  "chat.completion.chunk",
+		Created: o.streamCreated,
+		Model:   chunk.Model,
+		Choices: []model.Choice{choice},
+	}
+	if chunk.Done {
+		resp.Choices[0].FinishReason = convertDoneReason(chunk.DoneReason, o.toolCallIndex > 0)
+		resp.Usage = convertUsage(&chunk)
+	}
+	return resp, nil
+}
+
+// DecodeStream 按行读取 NDJSON，结束后追加 [DONE]
+func (o *Outbound) DecodeStream(body io.Reader) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		reader := bufio.NewReader(body)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				if !yield(line, nil) {
+					return
+				}
+			}
+			if errors.Is(err, io.EOF) {
+				yield([]byte("[DONE]"), nil)
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+	}
+}
+
+func (o *Outbound) convertChatRequest(request *model.InternalLLMRequest, extra *extraBody) *ChatRequest {
+	chatReq := &ChatRequest{
+		Model:    request.Model,
+		Messages: convertMessages(request.Messages),
+		Tools: lo.Reject(request.Tools, func(tool model.Tool, _ int) bool {
+			return tool.IsBuiltin()
+		}),
+		Stream:  request.Stream != nil && *request.Stream,
+		Options: o.modelOptions(),
+	}
+
+	// 标准参数映射到 options
+	if request.Temperature != nil {
+		chatReq.Options["temperature"] = *request.Temperature
+	}
+	if request.TopP != nil {
+		chatReq.Options["top_p"] = *request.TopP
+	}
+	if request.Seed != nil {
+		chatReq.Options["seed"] = *request.Seed
+	}
+	if request.FrequencyPenalty != nil {
+		chatReq.Options["frequency_penalty"] = *request.FrequencyPenalty
+	}
+	if request.PresencePenalty != nil {
+		chatReq.Options["presence_penalty"] = *request.PresencePenalty
+	}
+	if request.MaxCompletionTokens != nil {
+		chatReq.Options["num_predict"] = *request.MaxCompletionTokens
+	} else if request.MaxTokens != nil {
+		chatReq.Options["num_predict"] = *request.MaxTokens
+	}
+	if request.Stop != nil {
+		if request.Stop.Stop != nil {
+			chatReq.Options["stop"] = []string{*request.Stop.Stop}
+		} else if len(request.Stop.MultipleStop) > 0 {
+			chatReq.Options["stop"] = request.Stop.MultipleStop
+		}
+	}
+
+	// 结构化输出
+	if request.ResponseFormat != nil {
+		switch request.ResponseFormat.Type {
+		case "json_object":
+			chatReq.Format = json.RawMessage(`"json"`)
+		case "json_schema":
+			var jsonSchema struct {
+				Schema json.RawMessage `json:"schema"`
+			}
+			if err := json.Unmarshal(request.ResponseFormat.JSONSchema, &jsonSchema); err == nil && len(jsonSchema.Schema) > 0 {
+				chatReq.Format = jsonSchema.Schema
+			}
+		}
+	}
+
+	// 思考开关
+	// 预算为 0 时关闭思考
+	if request.ReasoningEffort != "" {
+		switch {
+		case request.ReasoningBudgetTokens() == 0:
+			chatReq.Think = false
+		case strings.Contains(strings.ToLower(request.Model), "gpt-oss") && !request.GetReasoningMapping().Unsupported:
+			// 仅 gpt-oss 支持思考等级，其余模型只能开关
+			chatReq.Think = request.ReasoningEffort
+		default:
+			chatReq.Think = true
+		}
+	}
+	if request.EnableThinking != nil {
+		chatReq.Think = *request.EnableThinking
+	}
+
+	if o.options != nil {
+		chatReq.KeepAlive = o.options.KeepAlive
+	}
+	o.applyExtraBody(&chatReq.Options, &chatReq.KeepAlive, extra)
+	if extra.Think != nil {
+		chatReq.Think = extra.Think
+	}
+	if len(chatReq.Options) == 0 {
+		chatReq.Options = nil
+	}
+	return chatReq
+}
+
+func (o *Outbound) convertEmbedRequest(request *model.InternalLLMRequest, extra *extraBody) *EmbedRequest {
+	embedReq := &EmbedRequest{
+		Model:      request.Model,
+		Input:      *request.EmbeddingInput,
+		Dimensions: request.EmbeddingDimensions,
+		Options:    o.modelOptions(),
+	}
+	if o.options != nil {
+		embedReq.KeepAlive = o.options.KeepAlive
+	}
+	o.applyExtraBody(&embedReq.Options, &embedReq.KeepAlive, extra)
+	if len(embedReq.Options) == 0 {
+		embedReq.Options = nil
+	}
+	return embedReq
+}
+
+// modelOptions 返回渠道配置的默认 options 副本
+func (o *Outbound) modelOptions() map[string]any {
+	options := make(map[string]any)
+	if o.options != nil {
+		maps.Copy(options, o.options.ModelOptions)
+	}
+	return options
+}
+
+// applyExtraBody 请求 extra_body 中的 options 和 keep_alive 优先级最高
+func (o *Outbound) applyExtraBody(options *map[string]any, keepAlive *any, extra *extraBody) {
+	maps.Copy(*options, extra.Options)
+	if extra.KeepAlive != nil {
+		*keepAlive = extra.KeepAlive
+	}
+}
+
+func convertMessages(messages []model.Message) []Message {
+	// 记录工具调用 ID 对应的函数名，用于填充工具结果的 tool_name
+	toolNames := make(map[string]string)
+	result := make([]Message, 0, len(messages))
+	for _, msg := range messages {
+		role := msg.Role
+		if role == "developer" {
+			role = "system"
+		}
+		m := Message{Role: role}
+
+		if msg.Content.Content != nil {
+			m.Content = *msg.Content.Content
+		}
+		var texts []string
+		for _, part := range msg.Content.MultipleContent {
+			switch {
+			case part.Text != nil:
+				texts = append(texts, *part.Text)
+			case part.ImageURL != nil && xurl.IsDataURL(part.ImageURL.URL):
+				// Ollama 只接受 base64 图片
+				m.Images = append(m.Images, xurl.ExtractBase64FromDataURL(part.ImageURL.URL))
+			}
+		}
+		if len(texts) > 0 {
+			m.Content = strings.Join(texts, "\n")
+		}
+
+		if reasoning := msg.GetReasoningContent(); reasoning != "" && role == "assistant" {
+			m.Thinking = reasoning
+		}
+		for _, toolCall := range msg.ToolCalls {
+			toolNames[toolCall.ID] = toolCall.Function.Name
+			arguments := json.RawMessage(toolCall.Function.Arguments)
+			if !json.Valid(arguments) {
+				arguments = json.RawMessage("{}")
+			}
+			m.ToolCalls = append(m.ToolCalls, ToolCall{
+				Function: ToolFunction{Name: toolCall.Function.Name, Arguments: arguments},
+			})
+		}
+		if role == "tool" {
+			if msg.ToolCallName != nil {
+				m.ToolName = *msg.ToolCallName
+			} else if msg.ToolCallID != nil {
+				m.ToolName = toolNames[*msg.ToolCallID]
+			}
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+func convertResponseMessage(msg *Message, toolCallIndex int) *model.Message {
+	result := &model.Message{Role: "assistant"}
+	if msg.Content != "" {
+		content := msg.Content
+		result.Content.Content = &content
+	}
+	if msg.Thinking != "" {
+		thinking := msg.Thinking
+		result.ReasoningContent = &thinking
+	}
+	for i, toolCall := range msg.ToolCalls {
+		index := toolCallIndex + i
+		id := toolCall.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%s_%d", toolCall.Function.Name, index)
+		}
+		arguments := string(toolCall.Function.Arguments)
+		if arguments == "" || arguments == "null" {
+			arguments = "{}"
+		}
+		result.ToolCalls = append(result.ToolCalls, model.ToolCall{
+			ID:    id,
+			Type:  "function",
+			Index: index,
+			Function: model.FunctionCall{
+				Name:      toolCall.Function.Name,
+				Arguments: arguments,
+			},
+		})
+	}
+	return result
+}
+
+func convertEmbedResponse(resp *EmbedResponse) *model.InternalLLMResponse {
+	data := make([]model.EmbeddingObject, 0, len(resp.Embeddings))
+	for i, embedding := range resp.Embeddings {
+		data = append(data, model.EmbeddingObject{
+			Object:    "embedding",
+			Index:     i,
+			Embedding: model.Embedding{FloatArray: embedding},
+		})
+	}
+	return &model.InternalLLMResponse{
+		Object:        "list",
+		Created:       time.Now().Unix(),
+		Model:         resp.Model,
+		EmbeddingData: data,
+		Usage: &model.Usage{
+			PromptTokens: resp.PromptEvalCount,
+			TotalTokens:  resp.PromptEvalCount,
+		},
+	}
+}
+
+func convertDoneReason(reason string, hasToolCalls bool) *string {
+	finishReason := "stop"
+	switch {
+	case hasToolCalls:
+		finishReason = "tool_calls"
+	case reason == "length":
+		finishReason = "length"
+	}
+	return &finishReason
+}
+
+func convertUsage(resp *ChatResponse) *model.Usage {
+	return &model.Usage{
+		PromptTokens:     resp.PromptEvalCount,
+		CompletionTokens: resp.EvalCount,
+		TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+	}
+}
+
+func parseCreatedAt(createdAt string) int64 {
+	if t, err := time.Parse(time.RFC3339Nano, createdAt); err == nil {
+		return t.Unix()
+	}
+	return time.Now().Unix()
+}
@@ -0,0 +1,77 @@
+package ollama
+
+import (
+	"encoding/json"
+
+	"github.com/bestruirui/octopus/internal/transformer/model"
+)
+
+// refer: https://github.com/ollama/ollama/blob/main/docs/api.md
+
+// ChatRequest /api/chat 请求
+type ChatRequest struct {
+	Model     string          `json:"model"`
+	Messages  []Message       `json:"messages"`
+	Tools     []model.Tool    `json:"tools,omitempty"`
+	Format    json.RawMessage `json:"format,omitempty"`
+	Options   map[string]any  `json:"options,omitempty"`
+	Stream    bool            `json:"stream"`
+	KeepAlive any             `json:"keep_alive,omitempty"`
+	Think     any             `json:"think,omitempty"`
+}
+
+type Message struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	Thinking  string     `json:"thinking,omitempty"`
+	Images    []string   `json:"images,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	ToolName  string     `json:"tool_name,omitempty"`
+}
+
+type ToolCall struct {
+	ID       string       `json:"id,omitempty"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Index     int             `json:"index,omitempty"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// ChatResponse /api/chat 非流式响应和每一行流式响应
+type ChatResponse struct {
+	Model           string  `json:"model"`
+	CreatedAt       string  `json:"created_at"`
+	Message         Message `json:"message"`
+	Done            bool    `json:"done"`
+	DoneReason      string  `json:"done_reason,omitempty"`
+	PromptEvalCount int64   `json:"prompt_eval_count,omitempty"`
+	EvalCount       int64   `json:"eval_count,omitempty"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// EmbedRequest /api/embed 请求
+type EmbedRequest struct {
+	Model      string               `json:"model"`
+	Input      model.EmbeddingInput `json:"input"`
+	Dimensions *int64               `json:"dimensions,omitempty"`
+	Options    map[string]any       `json:"options,omitempty"`
+	KeepAlive  any                  `json:"keep_alive,omitempty"`
+}
+
+// EmbedResponse /api/embed 响应
+type EmbedResponse struct {
+	Model           string      `json:"model"`
+	Embeddings      [][]float64 `json:"embeddings"`
+	PromptEvalCount int64       `json:"prompt_eval_count,omitempty"`
+	Error           string      `json:"error,omitempty"`
+}
+
+// extraBody 请求 extra_body 中可覆盖的 Ollama 参数
+type extraBody struct {
+	Options   map[string]any `json:"options,omitempty"`
+	KeepAlive any            `json:"keep_alive,omitempty"`
+	Think     any            `json:"think,omitempty"`
+}
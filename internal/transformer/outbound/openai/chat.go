@@ -11,12 +11,33 @@ import (
 	"strings"
 
 	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/samber/lo"
 )
 
-type ChatOutbound struct{}
+type ChatOutbound struct {
+	options *model.ChannelOptions
+	// extractThink 是否从正文中提取 <think> 标签内的推理内容，由渠道选项和请求的模型决定
+	extractThink bool
+	// thinkParsers 流式响应中各 choice 的 <think> 标签解析状态
+	thinkParsers map[int]*thinkTagParser
+}
+
+func (o *ChatOutbound) SetChannelOptions(options *model.ChannelOptions) {
+	o.options = options
+}
 
 func (o *ChatOutbound) TransformRequest(ctx context.Context, request *model.InternalLLMRequest, baseUrl, key string) (*http.Request, error) {
+	o.extractThink = o.options.ExtractsThinkTags(request.Model)
+	// 推理参数和内置工具的转换只作用于本次请求，重试其他渠道时保持原请求不变
+	copied := *request
+	request = &copied
+	applyReasoningMapping(request)
 	request.ClearHelpFields()
+	convertBuiltinTools(request)
+	// Chat Completions 的 file 仅支持 file_data 和 file_id，远程文档下载后内联
+	if err := request.InlineRemoteMedia(ctx, model.RemoteMediaFile); err != nil {
+		return nil, err
+	}
 
 	// Convert developer role to system role for compatibility
 	for i := range request.Messages {
@@ -71,6 +92,11 @@ func (o *ChatOutbound) TransformResponse(ctx context.Context, response *http.Res
 	if err := json.Unmarshal(body, &resp); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
+	if o.extractThink {
+		for i := range resp.Choices {
+			extractThinkTag(resp.Choices[i].Message)
+		}
+	}
 	return &resp, nil
 }
 
@@ -94,5 +120,46 @@ func (o *ChatOutbound) TransformStream(ctx context.Context, eventData []byte) (*
 	if err := json.Unmarshal(eventData, &resp); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal stream chunk: %w", err)
 	}
+	if o.extractThink {
+		o.extractThinkTagStream(&resp)
+	}
 	return &resp, nil
 }
+
+// convertBuiltinTools Chat Completions 不支持内置工具，联网搜索转换为 web_search_options，其余内置工具丢弃
+func convertBuiltinTools(request *model.InternalLLMRequest) {
+	if tool := request.BuiltinTool("web_search"); tool != nil && request.WebSearchOptions == nil {
+		options := &model.WebSearchOptions{}
+		if tool.WebSearch != nil {
+			options.SearchContextSize = tool.WebSearch.SearchContextSize
+			if tool.WebSearch.UserLocation != nil {
+				options.UserLocation = &model.WebSearchOptionsUserLocation{Type: "approximate", Approximate: tool.WebSearch.UserLocation}
+			}
+		}
+		request.WebSearchOptions = options
+	}
+	if len(request.Tools) == 0 {
+		return
+	}
+	request.Tools = lo.Reject(request.Tools, func(tool model.Tool, _ int) bool {
+		return tool.IsBuiltin()
+	})
+	if len(request.Tools) == 0 {
+		request.Tools = nil
+		request.ToolChoice = nil
+	}
+}
+
+// applyReasoningMapping 按映射规则处理推理参数：附加 enable_thinking 开关，丢弃上游不支持的 reasoning_effort
+func applyReasoningMapping(request *model.InternalLLMRequest) {
+	if request.ReasoningEffort == "" {
+		return
+	}
+	mapping := request.GetReasoningMapping()
+	if mapping.EnableThinking && request.EnableThinking == nil {
+		request.EnableThinking = lo.ToPtr(request.ReasoningBudgetTokens() != 0)
+	}
+	if mapping.Unsupported {
+		request.ReasoningEffort = ""
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/samber/lo"
 
@@ -22,6 +23,9 @@ type ResponseOutbound struct {
 	streamID    string
 	streamModel string
 	initialized bool
+	// streamTextLen 已输出文本的字符数，streamPartStart 当前内容块的起始字符位置，用于换算引用的位置
+	streamTextLen   int
+	streamPartStart int
 }
 
 func (o *ResponseOutbound) TransformRequest(ctx context.Context, request *model.InternalLLMRequest, baseUrl, key string) (*http.Request, error) {
@@ -143,7 +147,27 @@ func (o *ResponseOutbound) TransformStream(ctx context.Context, eventData []byte
 			},
 		}
 
+	case "response.content_part.added":
+		o.streamPartStart = o.streamTextLen
+		return nil, nil
+
+	case "response.output_text.annotation.added":
+		annotation := convertAnnotationFromResponses(streamEvent.Annotation, o.streamPartStart)
+		if annotation == nil {
+			return nil, nil
+		}
+		resp.Choices = []model.Choice{
+			{
+				Index: 0,
+				Delta: &model.Message{
+					Role:        "assistant",
+					Annotations: []model.Annotation{*annotation},
+				},
+			},
+		}
+
 	case "response.output_text.delta":
+		o.streamTextLen += utf8.RuneCountInString(streamEvent.Delta)
 		resp.Choices = []model.Choice{
 			{
 				Index: 0,
@@ -308,6 +332,12 @@ type ResponsesItem struct {
 	ImageURL *string         `json:"image_url,omitempty"`
 	Detail   *string         `json:"detail,omitempty"`
 
+	// Input file fields
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data,omitempty"`
+	FileURL  string `json:"file_url,omitempty"`
+	FileID   string `json:"file_id,omitempty"`
+
 	// Annotations for output_text content
 	Annotations []ResponsesAnnotation `json:"annotations,omitempty"`
 
@@ -356,6 +386,30 @@ type ResponsesTool struct {
 	Quality           string         `json:"quality,omitempty"`
 	Size              string         `json:"size,omitempty"`
 	OutputCompression *int64         `json:"output_compression,omitempty"`
+
+	// Web search fields
+	Filters           *ResponsesWebSearchFilters `json:"filters,omitempty"`
+	SearchContextSize string                     `json:"search_context_size,omitempty"`
+	UserLocation      *ResponsesUserLocation     `json:"user_location,omitempty"`
+
+	// Code interpreter fields
+	Container *ResponsesContainer `json:"container,omitempty"`
+}
+
+type ResponsesWebSearchFilters struct {
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+}
+
+type ResponsesUserLocation struct {
+	Type     string `json:"type"`
+	City     string `json:"city,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Country  string `json:"country,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+type ResponsesContainer struct {
+	Type string `json:"type"`
 }
 
 type ResponsesToolChoice struct {
@@ -380,9 +434,11 @@ type ResponsesTextOptions struct {
 }
 
 type ResponsesTextFormat struct {
-	Type   string          `json:"type,omitempty"`
-	Name   string          `json:"name,omitempty"`
-	Schema json.RawMessage `json:"schema,omitempty"`
+	Type        string          `json:"type,omitempty"`
+	Name        string          `json:"name,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"`
+	Strict      *bool           `json:"strict,omitempty"`
 }
 
 type ResponsesReasoning struct {
@@ -419,21 +475,22 @@ type ResponsesError struct {
 }
 
 type ResponsesStreamEvent struct {
-	Type           string             `json:"type"`
-	SequenceNumber int                `json:"sequence_number"`
-	Response       *ResponsesResponse `json:"response,omitempty"`
-	OutputIndex    int                `json:"output_index"`
-	Item           *ResponsesItem     `json:"item,omitempty"`
-	ItemID         *string            `json:"item_id,omitempty"`
-	ContentIndex   *int               `json:"content_index,omitempty"`
-	Delta          string             `json:"delta,omitempty"`
-	Text           string             `json:"text,omitempty"`
-	Name           string             `json:"name,omitempty"`
-	CallID         string             `json:"call_id,omitempty"`
-	Arguments      string             `json:"arguments,omitempty"`
-	SummaryIndex   *int               `json:"summary_index,omitempty"`
-	Code           string             `json:"code,omitempty"`
-	Message        string             `json:"message,omitempty"`
+	Type           string               `json:"type"`
+	SequenceNumber int                  `json:"sequence_number"`
+	Response       *ResponsesResponse   `json:"response,omitempty"`
+	OutputIndex    int                  `json:"output_index"`
+	Item           *ResponsesItem       `json:"item,omitempty"`
+	ItemID         *string              `json:"item_id,omitempty"`
+	ContentIndex   *int                 `json:"content_index,omitempty"`
+	Delta          string               `json:"delta,omitempty"`
+	Text           string               `json:"text,omitempty"`
+	Name           string               `json:"name,omitempty"`
+	CallID         string               `json:"call_id,omitempty"`
+	Arguments      string               `json:"arguments,omitempty"`
+	SummaryIndex   *int                 `json:"summary_index,omitempty"`
+	Code           string               `json:"code,omitempty"`
+	Message        string               `json:"message,omitempty"`
+	Annotation     *ResponsesAnnotation `json:"annotation,omitempty"`
 }
 
 // Conversion functions
@@ -471,15 +528,20 @@ func ConvertToResponsesRequest(req *model.InternalLLMRequest) *ResponsesRequest
 
 	// Convert text options
 	if req.ResponseFormat != nil {
-		result.Text = &ResponsesTextOptions{
-			Format: &ResponsesTextFormat{
-				Type: req.ResponseFormat.Type,
-			},
+		format := &ResponsesTextFormat{
+			Type: req.ResponseFormat.Type,
+		}
+		if jsonSchema := req.ResponseFormat.GetJSONSchema(); jsonSchema != nil {
+			format.Name = jsonSchema.Name
+			format.Description = jsonSchema.Description
+			format.Schema = jsonSchema.Schema
+			format.Strict = jsonSchema.Strict
 		}
+		result.Text = &ResponsesTextOptions{Format: format}
 	}
 
 	// Convert reasoning
-	if req.ReasoningEffort != "" || req.ReasoningBudget != nil {
+	if (req.ReasoningEffort != "" || req.ReasoningBudget != nil) && !req.GetReasoningMapping().Unsupported {
 		result.Reasoning = &ResponsesReasoning{
 			Effort: req.ReasoningEffort,
 		}
@@ -575,6 +637,16 @@ func convertUserMessageToResponses(msg model.Message) ResponsesItem {
 						Detail:   p.ImageURL.Detail,
 					})
 				}
+			case "file":
+				if p.File != nil {
+					contentItems = append(contentItems, ResponsesItem{
+						Type:     "input_file",
+						Filename: p.File.Filename,
+						FileData: p.File.FileData,
+						FileURL:  p.File.FileURL,
+						FileID:   p.File.FileID,
+					})
+				}
 			}
 		}
 	}
@@ -685,11 +757,52 @@ func convertToolsToResponses(tools []model.Tool) []ResponsesTool {
 				rt.OutputCompression = tool.ImageGeneration.OutputCompression
 			}
 			result = append(result, rt)
+		case "web_search":
+			rt := ResponsesTool{
+				Type: "web_search",
+			}
+			if ws := tool.WebSearch; ws != nil {
+				rt.SearchContextSize = ws.SearchContextSize
+				if len(ws.AllowedDomains) > 0 {
+					rt.Filters = &ResponsesWebSearchFilters{AllowedDomains: ws.AllowedDomains}
+				}
+				if loc := ws.UserLocation; loc != nil {
+					rt.UserLocation = &ResponsesUserLocation{
+						Type:     "approximate",
+						City:     loc.City,
+						Region:   loc.Region,
+						Country:  loc.Country,
+						Timezone: loc.Timezone,
+					}
+				}
+			}
+			result = append(result, rt)
+		case "code_execution":
+			result = append(result, ResponsesTool{
+				Type:      "code_interpreter",
+				Container: &ResponsesContainer{Type: "auto"},
+			})
 		}
 	}
 	return result
 }
 
+// convertAnnotationFromResponses 将 url_citation 注释转换为内部格式，offset 为所在内容块在消息中的起始字符位置
+func convertAnnotationFromResponses(annotation *ResponsesAnnotation, offset int) *model.Annotation {
+	if annotation == nil || annotation.Type != "url_citation" || annotation.URL == nil {
+		return nil
+	}
+	return &model.Annotation{
+		Type: "url_citation",
+		URLCitation: &model.URLCitation{
+			StartIndex: offset + lo.FromPtr(annotation.StartIndex),
+			EndIndex:   offset + lo.FromPtr(annotation.EndIndex),
+			URL:        *annotation.URL,
+			Title:      lo.FromPtr(annotation.Title),
+		},
+	}
+}
+
 func convertToolChoiceToResponses(tc *model.ToolChoice) *ResponsesToolChoice {
 	if tc == nil {
 		return nil
@@ -724,6 +837,7 @@ func convertToLLMResponseFromResponses(resp *ResponsesResponse) *model.InternalL
 		textContent      strings.Builder
 		reasoningContent strings.Builder
 		toolCalls        []model.ToolCall
+		annotations      []model.Annotation
 	)
 
 	for _, outputItem := range resp.Output {
@@ -732,6 +846,12 @@ func convertToLLMResponseFromResponses(resp *ResponsesResponse) *model.InternalL
 			if outputItem.Content != nil {
 				for _, item := range outputItem.Content.Items {
 					if item.Type == "output_text" && item.Text != nil {
+						offset := utf8.RuneCountInString(textContent.String())
+						for _, annotation := range item.Annotations {
+							if converted := convertAnnotationFromResponses(&annotation, offset); converted != nil {
+								annotations = append(annotations, *converted)
+							}
+						}
 						textContent.WriteString(*item.Text)
 					}
 				}
@@ -772,8 +892,9 @@ func convertToLLMResponseFromResponses(resp *ResponsesResponse) *model.InternalL
 	choice := model.Choice{
 		Index: 0,
 		Message: &model.Message{
-			Role:      "assistant",
-			ToolCalls: toolCalls,
+			Role:        "assistant",
+			ToolCalls:   toolCalls,
+			Annotations: annotations,
 		},
 	}
 
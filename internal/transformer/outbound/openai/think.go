@@ -0,0 +1,160 @@
+package openai
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/bestruirui/octopus/internal/transformer/model"
+)
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// extractThinkTag 将以 <think> 开头的内容拆分为推理内容和正文
+// 部分开源模型（如 DeepSeek-R1、QwQ）通过兼容接口部署时，推理内容以 <think>...</think> 的形式内联在正文中
+func extractThinkTag(message *model.Message) {
+	if message == nil || message.Content.Content == nil || message.GetReasoningContent() != "" {
+		return
+	}
+	content := strings.TrimLeftFunc(*message.Content.Content, unicode.IsSpace)
+	if !strings.HasPrefix(content, thinkOpenTag) {
+		return
+	}
+	content = content[len(thinkOpenTag):]
+	reasoning, text, closed := strings.Cut(content, thinkCloseTag)
+	if !closed {
+		// 输出被截断时全部作为推理内容
+		text = ""
+	}
+	text = strings.TrimLeftFunc(text, unicode.IsSpace)
+	message.SetReasoningContent(strings.TrimSpace(reasoning))
+	message.Content.Content = &text
+}
+
+type thinkState int
+
+const (
+	// thinkStateDetect 尚未确定正文是否以 <think> 开头，暂存收到的内容
+	thinkStateDetect thinkState = iota
+	// thinkStateReasoning 位于 <think> 与 </think> 之间
+	thinkStateReasoning
+	// thinkStateContent 推理已结束或正文不含 <think>，内容原样输出
+	thinkStateContent
+)
+
+// thinkTagParser 流式解析单个 choice 中的 <think> 标签
+// 标签可能被拆分到多个数据块中，无法确定的部分暂存到下一个数据块
+type thinkTagParser struct {
+	state   thinkState
+	pending string
+	// trimLeading 推理结束后去除正文开头的空白
+	trimLeading bool
+}
+
+// process 处理一段正文，返回应输出的推理内容和正文
+func (p *thinkTagParser) process(delta string) (reasoning, content string) {
+	p.pending += delta
+	for {
+		switch p.state {
+		case thinkStateDetect:
+			trimmed := strings.TrimLeftFunc(p.pending, unicode.IsSpace)
+			if trimmed == "" || (len(trimmed) < len(thinkOpenTag) && strings.HasPrefix(thinkOpenTag, trimmed)) {
+				return reasoning, content
+			}
+			if !strings.HasPrefix(trimmed, thinkOpenTag) {
+				p.state = thinkStateContent
+				continue
+			}
+			p.pending = trimmed[len(thinkOpenTag):]
+			p.state = thinkStateReasoning
+		case thinkStateReasoning:
+			if index := strings.Index(p.pending, thinkCloseTag); index >= 0 {
+				reasoning += p.pending[:index]
+				p.pending = p.pending[index+len(thinkCloseTag):]
+				p.state = thinkStateContent
+				p.trimLeading = true
+				continue
+			}
+			// 保留可能是 </think> 开头部分的结尾
+			keep := partialSuffix(p.pending, thinkCloseTag)
+			reasoning += p.pending[:len(p.pending)-keep]
+			p.pending = p.pending[len(p.pending)-keep:]
+			return reasoning, content
+		case thinkStateContent:
+			text := p.pending
+			p.pending = ""
+			if p.trimLeading {
+				text = strings.TrimLeftFunc(text, unicode.IsSpace)
+				p.trimLeading = text == ""
+			}
+			return reasoning, content + text
+		}
+	}
+}
+
+// flush 在输出结束时返回暂存的内容
+func (p *thinkTagParser) flush() (reasoning, content string) {
+	text := p.pending
+	p.pending = ""
+	if p.state == thinkStateReasoning {
+		return text, ""
+	}
+	if p.trimLeading {
+		return "", ""
+	}
+	return "", text
+}
+
+// partialSuffix 返回 s 结尾与 tag 开头相同的最大长度
+func partialSuffix(s, tag string) int {
+	for n := min(len(s), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// extractThinkTagStream 解析流式响应中的 <think> 标签，已由上游分离推理内容的 choice 不做处理
+func (o *ChatOutbound) extractThinkTagStream(resp *model.InternalLLMResponse) {
+	for i := range resp.Choices {
+		choice := &resp.Choices[i]
+		if choice.Delta == nil {
+			continue
+		}
+		if o.thinkParsers == nil {
+			o.thinkParsers = make(map[int]*thinkTagParser)
+		}
+		parser, ok := o.thinkParsers[choice.Index]
+		if !ok {
+			parser = &thinkTagParser{}
+			o.thinkParsers[choice.Index] = parser
+		}
+		if parser.state == thinkStateDetect && choice.Delta.GetReasoningContent() != "" {
+			parser.state = thinkStateContent
+		}
+		if parser.state == thinkStateContent && parser.pending == "" && !parser.trimLeading {
+			continue
+		}
+
+		var reasoning, content string
+		if choice.Delta.Content.Content != nil {
+			reasoning, content = parser.process(*choice.Delta.Content.Content)
+		}
+		if choice.FinishReason != nil {
+			flushReasoning, flushContent := parser.flush()
+			reasoning += flushReasoning
+			content += flushContent
+		}
+		if reasoning != "" {
+			choice.Delta.SetReasoningContent(choice.Delta.GetReasoningContent() + reasoning)
+		}
+		if content != "" {
+			choice.Delta.Content.Content = &content
+		} else {
+			choice.Delta.Content.Content = nil
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package openai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/samber/lo"
+)
+
+func TestChatOutboundThinkTags(t *testing.T) {
+	const body = `{"choices":[{"index":0,"message":{"role":"assistant","content":"<think>plan</think>\n\nanswer"}}]}`
+	cases := []struct {
+		model     string
+		options   *model.ChannelOptions
+		reasoning string
+		content   string
+	}{
+		{"gpt-4o", nil, "", "<think>plan</think>\n\nanswer"},
+		{"DeepSeek-R1-Distill-Qwen-32B", nil, "plan", "answer"},
+		{"my-reasoner-1", &model.ChannelOptions{ThinkTags: []string{"my-reasoner-*"}}, "plan", "answer"},
+	}
+	for _, tc := range cases {
+		o := &ChatOutbound{}
+		o.SetChannelOptions(tc.options)
+		request := &model.InternalLLMRequest{
+			Model:    tc.model,
+			Messages: []model.Message{{Role: "user", Content: model.MessageContent{Content: lo.ToPtr("hi")}}},
+		}
+		if _, err := o.TransformRequest(context.Background(), request, "https://example.com/v1", "key"); err != nil {
+			t.Fatalf("TransformRequest: %v", err)
+		}
+		resp, err := o.TransformResponse(context.Background(), &http.Response{Body: io.NopCloser(strings.NewReader(body))})
+		if err != nil {
+			t.Fatalf("TransformResponse: %v", err)
+		}
+		message := resp.Choices[0].Message
+		if message.GetReasoningContent() != tc.reasoning || lo.FromPtr(message.Content.Content) != tc.content {
+			t.Errorf("%s: got reasoning %q content %q", tc.model, message.GetReasoningContent(), lo.FromPtr(message.Content.Content))
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package outbound
+
+import (
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/samber/lo"
+)
+
+// builtinReasoningMappings 各渠道类型内置的推理强度映射，管理员配置的规则优先
+var builtinReasoningMappings = []model.ReasoningMapping{
+	// Vertex AI 上的 Claude 与 Anthropic 一致
+	{
+		ModelPattern:  "claude*",
+		ChannelType:   lo.ToPtr(int(OutboundTypeVertex)),
+		Budgets:       map[string]int64{"none": 0, "minimal": 1024, "low": 1024, "medium": 8192, "high": 32768},
+		DefaultBudget: 8192,
+	},
+	{
+		ChannelType:   lo.ToPtr(int(OutboundTypeAnthropic)),
+		Budgets:       map[string]int64{"none": 0, "minimal": 1024, "low": 1024, "medium": 8192, "high": 32768},
+		DefaultBudget: 8192,
+	},
+	{
+		ChannelType:   lo.ToPtr(int(OutboundTypeBedrock)),
+		Budgets:       map[string]int64{"none": 0, "minimal": 1024, "low": 1024, "medium": 8192, "high": 32768},
+		DefaultBudget: 8192,
+	},
+	// Gemini 2.5 Pro 不能关闭思考，使用最小预算
+	// https://ai.google.dev/gemini-api/docs/thinking
+	{
+		ModelPattern:  "gemini-2.5-pro*",
+		ChannelType:   lo.ToPtr(int(OutboundTypeGemini)),
+		Budgets:       map[string]int64{"none": 128, "low": 1024, "medium": 4096, "high": 24576},
+		DefaultBudget: -1,
+	},
+	{
+		ChannelType:   lo.ToPtr(int(OutboundTypeGemini)),
+		Budgets:       map[string]int64{"none": 0, "low": 1024, "medium": 4096, "high": 24576},
+		DefaultBudget: -1,
+	},
+	{
+		ModelPattern:  "gemini-2.5-pro*",
+		ChannelType:   lo.ToPtr(int(OutboundTypeVertex)),
+		Budgets:       map[string]int64{"none": 128, "low": 1024, "medium": 4096, "high": 24576},
+		DefaultBudget: -1,
+	},
+	{
+		ChannelType:   lo.ToPtr(int(OutboundTypeVertex)),
+		Budgets:       map[string]int64{"none": 0, "low": 1024, "medium": 4096, "high": 24576},
+		DefaultBudget: -1,
+	},
+	// 火山引擎仅部分模型支持推理强度参数，其余模型只能开关思考
+	{
+		ModelPattern:  "doubao-seed-1-8-251228",
+		ChannelType:   lo.ToPtr(int(OutboundTypeVolcengine)),
+		Budgets:       map[string]int64{"none": 0, "minimal": 0},
+		DefaultBudget: -1,
+	},
+	{
+		ModelPattern:  "doubao-seed-1-6-lite-251015",
+		ChannelType:   lo.ToPtr(int(OutboundTypeVolcengine)),
+		Budgets:       map[string]int64{"none": 0, "minimal": 0},
+		DefaultBudget: -1,
+	},
+	{
+		ModelPattern:  "doubao-seed-1-6-251015",
+		ChannelType:   lo.ToPtr(int(OutboundTypeVolcengine)),
+		Budgets:       map[string]int64{"none": 0, "minimal": 0},
+		DefaultBudget: -1,
+	},
+	{
+		ChannelType:   lo.ToPtr(int(OutboundTypeVolcengine)),
+		Budgets:       map[string]int64{"none": 0, "minimal": 0},
+		DefaultBudget: -1,
+		Unsupported:   true,
+	},
+}
+
+func init() {
+	model.SetBuiltinReasoningMappings(builtinReasoningMappings)
+}
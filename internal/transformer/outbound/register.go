@@ -3,8 +3,14 @@ package outbound
 import (
 	"github.com/bestruirui/octopus/internal/transformer/model"
 	"github.com/bestruirui/octopus/internal/transformer/outbound/authropic"
+	"github.com/bestruirui/octopus/internal/transformer/outbound/azure"
+	"github.com/bestruirui/octopus/internal/transformer/outbound/bedrock"
+	"github.com/bestruirui/octopus/internal/transformer/outbound/custom"
 	"github.com/bestruirui/octopus/internal/transformer/outbound/gemini"
+	"github.com/bestruirui/octopus/internal/transformer/outbound/mock"
+	"github.com/bestruirui/octopus/internal/transformer/outbound/ollama"
 	"github.com/bestruirui/octopus/internal/transformer/outbound/openai"
+	"github.com/bestruirui/octopus/internal/transformer/outbound/vertex"
 	"github.com/bestruirui/octopus/internal/transformer/outbound/volcengine"
 )
 
@@ -17,11 +23,22 @@ const (
 	OutboundTypeGemini
 	OutboundTypeVolcengine
 	OutboundTypeOpenAIEmbedding
+	OutboundTypeAzureOpenAI
+	OutboundTypeBedrock
+	OutboundTypeVertex
+	OutboundTypeOllama
+	OutboundTypeGeminiEmbedding
+	OutboundTypeCustom
+	OutboundTypeMock
 )
 
 // EmbeddingChannelTypes 定义支持 embedding 请求的 channel 类型集合
+// 接入其他厂商的原生 embedding 接口时，新增 channel 类型并加入此集合即可
 var EmbeddingChannelTypes = map[OutboundType]bool{
 	OutboundTypeOpenAIEmbedding: true,
+	OutboundTypeAzureOpenAI:     true,
+	OutboundTypeOllama:          true,
+	OutboundTypeGeminiEmbedding: true,
 }
 
 // ChatChannelTypes 定义支持 chat 请求的 channel 类型集合
@@ -31,6 +48,24 @@ var ChatChannelTypes = map[OutboundType]bool{
 	OutboundTypeAnthropic:      true,
 	OutboundTypeGemini:         true,
 	OutboundTypeVolcengine:     true,
+	OutboundTypeAzureOpenAI:    true,
+	OutboundTypeBedrock:        true,
+	OutboundTypeVertex:         true,
+	OutboundTypeOllama:         true,
+	OutboundTypeCustom:         true,
+	OutboundTypeMock:           true,
+}
+
+// MultipleChoicesChannelTypes 定义上游原生支持 n 的 channel 类型集合，其他类型由中转拆分为多个请求
+var MultipleChoicesChannelTypes = map[OutboundType]bool{
+	OutboundTypeOpenAIChat:  true,
+	OutboundTypeGemini:      true,
+	OutboundTypeAzureOpenAI: true,
+}
+
+// EmbeddingBatchSizes 定义上游单次 embedding 请求的最大输入条数，超出时由中转拆分为多个请求
+var EmbeddingBatchSizes = map[OutboundType]int{
+	OutboundTypeGeminiEmbedding: gemini.MaxEmbeddingBatchSize,
 }
 
 // IsEmbeddingChannelType 判断 channel 类型是否支持 embedding 请求
@@ -43,6 +78,19 @@ func IsChatChannelType(channelType OutboundType) bool {
 	return ChatChannelTypes[channelType]
 }
 
+// IsMultipleChoicesChannelType 判断 channel 类型是否原生支持 n，Azure 使用 Responses 接口时不支持
+func IsMultipleChoicesChannelType(channelType OutboundType, options *model.ChannelOptions) bool {
+	if channelType == OutboundTypeAzureOpenAI && options != nil && options.API == azure.APIResponses {
+		return false
+	}
+	return MultipleChoicesChannelTypes[channelType]
+}
+
+// EmbeddingBatchSize 返回 channel 类型单次 embedding 请求的最大输入条数，0 为不限制
+func EmbeddingBatchSize(channelType OutboundType) int {
+	return EmbeddingBatchSizes[channelType]
+}
+
 var outboundFactories = map[OutboundType]func() model.Outbound{
 	OutboundTypeOpenAIChat:      func() model.Outbound { return &openai.ChatOutbound{} },
 	OutboundTypeOpenAIResponse:  func() model.Outbound { return &openai.ResponseOutbound{} },
@@ -50,6 +98,13 @@ var outboundFactories = map[OutboundType]func() model.Outbound{
 	OutboundTypeAnthropic:       func() model.Outbound { return &authropic.MessageOutbound{} },
 	OutboundTypeGemini:          func() model.Outbound { return &gemini.MessagesOutbound{} },
 	OutboundTypeVolcengine:      func() model.Outbound { return &volcengine.ResponseOutbound{} },
+	OutboundTypeAzureOpenAI:     func() model.Outbound { return &azure.OpenAIOutbound{} },
+	OutboundTypeBedrock:         func() model.Outbound { return &bedrock.MessagesOutbound{} },
+	OutboundTypeVertex:          func() model.Outbound { return &vertex.MessagesOutbound{} },
+	OutboundTypeOllama:          func() model.Outbound { return &ollama.Outbound{} },
+	OutboundTypeGeminiEmbedding: func() model.Outbound { return &gemini.EmbeddingOutbound{} },
+	OutboundTypeCustom:          func() model.Outbound { return &custom.Outbound{} },
+	OutboundTypeMock:            func() model.Outbound { return &mock.Outbound{} },
 }
 
 func Get(outboundType OutboundType) model.Outbound {
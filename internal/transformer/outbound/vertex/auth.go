@@ -0,0 +1,153 @@
+package vertex
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bestruirui/octopus/internal/utils/cache"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	defaultTokenURL = "https://oauth2.googleapis.com/token"
+	tokenScope      = "https://www.googleapis.com/auth/cloud-platform"
+	// tokenRefreshBefore 令牌过期前提前刷新的时间
+	tokenRefreshBefore = 5 * time.Minute
+)
+
+// serviceAccount 服务账号 JSON 中用到的字段
+type serviceAccount struct {
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+}
+
+type accessToken struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+var (
+	// tokenCache 按服务账号和令牌地址缓存访问令牌
+	tokenCache = cache.New[string, accessToken](16)
+	// tokenLocks 按缓存键加锁，同一服务账号只换取一次令牌，不同服务账号互不阻塞
+	tokenLocks sync.Map
+	// tokenClient 未设置渠道 HTTP 客户端时换取令牌使用的默认客户端
+	tokenClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+func parseServiceAccount(key string) (*serviceAccount, error) {
+	var sa serviceAccount
+	if err := json.Unmarshal([]byte(key), &sa); err != nil {
+		return nil, fmt.Errorf("vertex key must be a service account JSON: %w", err)
+	}
+	if sa.ClientEmail == "" || sa.PrivateKey == "" {
+		return nil, fmt.Errorf("service account JSON is missing client_email or private_key")
+	}
+	return &sa, nil
+}
+
+// getAccessToken 返回缓存的访问令牌，即将过期时使用服务账号签名的 JWT 通过 httpClient 重新换取
+func getAccessToken(ctx context.Context, httpClient *http.Client, sa *serviceAccount, tokenURL string) (string, error) {
+	keyHash := sha256.Sum256([]byte(sa.PrivateKey))
+	cacheKey := sa.ClientEmail + "|" + tokenURL + "|" + hex.EncodeToString(keyHash[:8])
+	if token, ok := tokenCache.Get(cacheKey); ok && time.Until(token.ExpiresAt) > tokenRefreshBefore {
+		return token.Token, nil
+	}
+
+	lock, _ := tokenLocks.LoadOrStore(cacheKey, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+	// Re-check after acquiring lock.
+	if token, ok := tokenCache.Get(cacheKey); ok && time.Until(token.ExpiresAt) > tokenRefreshBefore {
+		return token.Token, nil
+	}
+
+	token, err := exchangeToken(ctx, httpClient, sa, tokenURL)
+	if err != nil {
+		return "", err
+	}
+	tokenCache.Set(cacheKey, *token)
+	return token.Token, nil
+}
+
+// exchangeToken 使用 JWT Bearer 授权换取访问令牌
+// refer: https://developers.google.com/identity/protocols/oauth2/service-account#httprest
+func exchangeToken(ctx context.Context, httpClient *http.Client, sa *serviceAccount, tokenURL string) (*accessToken, error) {
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(sa.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse service account private key: %w", err)
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   sa.ClientEmail,
+		"scope": tokenScope,
+		"aud":   tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	assertion := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	if sa.PrivateKeyID != "" {
+		assertion.Header["kid"] = sa.PrivateKeyID
+	}
+	signed, err := assertion.SignedString(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign jwt: %w", err)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", signed)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if httpClient == nil {
+		httpClient = tokenClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint error %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal token response: %w", err)
+	}
+	if result.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint returned no access token")
+	}
+	if result.ExpiresIn <= 0 {
+		result.ExpiresIn = 3600
+	}
+	return &accessToken{
+		Token:     result.AccessToken,
+		ExpiresAt: now.Add(time.Duration(result.ExpiresIn) * time.Second),
+	}, nil
+}
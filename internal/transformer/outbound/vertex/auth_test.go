@@ -0,0 +1,157 @@
+package vertex
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestServiceAccount(t *testing.T, email string) (*serviceAccount, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return &serviceAccount{ClientEmail: email, PrivateKey: string(privateKey), PrivateKeyID: "kid-1"}, key
+}
+
+// newTestTokenServer 校验 JWT 断言并签发令牌，expiresIn 为签发令牌的有效期（秒）
+func newTestTokenServer(t *testing.T, key *rsa.PrivateKey, expiresIn *atomic.Int64, block <-chan struct{}) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var issued atomic.Int64
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			http.Error(w, "invalid grant_type", http.StatusBadRequest)
+			return
+		}
+		claims := jwt.MapClaims{}
+		_, err := jwt.ParseWithClaims(r.FormValue("assertion"), claims, func(token *jwt.Token) (any, error) {
+			if token.Header["kid"] != "kid-1" {
+				return nil, fmt.Errorf("unexpected kid %v", token.Header["kid"])
+			}
+			return &key.PublicKey, nil
+		}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithAudience(server.URL+"/token"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if claims["scope"] != tokenScope {
+			http.Error(w, "invalid scope", http.StatusBadRequest)
+			return
+		}
+		if block != nil {
+			<-block
+		}
+		n := issued.Add(1)
+		fmt.Fprintf(w, `{"access_token":"%s-%d","expires_in":%d,"token_type":"Bearer"}`, claims["iss"], n, expiresIn.Load())
+	}))
+	t.Cleanup(server.Close)
+	return server, &issued
+}
+
+func TestGetAccessToken(t *testing.T) {
+	sa, key := newTestServiceAccount(t, "cache@example.iam.gserviceaccount.com")
+	var expiresIn atomic.Int64
+	expiresIn.Store(3600)
+	server, issued := newTestTokenServer(t, key, &expiresIn, nil)
+	tokenURL := server.URL + "/token"
+
+	for i := 0; i < 2; i++ {
+		token, err := getAccessToken(context.Background(), nil, sa, tokenURL)
+		if err != nil {
+			t.Fatalf("getAccessToken: %v", err)
+		}
+		if token != sa.ClientEmail+"-1" {
+			t.Fatalf("expected cached token, got %s", token)
+		}
+	}
+
+	// 即将过期的令牌每次都重新换取
+	expiresIn.Store(int64(tokenRefreshBefore/time.Second) - 60)
+	for cacheKey := range tokenCache.GetAll() {
+		if strings.HasPrefix(cacheKey, sa.ClientEmail+"|"+tokenURL+"|") {
+			tokenCache.Set(cacheKey, accessToken{Token: "expiring", ExpiresAt: time.Now().Add(time.Minute)})
+		}
+	}
+	for i := 2; i <= 3; i++ {
+		token, err := getAccessToken(context.Background(), nil, sa, tokenURL)
+		if err != nil {
+			t.Fatalf("getAccessToken: %v", err)
+		}
+		if want := fmt.Sprintf("%s-%d", sa.ClientEmail, i); token != want {
+			t.Fatalf("expected refreshed token %s, got %s", want, token)
+		}
+	}
+	if issued.Load() != 3 {
+		t.Fatalf("expected 3 token exchanges, got %d", issued.Load())
+	}
+
+	// 断言的 aud 必须是实际请求的令牌地址
+	if _, err := getAccessToken(context.Background(), nil, sa, server.URL+"/other"); err == nil {
+		t.Fatal("expected error for mismatched audience")
+	}
+}
+
+func TestGetAccessTokenLocksPerKey(t *testing.T) {
+	slow, slowKey := newTestServiceAccount(t, "slow@example.iam.gserviceaccount.com")
+	fast, fastKey := newTestServiceAccount(t, "fast@example.iam.gserviceaccount.com")
+	var expiresIn atomic.Int64
+	expiresIn.Store(3600)
+	block := make(chan struct{})
+	slowServer, _ := newTestTokenServer(t, slowKey, &expiresIn, block)
+	fastServer, _ := newTestTokenServer(t, fastKey, &expiresIn, nil)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := getAccessToken(context.Background(), nil, slow, slowServer.URL+"/token")
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := getAccessToken(ctx, nil, fast, fastServer.URL+"/token"); err != nil {
+		t.Fatalf("token exchange blocked by another service account: %v", err)
+	}
+	close(block)
+	if err := <-done; err != nil {
+		t.Fatalf("slow token exchange failed: %v", err)
+	}
+}
+
+type countingTransport struct {
+	count atomic.Int64
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.count.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestGetAccessTokenUsesChannelClient(t *testing.T) {
+	sa, key := newTestServiceAccount(t, "proxy@example.iam.gserviceaccount.com")
+	var expiresIn atomic.Int64
+	expiresIn.Store(3600)
+	server, _ := newTestTokenServer(t, key, &expiresIn, nil)
+
+	transport := &countingTransport{}
+	if _, err := getAccessToken(context.Background(), &http.Client{Transport: transport}, sa, server.URL+"/token"); err != nil {
+		t.Fatalf("getAccessToken: %v", err)
+	}
+	if transport.count.Load() != 1 {
+		t.Fatalf("expected token exchange through channel client, got %d requests", transport.count.Load())
+	}
+}
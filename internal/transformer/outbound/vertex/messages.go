@@ -0,0 +1,165 @@
+package vertex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	anthropicModel "github.com/bestruirui/octopus/internal/transformer/inbound/anthropic"
+	"github.com/bestruirui/octopus/internal/transformer/model"
+	"github.com/bestruirui/octopus/internal/transformer/outbound/authropic"
+	"github.com/bestruirui/octopus/internal/transformer/outbound/gemini"
+	"github.com/samber/lo"
+)
+
+const (
+	defaultRegion = "us-central1"
+	// anthropicVersion Vertex AI 上 Anthropic 模型要求的 anthropic_version
+	anthropicVersion = "vertex-2023-10-16"
+)
+
+// MessagesOutbound Google Vertex AI
+// claude 开头的模型走 Anthropic 发布方并复用 Anthropic 转换，其余走 Google 发布方并复用 Gemini 转换
+type MessagesOutbound struct {
+	options *model.ChannelOptions
+	client  *http.Client
+	inner   model.Outbound
+}
+
+func (o *MessagesOutbound) SetChannelOptions(options *model.ChannelOptions) {
+	o.options = options
+}
+
+// SetHTTPClient 换取访问令牌时使用渠道的 HTTP 客户端，与转发请求使用相同的代理
+func (o *MessagesOutbound) SetHTTPClient(client *http.Client) {
+	o.client = client
+}
+
+func (o *MessagesOutbound) TransformRequest(ctx context.Context, request *model.InternalLLMRequest, baseUrl, key string) (*http.Request, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request is nil")
+	}
+	sa, err := parseServiceAccount(key)
+	if err != nil {
+		return nil, err
+	}
+
+	region := defaultRegion
+	projectID := sa.ProjectID
+	tokenURL := sa.TokenURI
+	if o.options != nil {
+		if o.options.Region != "" {
+			region = o.options.Region
+		}
+		if o.options.ProjectID != "" {
+			projectID = o.options.ProjectID
+		}
+		if o.options.TokenURL != "" {
+			tokenURL = o.options.TokenURL
+		}
+	}
+	if projectID == "" {
+		return nil, fmt.Errorf("vertex project id is required")
+	}
+	if tokenURL == "" {
+		tokenURL = defaultTokenURL
+	}
+
+	token, err := getAccessToken(ctx, o.client, sa, tokenURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// 未填写 Base URL 时使用区域对应的默认地址
+	if baseUrl == "" {
+		baseUrl = "https://" + region + "-aiplatform.googleapis.com"
+		if region == "global" {
+			baseUrl = "https://aiplatform.googleapis.com"
+		}
+	}
+	modelsUrl := fmt.Sprintf("%s/v1/projects/%s/locations/%s/publishers",
+		strings.TrimSuffix(baseUrl, "/"), url.PathEscape(projectID), url.PathEscape(region))
+
+	var req *http.Request
+	if strings.HasPrefix(strings.ToLower(request.Model), "claude") {
+		req, err = o.anthropicRequest(ctx, request, modelsUrl+"/anthropic/models")
+	} else {
+		req, err = o.geminiRequest(ctx, request, modelsUrl+"/google")
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req, nil
+}
+
+// anthropicRequest 构建 rawPredict / streamRawPredict 请求，模型由路径指定
+func (o *MessagesOutbound) anthropicRequest(ctx context.Context, request *model.InternalLLMRequest, modelsUrl string) (*http.Request, error) {
+	o.inner = &authropic.MessageOutbound{}
+
+	// Vertex AI 上的 Claude 不支持 url 类型的图片和文档，下载后内联
+	copied := *request
+	request = &copied
+	if err := request.InlineRemoteMedia(ctx, model.RemoteMediaImage|model.RemoteMediaFile); err != nil {
+		return nil, err
+	}
+
+	anthropicReq := authropic.ConvertToAnthropicRequest(request)
+	anthropicReq.Model = ""
+	anthropicReq.AnthropicVersion = anthropicVersion
+	// Vertex AI 不支持 Anthropic 的服务端工具（联网搜索、代码执行）
+	anthropicReq.Tools = lo.Reject(anthropicReq.Tools, func(tool anthropicModel.Tool, _ int) bool {
+		return tool.IsServerTool()
+	})
+
+	body, err := json.Marshal(anthropicReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal anthropic request: %w", err)
+	}
+
+	method := "rawPredict"
+	accept := "application/json"
+	if request.Stream != nil && *request.Stream {
+		method = "streamRawPredict"
+		accept = "text/event-stream"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, modelsUrl+"/"+url.PathEscape(request.Model)+":"+method, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+	return req, nil
+}
+
+// geminiRequest 复用 Gemini 出站构建请求，改用 Bearer 令牌认证
+func (o *MessagesOutbound) geminiRequest(ctx context.Context, request *model.InternalLLMRequest, publisherUrl string) (*http.Request, error) {
+	o.inner = &gemini.MessagesOutbound{}
+
+	req, err := o.inner.TransformRequest(ctx, request, publisherUrl, "")
+	if err != nil {
+		return nil, err
+	}
+	query := req.URL.Query()
+	query.Del("key")
+	req.URL.RawQuery = query.Encode()
+	return req, nil
+}
+
+func (o *MessagesOutbound) TransformResponse(ctx context.Context, response *http.Response) (*model.InternalLLMResponse, error) {
+	if o.inner == nil {
+		return nil, fmt.Errorf("request has not been transformed")
+	}
+	return o.inner.TransformResponse(ctx, response)
+}
+
+func (o *MessagesOutbound) TransformStream(ctx context.Context, eventData []byte) (*model.InternalLLMResponse, error) {
+	if o.inner == nil {
+		return nil, fmt.Errorf("request has not been transformed")
+	}
+	return o.inner.TransformStream(ctx, eventData)
+}
@@ -13,12 +13,6 @@ import (
 	"github.com/bestruirui/octopus/internal/transformer/outbound/openai"
 )
 
-var supportedReasoningEffortModel = map[string]bool{
-	"doubao-seed-1-8-251228":      true,
-	"doubao-seed-1-6-lite-251015": true,
-	"doubao-seed-1-6-251015":      true,
-}
-
 type ResponseOutbound struct {
 	inner openai.ResponseOutbound
 }
@@ -29,21 +23,20 @@ func (o *ResponseOutbound) TransformRequest(ctx context.Context, request *model.
 	}
 
 	// Convert to Responses API request format
+	// 不支持推理强度参数的模型由映射规则在转换时丢弃 reasoning
 	openaiReq := openai.ConvertToResponsesRequest(request)
 	openaiReq.Metadata = nil // volcengine not supported
-	if _, ok := supportedReasoningEffortModel[request.Model]; !ok {
-		openaiReq.Reasoning = nil
-	}
 	responsesReq := ResponsesRequest{
 		ResponsesRequest: openaiReq,
 		Input:            convertToResponsesInput(openaiReq.Input),
 	}
-	switch request.ReasoningEffort {
-	case "minimal":
-		responsesReq.Thinking.Type = ThinkingTypeDisabled
-	case "low", "medium", "high":
-		responsesReq.Thinking.Type = ThinkingTypeEnabled
-	default:
+	// 思考开关由映射规则的预算决定，预算为 0 时关闭
+	if request.ReasoningEffort != "" {
+		if request.ReasoningBudgetTokens() == 0 {
+			responsesReq.Thinking.Type = ThinkingTypeDisabled
+		} else {
+			responsesReq.Thinking.Type = ThinkingTypeEnabled
+		}
 	}
 
 	body, err := json.Marshal(responsesReq)
@@ -0,0 +1,527 @@
+// Package jsonschema 实现 JSON Schema 的常用子集，用于校验结构化输出
+// 支持 type、enum、const、properties、required、additionalProperties、patternProperties、items、prefixItems、
+// 长度和数值范围、pattern、allOf、anyOf、oneOf、not 以及文档内的 $ref，不支持的关键字会被忽略
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dlclark/regexp2"
+)
+
+// Schema 编译后的 JSON Schema
+type Schema struct {
+	root    any
+	regexps map[string]*regexp2.Regexp
+}
+
+// ValidationError 校验失败的位置和原因
+type ValidationError struct {
+	// Path 不符合的值的位置，如 $.items[0].name
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Compile 解析 JSON Schema
+func Compile(schema []byte) (*Schema, error) {
+	var root any
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return nil, fmt.Errorf("invalid json schema: %w", err)
+	}
+	switch root.(type) {
+	case map[string]any, bool:
+	default:
+		return nil, fmt.Errorf("invalid json schema: must be an object or a boolean")
+	}
+	return &Schema{root: root, regexps: make(map[string]*regexp2.Regexp)}, nil
+}
+
+// ValidateJSON 解析 JSON 文本并校验
+func (s *Schema) ValidateJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return &ValidationError{Path: "$", Message: "invalid json: " + err.Error()}
+	}
+	if decoder.More() {
+		return &ValidationError{Path: "$", Message: "invalid json: unexpected data after top-level value"}
+	}
+	return s.Validate(value)
+}
+
+// Validate 校验由 json.Decoder 解码的值，数字可以是 float64 或 json.Number
+func (s *Schema) Validate(value any) error {
+	return s.validate(s.root, value, "$", 0)
+}
+
+// maxDepth $ref 的最大展开深度，防止循环引用
+const maxDepth = 64
+
+func (s *Schema) validate(schema any, value any, path string, depth int) error {
+	if depth > maxDepth {
+		return &ValidationError{Path: path, Message: "schema nesting too deep"}
+	}
+	switch schema := schema.(type) {
+	case bool:
+		if !schema {
+			return &ValidationError{Path: path, Message: "no value is allowed"}
+		}
+		return nil
+	case map[string]any:
+		return s.validateObjectSchema(schema, value, path, depth)
+	}
+	return nil
+}
+
+func (s *Schema) validateObjectSchema(schema map[string]any, value any, path string, depth int) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		target, err := s.resolveRef(ref)
+		if err != nil {
+			return &ValidationError{Path: path, Message: err.Error()}
+		}
+		if err := s.validate(target, value, path, depth+1); err != nil {
+			return err
+		}
+	}
+
+	if nullable, _ := schema["nullable"].(bool); nullable && value == nil {
+		return nil
+	}
+	if err := validateType(schema["type"], value, path); err != nil {
+		return err
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		if !containsValue(enum, value) {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("value must be one of %s", compactJSON(enum))}
+		}
+	}
+	if constant, ok := schema["const"]; ok && !equalValues(constant, value) {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("value must be %s", compactJSON(constant))}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if err := s.validateObject(schema, v, path, depth); err != nil {
+			return err
+		}
+	case []any:
+		if err := s.validateArray(schema, v, path, depth); err != nil {
+			return err
+		}
+	case string:
+		if err := s.validateString(schema, v, path); err != nil {
+			return err
+		}
+	case json.Number, float64:
+		if err := validateNumber(schema, toFloat(v), path); err != nil {
+			return err
+		}
+	}
+
+	return s.validateCombinators(schema, value, path, depth)
+}
+
+func (s *Schema) validateCombinators(schema map[string]any, value any, path string, depth int) error {
+	if allOf, ok := schema["allOf"].([]any); ok {
+		for _, sub := range allOf {
+			if err := s.validate(sub, value, path, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		var firstErr error
+		matched := false
+		for _, sub := range anyOf {
+			err := s.validate(sub, value, path, depth+1)
+			if err == nil {
+				matched = true
+				break
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if !matched {
+			return &ValidationError{Path: path, Message: "value does not match any schema in anyOf: " + errorMessage(firstErr)}
+		}
+	}
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		count := 0
+		for _, sub := range oneOf {
+			if s.validate(sub, value, path, depth+1) == nil {
+				count++
+			}
+		}
+		if count != 1 {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("value must match exactly one schema in oneOf, matched %d", count)}
+		}
+	}
+	if not, ok := schema["not"]; ok {
+		if s.validate(not, value, path, depth+1) == nil {
+			return &ValidationError{Path: path, Message: "value must not match the schema in not"}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateObject(schema map[string]any, object map[string]any, path string, depth int) error {
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			key, _ := name.(string)
+			if _, exists := object[key]; !exists {
+				return &ValidationError{Path: path, Message: fmt.Sprintf("missing required property %q", key)}
+			}
+		}
+	}
+	if n, ok := intKeyword(schema, "minProperties"); ok && len(object) < n {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("must have at least %d properties", n)}
+	}
+	if n, ok := intKeyword(schema, "maxProperties"); ok && len(object) > n {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("must have at most %d properties", n)}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	patternProperties, _ := schema["patternProperties"].(map[string]any)
+	additional, hasAdditional := schema["additionalProperties"]
+
+	// 按键名排序，保证返回的错误稳定
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		childPath := path + "." + key
+		matched := false
+		if sub, ok := properties[key]; ok {
+			matched = true
+			if err := s.validate(sub, object[key], childPath, depth+1); err != nil {
+				return err
+			}
+		}
+		for pattern, sub := range patternProperties {
+			re, err := s.regexp(pattern)
+			if err != nil {
+				return &ValidationError{Path: path, Message: err.Error()}
+			}
+			if ok, _ := re.MatchString(key); ok {
+				matched = true
+				if err := s.validate(sub, object[key], childPath, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		if matched || !hasAdditional {
+			continue
+		}
+		if allowed, ok := additional.(bool); ok && !allowed {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("additional property %q is not allowed", key)}
+		}
+		if err := s.validate(additional, object[key], childPath, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateArray(schema map[string]any, array []any, path string, depth int) error {
+	if n, ok := intKeyword(schema, "minItems"); ok && len(array) < n {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("must have at least %d items", n)}
+	}
+	if n, ok := intKeyword(schema, "maxItems"); ok && len(array) > n {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("must have at most %d items", n)}
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range array {
+			for j := i + 1; j < len(array); j++ {
+				if equalValues(array[i], array[j]) {
+					return &ValidationError{Path: path, Message: fmt.Sprintf("items %d and %d are not unique", i, j)}
+				}
+			}
+		}
+	}
+
+	start := 0
+	if prefixItems, ok := schema["prefixItems"].([]any); ok {
+		for i := 0; i < len(prefixItems) && i < len(array); i++ {
+			if err := s.validate(prefixItems[i], array[i], path+"["+strconv.Itoa(i)+"]", depth+1); err != nil {
+				return err
+			}
+		}
+		start = len(prefixItems)
+	}
+	items, ok := schema["items"]
+	if !ok {
+		return nil
+	}
+	// 旧版本的元组写法
+	if tuple, ok := items.([]any); ok {
+		for i := 0; i < len(tuple) && i < len(array); i++ {
+			if err := s.validate(tuple[i], array[i], path+"["+strconv.Itoa(i)+"]", depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i := start; i < len(array); i++ {
+		if err := s.validate(items, array[i], path+"["+strconv.Itoa(i)+"]", depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateString(schema map[string]any, value string, path string) error {
+	length := len([]rune(value))
+	if n, ok := intKeyword(schema, "minLength"); ok && length < n {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("length must be at least %d", n)}
+	}
+	if n, ok := intKeyword(schema, "maxLength"); ok && length > n {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("length must be at most %d", n)}
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := s.regexp(pattern)
+		if err != nil {
+			return &ValidationError{Path: path, Message: err.Error()}
+		}
+		if matched, _ := re.MatchString(value); !matched {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("value does not match pattern %q", pattern)}
+		}
+	}
+	return nil
+}
+
+func validateNumber(schema map[string]any, value float64, path string) error {
+	if limit, ok := numberKeyword(schema, "minimum"); ok && value < limit {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("value must be >= %v", limit)}
+	}
+	if limit, ok := numberKeyword(schema, "maximum"); ok && value > limit {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("value must be <= %v", limit)}
+	}
+	if limit, ok := numberKeyword(schema, "exclusiveMinimum"); ok && value <= limit {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("value must be > %v", limit)}
+	}
+	if limit, ok := numberKeyword(schema, "exclusiveMaximum"); ok && value >= limit {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("value must be < %v", limit)}
+	}
+	if divisor, ok := numberKeyword(schema, "multipleOf"); ok && divisor > 0 {
+		if quotient := value / divisor; math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("value must be a multiple of %v", divisor)}
+		}
+	}
+	return nil
+}
+
+func validateType(typeKeyword any, value any, path string) error {
+	var types []string
+	switch t := typeKeyword.(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, item := range t {
+			if name, ok := item.(string); ok {
+				types = append(types, name)
+			}
+		}
+	default:
+		return nil
+	}
+	for _, name := range types {
+		if matchesType(name, value) {
+			return nil
+		}
+	}
+	return &ValidationError{Path: path, Message: fmt.Sprintf("expected %s, got %s", strings.Join(types, " or "), typeName(value))}
+}
+
+func matchesType(name string, value any) bool {
+	switch name {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		switch value.(type) {
+		case json.Number, float64:
+			return true
+		}
+	case "integer":
+		switch value.(type) {
+		case json.Number, float64:
+			f := toFloat(value)
+			return f == math.Trunc(f) && !math.IsInf(f, 0)
+		}
+	}
+	return false
+}
+
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case json.Number, float64:
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// resolveRef 解析文档内的引用，如 #/$defs/item 或 #/definitions/item
+func (s *Schema) resolveRef(ref string) (any, error) {
+	if ref == "#" {
+		return s.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	var node any = s.root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch current := node.(type) {
+		case map[string]any:
+			next, ok := current[token]
+			if !ok {
+				return nil, fmt.Errorf("unresolved $ref %q", ref)
+			}
+			node = next
+		case []any:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(current) {
+				return nil, fmt.Errorf("unresolved $ref %q", ref)
+			}
+			node = current[index]
+		default:
+			return nil, fmt.Errorf("unresolved $ref %q", ref)
+		}
+	}
+	return node, nil
+}
+
+// regexp 编译并缓存 pattern，使用 ECMAScript 语法与 JSON Schema 保持一致
+func (s *Schema) regexp(pattern string) (*regexp2.Regexp, error) {
+	if re, ok := s.regexps[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp2.Compile(pattern, regexp2.ECMAScript)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+	s.regexps[pattern] = re
+	return re, nil
+}
+
+func intKeyword(schema map[string]any, key string) (int, bool) {
+	f, ok := numberKeyword(schema, key)
+	return int(f), ok
+}
+
+func numberKeyword(schema map[string]any, key string) (float64, bool) {
+	switch v := schema[key].(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		return toFloat(v), true
+	}
+	return 0, false
+}
+
+func toFloat(value any) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	}
+	return 0
+}
+
+func containsValue(values []any, value any) bool {
+	for _, candidate := range values {
+		if equalValues(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// equalValues 按 JSON 语义比较，数字按数值比较
+func equalValues(a, b any) bool {
+	switch av := a.(type) {
+	case json.Number, float64:
+		switch b.(type) {
+		case json.Number, float64:
+			return toFloat(av) == toFloat(b)
+		}
+		return false
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for key, value := range av {
+			other, exists := bv[key]
+			if !exists || !equalValues(value, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !equalValues(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func compactJSON(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
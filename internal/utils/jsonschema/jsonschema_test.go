@@ -0,0 +1,77 @@
+package jsonschema
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"age": {"type": "integer", "minimum": 0},
+		"email": {"type": ["string", "null"], "pattern": "^[^@]+@[^@]+$"},
+		"tags": {"type": "array", "items": {"$ref": "#/$defs/tag"}, "uniqueItems": true},
+		"role": {"enum": ["admin", "user"]}
+	},
+	"required": ["name", "age"],
+	"additionalProperties": false,
+	"$defs": {
+		"tag": {"type": "string", "maxLength": 5}
+	}
+}`
+
+func TestValidateJSON(t *testing.T) {
+	schema, err := Compile([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"valid", `{"name":"a","age":3,"email":null,"tags":["x","y"],"role":"user"}`, ""},
+		{"missing required", `{"name":"a"}`, `$: missing required property "age"`},
+		{"wrong type", `{"name":"a","age":1.5}`, "$.age: expected integer, got number"},
+		{"additional property", `{"name":"a","age":1,"extra":true}`, `additional property "extra" is not allowed`},
+		{"ref", `{"name":"a","age":1,"tags":["toolong"]}`, "$.tags[0]: length must be at most 5"},
+		{"unique", `{"name":"a","age":1,"tags":["x","x"]}`, "are not unique"},
+		{"enum", `{"name":"a","age":1,"role":"root"}`, `$.role: value must be one of ["admin","user"]`},
+		{"pattern", `{"name":"a","age":1,"email":"nope"}`, "$.email: value does not match pattern"},
+		{"invalid json", `{"name":`, "$: invalid json"},
+		{"trailing data", `{"name":"a","age":1} {}`, "unexpected data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.ValidateJSON([]byte(tt.input))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateCombinators(t *testing.T) {
+	schema, err := Compile([]byte(`{"anyOf":[{"type":"string"},{"type":"number","exclusiveMinimum":0}],"not":{"const":"forbidden"}}`))
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	for _, input := range []string{`"ok"`, `1`} {
+		if err := schema.ValidateJSON([]byte(input)); err != nil {
+			t.Errorf("%s: unexpected error: %v", input, err)
+		}
+	}
+	for _, input := range []string{`0`, `true`, `"forbidden"`} {
+		if err := schema.ValidateJSON([]byte(input)); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+}
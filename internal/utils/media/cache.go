@@ -0,0 +1,79 @@
+package media
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	key       string
+	media     *Media
+	expiresAt time.Time
+}
+
+// lruCache 按 URL 的哈希缓存下载的文件，总大小超过上限时淘汰最久未使用的文件
+type lruCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	ttl      time.Duration
+	size     int64
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+func newLRUCache(maxBytes int64, ttl time.Duration) *lruCache {
+	return &lruCache{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func cacheKey(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *lruCache) get(key string) (*Media, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.media, true
+}
+
+func (c *lruCache) set(key string, media *Media) {
+	size := int64(len(media.Data))
+	if size > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, media: media, expiresAt: time.Now().Add(c.ttl)})
+	c.size += size
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *lruCache) remove(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.order.Remove(elem)
+	delete(c.items, entry.key)
+	c.size -= int64(len(entry.media.Data))
+}
@@ -0,0 +1,170 @@
+// Package media 下载远程图片和文档，供需要内联数据的上游使用
+package media
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bestruirui/octopus/internal/conf"
+)
+
+const (
+	dialTimeout  = 10 * time.Second
+	fetchTimeout = 30 * time.Second
+	maxRedirects = 5
+	cacheTTL     = 10 * time.Minute
+)
+
+// maxSize 单个文件的大小上限，默认 20MB，可通过环境变量 OCTOPUS_RELAY_MEDIA_MAX_SIZE 覆盖
+var maxSize int64 = 20 * 1024 * 1024
+
+// cacheSize 缓存的总大小上限，默认 128MB，可通过环境变量 OCTOPUS_RELAY_MEDIA_CACHE_SIZE 覆盖，0 表示不缓存
+var cacheSize int64 = 128 * 1024 * 1024
+
+// allowedMimeTypes 允许下载的文件类型
+var allowedMimeTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"image/heic":      true,
+	"image/heif":      true,
+	"application/pdf": true,
+}
+
+var (
+	// ErrTooLarge 文件超过大小上限
+	ErrTooLarge = errors.New("media exceeds size limit")
+	// ErrUnsupportedType 文件类型不在允许的范围内
+	ErrUnsupportedType = errors.New("unsupported media type")
+)
+
+var (
+	httpClient *http.Client
+	mediaCache *lruCache
+)
+
+func init() {
+	prefix := strings.ToUpper(conf.APP_NAME) + "_RELAY_MEDIA_"
+	if raw := strings.TrimSpace(os.Getenv(prefix + "MAX_SIZE")); raw != "" {
+		if v, err := strconv.ParseInt(raw, 10, 64); err == nil && v > 0 {
+			maxSize = v
+		}
+	}
+	if raw := strings.TrimSpace(os.Getenv(prefix + "CACHE_SIZE")); raw != "" {
+		if v, err := strconv.ParseInt(raw, 10, 64); err == nil && v >= 0 {
+			cacheSize = v
+		}
+	}
+
+	// 不使用代理，否则无法校验实际访问的地址
+	transport := &http.Transport{
+		DialContext:           newSafeDialer().DialContext,
+		TLSHandshakeTimeout:   dialTimeout,
+		ResponseHeaderTimeout: fetchTimeout,
+		MaxIdleConns:          16,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	httpClient = &http.Client{
+		Transport: transport,
+		Timeout:   fetchTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("unsupported redirect scheme: %s", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	mediaCache = newLRUCache(cacheSize, cacheTTL)
+}
+
+// Media 下载的文件
+type Media struct {
+	MimeType string
+	Data     []byte
+}
+
+// Base64 返回 base64 编码的文件内容
+func (m *Media) Base64() string {
+	return base64.StdEncoding.EncodeToString(m.Data)
+}
+
+// DataURL 返回文件的 data URL
+func (m *Media) DataURL() string {
+	return "data:" + m.MimeType + ";base64," + m.Base64()
+}
+
+// IsRemoteURL 判断是否为可下载的 http(s) 地址
+func IsRemoteURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "http://") || strings.HasPrefix(rawURL, "https://")
+}
+
+// Fetch 下载远程文件，仅允许访问公网地址，限制文件大小和类型，结果按 URL 的哈希缓存
+func Fetch(ctx context.Context, rawURL string) (*Media, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid media url: %s", rawURL)
+	}
+
+	key := cacheKey(rawURL)
+	if media, ok := mediaCache.get(key); ok {
+		return media, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create media request: %w", err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch media: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch media: status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooLarge, resp.ContentLength)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, maxSize)
+	}
+
+	mimeType, err := detectMimeType(resp.Header.Get("Content-Type"), data)
+	if err != nil {
+		return nil, err
+	}
+	media := &Media{MimeType: mimeType, Data: data}
+	mediaCache.set(key, media)
+	return media, nil
+}
+
+// detectMimeType 优先使用响应头的类型，不在允许范围内时（如 application/octet-stream）按内容识别
+func detectMimeType(contentType string, data []byte) (string, error) {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && allowedMimeTypes[mediaType] {
+		return mediaType, nil
+	}
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if allowedMimeTypes[sniffed] {
+		return sniffed, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+}
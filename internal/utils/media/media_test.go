@@ -0,0 +1,83 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected bool
+	}{
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := isPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.expected {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", tt.addr, got, tt.expected)
+		}
+	}
+}
+
+func TestFetchBlocksLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG\r\n\x1a\n"))
+	}))
+	defer server.Close()
+
+	if _, err := Fetch(context.Background(), server.URL+"/a.png"); !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("expected ErrBlockedAddress, got %v", err)
+	}
+	if _, err := Fetch(context.Background(), "file:///etc/passwd"); err == nil {
+		t.Fatal("expected error for non-http url")
+	}
+}
+
+func TestDetectMimeType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	if got, err := detectMimeType("application/octet-stream", png); err != nil || got != "image/png" {
+		t.Errorf("expected sniffed image/png, got %q, %v", got, err)
+	}
+	if got, err := detectMimeType("application/pdf; charset=binary", []byte("%PDF-1.7")); err != nil || got != "application/pdf" {
+		t.Errorf("expected application/pdf, got %q, %v", got, err)
+	}
+	if _, err := detectMimeType("text/html", []byte("<html></html>")); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("expected ErrUnsupportedType, got %v", err)
+	}
+}
+
+func TestLRUCacheEviction(t *testing.T) {
+	c := newLRUCache(10, time.Minute)
+	c.set("a", &Media{Data: make([]byte, 4)})
+	c.set("b", &Media{Data: make([]byte, 4)})
+	c.get("a")
+	c.set("c", &Media{Data: make([]byte, 4)})
+
+	if _, ok := c.get("b"); ok {
+		t.Error("expected least recently used entry to be evicted")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Error("expected recently used entry to be kept")
+	}
+	if c.size != 8 {
+		t.Errorf("expected size 8, got %d", c.size)
+	}
+}
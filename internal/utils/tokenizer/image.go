@@ -0,0 +1,89 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"strings"
+
+	"github.com/bestruirui/octopus/internal/utils/xurl"
+)
+
+// defaultImageSize 无法获取图片尺寸（如远程图片）时假定的宽高
+const defaultImageSize = 1024
+
+// imageSize 读取 base64 data URL 中图片头部的宽高，无法解析时返回 0
+func imageSize(url string) (int, int) {
+	dataURL := xurl.ParseDataURL(url)
+	if dataURL == nil || !dataURL.IsBase64 {
+		return 0, 0
+	}
+	config, _, err := image.DecodeConfig(base64.NewDecoder(base64.StdEncoding, strings.NewReader(dataURL.Data)))
+	if err != nil {
+		return 0, 0
+	}
+	return config.Width, config.Height
+}
+
+// orDefaultSize 尺寸未知时使用默认尺寸
+func orDefaultSize(width, height int) (float64, float64) {
+	if width <= 0 || height <= 0 {
+		return defaultImageSize, defaultImageSize
+	}
+	return float64(width), float64(height)
+}
+
+// fit 等比缩小到不超过 maxWidth x maxHeight
+func fit(width, height, maxWidth, maxHeight float64) (float64, float64) {
+	if ratio := math.Min(maxWidth/width, maxHeight/height); ratio < 1 {
+		return width * ratio, height * ratio
+	}
+	return width, height
+}
+
+// openAIImageTokens low 固定 85，其他先缩放到 2048x2048 内、短边不超过 768，每个 512 像素的分块 170
+// refer: https://platform.openai.com/docs/guides/images-vision#calculating-costs
+func openAIImageTokens(width, height int, detail string) int {
+	if detail == "low" {
+		return 85
+	}
+	w, h := orDefaultSize(width, height)
+	w, h = fit(w, h, 2048, 2048)
+	if shortest := math.Min(w, h); shortest > 768 {
+		w, h = w*768/shortest, h*768/shortest
+	}
+	return 85 + 170*int(math.Ceil(w/512)*math.Ceil(h/512))
+}
+
+// claudeImageTokens 长边缩放到 1568 以内、像素不超过约 115 万后，每 750 像素 1 个 token
+// refer: https://docs.anthropic.com/en/docs/build-with-claude/vision#evaluate-image-size
+func claudeImageTokens(width, height int, _ string) int {
+	w, h := orDefaultSize(width, height)
+	w, h = fit(w, h, 1568, 1568)
+	if pixels := w * h; pixels > 1_150_000 {
+		ratio := math.Sqrt(1_150_000 / pixels)
+		w, h = w*ratio, h*ratio
+	}
+	return int(math.Ceil(w * h / 750))
+}
+
+// geminiImageTokens 两边都不超过 384 时为 258，否则按 768x768 分块，每块 258
+// refer: https://ai.google.dev/gemini-api/docs/tokens#multimodal-tokens
+func geminiImageTokens(width, height int, _ string) int {
+	w, h := orDefaultSize(width, height)
+	if w <= 384 && h <= 384 {
+		return 258
+	}
+	return 258 * int(math.Ceil(w/768)*math.Ceil(h/768))
+}
+
+// qwenImageTokens 每 28x28 像素 1 个 token，像素数限制在 4 到 1280 个 token 之间
+// refer: https://help.aliyun.com/zh/model-studio/vision
+func qwenImageTokens(width, height int, _ string) int {
+	w, h := orDefaultSize(width, height)
+	tokens := math.Ceil(w/28) * math.Ceil(h/28)
+	return int(math.Max(4, math.Min(tokens, 1280)))
+}
@@ -1,15 +1,182 @@
+// Package tokenizer 按模型系列估算文本、图片和工具定义的 token 数
 package tokenizer
 
 import (
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/bestruirui/octopus/internal/utils/xstrings"
 	"github.com/tiktoken-go/tokenizer/codec"
 )
 
-func CountTokens(content, model string) int {
-	// TODO 更多模型
-	enc := codec.NewO200kBase()
-	tc, err := enc.Count(content)
+// tiktoken 编码只初始化一次，分词使用的 regexp2 可并发使用
+var (
+	o200kBase  = sync.OnceValue(codec.NewO200kBase)
+	cl100kBase = sync.OnceValue(codec.NewCl100kBase)
+)
+
+// Tokenizer 某一模型系列的 token 估算方式
+// 没有公开词表的模型系列使用相近的 tiktoken 编码乘以修正系数近似
+type Tokenizer struct {
+	// Name 分词器名称
+	Name string
+	// encoding 使用的 tiktoken 编码
+	encoding func() *codec.Codec
+	// scale 对编码计数的修正系数
+	scale float64
+	// image 估算单张图片的 token 数，宽高为 0 表示尺寸未知
+	image func(width, height int, detail string) int
+	// toolBase 请求带有工具时的固定开销，如上游注入的工具说明
+	toolBase int
+	// toolOverhead 每个工具定义在名称、描述和参数之外的开销
+	toolOverhead int
+}
+
+// Tool 工具定义中参与计数的文本
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  string
+}
+
+var (
+	O200kBase  = &Tokenizer{Name: "o200k_base", encoding: o200kBase, scale: 1, image: openAIImageTokens, toolOverhead: 8}
+	Cl100kBase = &Tokenizer{Name: "cl100k_base", encoding: cl100kBase, scale: 1, image: openAIImageTokens, toolOverhead: 8}
+	// Claude 的分词器未公开，按 cl100k 计数放大约 15% 近似，带工具时上游会注入约 346 个 token 的工具说明
+	Claude = &Tokenizer{Name: "claude", encoding: cl100kBase, scale: 1.15, image: claudeImageTokens, toolBase: 346, toolOverhead: 3}
+	// Gemini 使用 256k 词表的 SentencePiece，与 o200k 的计数相近
+	Gemini = &Tokenizer{Name: "gemini", encoding: o200kBase, scale: 1, image: geminiImageTokens, toolOverhead: 5}
+	// Qwen 和 DeepSeek 的词表针对中文扩充，中文计数与 o200k 相近
+	Qwen     = &Tokenizer{Name: "qwen", encoding: o200kBase, scale: 1, image: qwenImageTokens, toolOverhead: 8}
+	DeepSeek = &Tokenizer{Name: "deepseek", encoding: o200kBase, scale: 1, image: openAIImageTokens, toolOverhead: 8}
+)
+
+// rule 模型名匹配规则到分词器的映射
+type rule struct {
+	pattern   string
+	tokenizer *Tokenizer
+}
+
+// builtinRules 内置规则，按顺序匹配，模型名去掉 "provider/" 前缀后比较
+var builtinRules = []rule{
+	{"gpt-4o*", O200kBase},
+	{"gpt-4.1*", O200kBase},
+	{"gpt-4.5*", O200kBase},
+	{"gpt-5*", O200kBase},
+	{"gpt-oss*", O200kBase},
+	{"chatgpt-*", O200kBase},
+	{"o1*", O200kBase},
+	{"o3*", O200kBase},
+	{"o4*", O200kBase},
+	{"gpt-4*", Cl100kBase},
+	{"gpt-3.5*", Cl100kBase},
+	{"text-embedding-*", Cl100kBase},
+	{"*claude*", Claude},
+	{"*gemini*", Gemini},
+	{"*gemma*", Gemini},
+	{"*qwen*", Qwen},
+	{"*qwq*", Qwen},
+	{"*deepseek*", DeepSeek},
+}
+
+var (
+	rulesLock sync.RWMutex
+	// customRules 通过 Register 注册的规则，优先于内置规则
+	customRules []rule
+	// byName 按名称查找分词器
+	byName = map[string]*Tokenizer{}
+)
+
+func init() {
+	for _, t := range []*Tokenizer{O200kBase, Cl100kBase, Claude, Gemini, Qwen, DeepSeek} {
+		byName[t.Name] = t
+	}
+}
+
+// NewApprox 基于已有的分词器创建近似的分词器，文本计数乘以 scale，图片和工具的估算沿用 base
+func NewApprox(name string, base *Tokenizer, scale float64) *Tokenizer {
+	t := *base
+	t.Name = name
+	t.scale = base.scale * scale
+	return &t
+}
+
+// Get 按名称获取分词器，不存在时返回 nil
+func Get(name string) *Tokenizer {
+	rulesLock.RLock()
+	defer rulesLock.RUnlock()
+	return byName[name]
+}
+
+// Register 让匹配 pattern 的模型使用指定的分词器，pattern 支持 * 通配符，不区分大小写
+// 注册的规则按注册顺序优先于内置规则匹配
+func Register(pattern string, t *Tokenizer) {
+	rulesLock.Lock()
+	defer rulesLock.Unlock()
+	customRules = append(customRules, rule{pattern: pattern, tokenizer: t})
+	byName[t.Name] = t
+}
+
+// ForModel 返回模型使用的分词器，未匹配任何规则时使用 o200k_base
+func ForModel(modelName string) *Tokenizer {
+	name := modelName[strings.LastIndex(modelName, "/")+1:]
+	rulesLock.RLock()
+	defer rulesLock.RUnlock()
+	for _, rules := range [][]rule{customRules, builtinRules} {
+		for _, r := range rules {
+			if xstrings.MatchWildcard(r.pattern, name) || xstrings.MatchWildcard(r.pattern, modelName) {
+				return r.tokenizer
+			}
+		}
+	}
+	return O200kBase
+}
+
+// Count 估算文本的 token 数
+func (t *Tokenizer) Count(content string) int {
+	if content == "" {
+		return 0
+	}
+	tc, err := t.encoding().Count(content)
 	if err != nil {
 		return 0
 	}
-	return tc
+	if t.scale == 1 {
+		return tc
+	}
+	return int(math.Ceil(float64(tc) * t.scale))
+}
+
+// CountImage 估算图片的 token 数，url 为 data URL 时按图片尺寸计算，否则按默认尺寸估算
+func (t *Tokenizer) CountImage(url, detail string) int {
+	width, height := imageSize(url)
+	return t.image(width, height, detail)
+}
+
+// CountTools 估算工具定义的 token 数
+func (t *Tokenizer) CountTools(tools []Tool) int {
+	if len(tools) == 0 {
+		return 0
+	}
+	tokens := t.toolBase
+	for _, tool := range tools {
+		tokens += t.Count(tool.Name) + t.Count(tool.Description) + t.Count(tool.Parameters) + t.toolOverhead
+	}
+	return tokens
+}
+
+// CountTokens 按模型使用的分词器估算文本的 token 数
+func CountTokens(content, model string) int {
+	return ForModel(model).Count(content)
+}
+
+// CountImageTokens 按模型估算图片的 token 数
+func CountImageTokens(url, detail, model string) int {
+	return ForModel(model).CountImage(url, detail)
+}
+
+// CountToolTokens 按模型估算工具定义的 token 数
+func CountToolTokens(tools []Tool, model string) int {
+	return ForModel(model).CountTools(tools)
 }
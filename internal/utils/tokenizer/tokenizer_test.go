@@ -0,0 +1,48 @@
+package tokenizer
+
+import "testing"
+
+func TestForModel(t *testing.T) {
+	cases := map[string]*Tokenizer{
+		"gpt-4o-mini":                 O200kBase,
+		"gpt-4-turbo":                 Cl100kBase,
+		"anthropic/claude-sonnet-4-5": Claude,
+		"gemini-2.5-pro":              Gemini,
+		"Qwen3-235B-A22B":             Qwen,
+		"deepseek-chat":               DeepSeek,
+		"unknown-model":               O200kBase,
+	}
+	for modelName, want := range cases {
+		if got := ForModel(modelName); got != want {
+			t.Errorf("ForModel(%q) = %s, want %s", modelName, got.Name, want.Name)
+		}
+	}
+
+	Register("my-*", NewApprox("my", Cl100kBase, 2))
+	if got := ForModel("my-model"); got.Name != "my" || got.Count("hello") != 2*Cl100kBase.Count("hello") {
+		t.Errorf("registered tokenizer not used: %s", got.Name)
+	}
+}
+
+func TestCountImage(t *testing.T) {
+	// 1x1 PNG
+	const png = "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAAAAC0lEQVR42mNkYAAAAAYAAjCB0C8AAAAASUVORK5CYII="
+	if w, h := imageSize(png); w != 1 || h != 1 {
+		t.Fatalf("imageSize = %dx%d, want 1x1", w, h)
+	}
+	if got := O200kBase.CountImage(png, "high"); got != 255 {
+		t.Errorf("openai 1x1 = %d, want 255", got)
+	}
+	if got := O200kBase.CountImage("https://example.com/a.png", "low"); got != 85 {
+		t.Errorf("openai low = %d, want 85", got)
+	}
+	if got := O200kBase.CountImage("https://example.com/a.png", ""); got != 765 {
+		t.Errorf("openai default size = %d, want 765", got)
+	}
+	if got := Claude.CountImage("https://example.com/a.png", ""); got != 1399 {
+		t.Errorf("claude default size = %d, want 1399", got)
+	}
+	if got := Gemini.CountImage(png, ""); got != 258 {
+		t.Errorf("gemini 1x1 = %d, want 258", got)
+	}
+}
@@ -33,3 +33,25 @@ func TrimCompact(items []string) []string {
 	}
 	return out
 }
+
+// MatchWildcard reports whether s matches the pattern case-insensitively.
+// "*" in the pattern matches any sequence of characters, including "/".
+func MatchWildcard(pattern, s string) bool {
+	pattern, s = strings.ToLower(pattern), strings.ToLower(s)
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(s, part)
+		if idx < 0 {
+			return false
+		}
+		s = s[idx+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
@@ -13,6 +13,13 @@ export enum ChannelType {
     Gemini = 3,
     Volcengine = 4,
     OpenAIEmbedding = 5,
+    AzureOpenAI = 6,
+    Bedrock = 7,
+    Vertex = 8,
+    Ollama = 9,
+    GeminiEmbedding = 10,
+    Custom = 11,
+    Mock = 12,
 }
 
 /**
@@ -25,6 +32,38 @@ export enum AutoGroupType {
     Regex = 3,  // 正则匹配
 }
 
+/**
+ * 渠道流式模式枚举
+ */
+export enum ChannelStreamMode {
+    Auto = 0,           // 跟随客户端请求
+    ForceStream = 1,    // 上游强制流式，非流式请求聚合后返回
+    ForceNonStream = 2, // 上游强制非流式，流式请求合成 SSE 返回
+}
+
+/**
+ * 渠道类型相关的附加配置（与后端 ChannelOptions 对齐）
+ */
+export type ChannelOptions = {
+    // 通用
+    emulate_tools?: string[];
+    think_tags?: string[];
+    // Azure OpenAI
+    api_version?: string;
+    api?: 'chat' | 'responses';
+    deployments?: Record<string, string>;
+    // Vertex AI
+    region?: string;
+    project_id?: string;
+    token_url?: string;
+    // Ollama
+    keep_alive?: string | number;
+    model_options?: Record<string, unknown>;
+    // 自定义渠道、模拟渠道
+    custom?: Record<string, unknown>;
+    mock?: Record<string, unknown>;
+};
+
 export type BaseUrl = {
     url: string;
     delay: number;
@@ -65,6 +104,12 @@ export type Channel = {
     param_override?: string | null;
     channel_proxy?: string | null;
     match_regex?: string | null;
+    stream_mode: ChannelStreamMode;
+    connect_time_out: number;
+    response_header_time_out: number;
+    stream_idle_time_out: number;
+    total_time_out: number;
+    options?: ChannelOptions | null;
     stats: StatsChannel;
 };
 
@@ -93,6 +138,12 @@ export type CreateChannelRequest = {
     channel_proxy?: string | null;
     param_override?: string | null;
     match_regex?: string | null;
+    stream_mode?: ChannelStreamMode;
+    connect_time_out?: number;
+    response_header_time_out?: number;
+    stream_idle_time_out?: number;
+    total_time_out?: number;
+    options?: ChannelOptions;
 };
 
 /**
@@ -113,6 +164,12 @@ export type UpdateChannelRequest = {
     channel_proxy?: string | null;
     param_override?: string | null;
     match_regex?: string | null;
+    stream_mode?: ChannelStreamMode;
+    connect_time_out?: number;
+    response_header_time_out?: number;
+    stream_idle_time_out?: number;
+    total_time_out?: number;
+    options?: ChannelOptions;
     // keys diff
     keys_to_add?: Array<Pick<ChannelKey, 'enabled' | 'channel_key' | 'remark'>>;
     keys_to_update?: Array<{ id: number; enabled?: boolean; channel_key?: string; remark?: string }>;
@@ -125,6 +182,7 @@ export type FetchModelRequest = {
     keys: Array<Pick<ChannelKey, 'enabled' | 'channel_key'>>;
     proxy?: boolean;
     match_regex?: string | null;
+    options?: ChannelOptions;
 };
 
 /**
@@ -11,7 +11,7 @@ import {
     Globe,
     Key
 } from 'lucide-react';
-import { useUpdateChannel, useDeleteChannel, type Channel, type UpdateChannelRequest } from '@/api/endpoints/channel';
+import { useUpdateChannel, useDeleteChannel, ChannelStreamMode, type Channel, type UpdateChannelRequest } from '@/api/endpoints/channel';
 import {
     MorphingDialogTitle,
     MorphingDialogDescription,
@@ -22,7 +22,7 @@ import { Tabs, TabsContents, TabsContent } from '@/components/animate-ui/primiti
 import { type StatsMetricsFormatted } from '@/api/endpoints/stats';
 import { useTranslations } from 'next-intl';
 import { Button } from '@/components/ui/button';
-import { ChannelForm, type ChannelFormData } from './Form';
+import { ChannelForm, parseChannelOptions, type ChannelFormData } from './Form';
 import { formatMoney } from '@/lib/utils';
 import { Badge } from '@/components/ui/badge';
 import { cn } from '@/lib/utils';
@@ -58,6 +58,12 @@ export function CardContent({ channel, stats }: { channel: Channel; stats: Stats
         auto_sync: channel.auto_sync,
         auto_group: channel.auto_group,
         match_regex: channel.match_regex ?? '',
+        stream_mode: channel.stream_mode ?? ChannelStreamMode.Auto,
+        connect_time_out: channel.connect_time_out ?? 0,
+        response_header_time_out: channel.response_header_time_out ?? 0,
+        stream_idle_time_out: channel.stream_idle_time_out ?? 0,
+        total_time_out: channel.total_time_out ?? 0,
+        options: channel.options ? JSON.stringify(channel.options, null, 2) : '',
     });
     const t = useTranslations('channel.detail');
 
@@ -87,6 +93,11 @@ export function CardContent({ channel, stats }: { channel: Channel; stats: Stats
         if (formData.proxy !== channel.proxy) req.proxy = formData.proxy;
         if (formData.auto_sync !== channel.auto_sync) req.auto_sync = formData.auto_sync;
         if (formData.auto_group !== channel.auto_group) req.auto_group = formData.auto_group;
+        if (formData.stream_mode !== (channel.stream_mode ?? ChannelStreamMode.Auto)) req.stream_mode = formData.stream_mode;
+        if (formData.connect_time_out !== (channel.connect_time_out ?? 0)) req.connect_time_out = formData.connect_time_out;
+        if (formData.response_header_time_out !== (channel.response_header_time_out ?? 0)) req.response_header_time_out = formData.response_header_time_out;
+        if (formData.stream_idle_time_out !== (channel.stream_idle_time_out ?? 0)) req.stream_idle_time_out = formData.stream_idle_time_out;
+        if (formData.total_time_out !== (channel.total_time_out ?? 0)) req.total_time_out = formData.total_time_out;
 
         if (!headersEqual(formData.custom_header, channel.custom_header)) {
             req.custom_header = (formData.custom_header ?? [])
@@ -112,6 +123,11 @@ export function CardContent({ channel, stats }: { channel: Channel; stats: Stats
             req.match_regex = nextMatchRegex ? nextMatchRegex : null;
         }
 
+        const nextOptions = parseChannelOptions(formData.options);
+        if (JSON.stringify(nextOptions ?? {}) !== JSON.stringify(channel.options ?? {})) {
+            req.options = nextOptions ?? {};
+        }
+
         const originalKeys = channel.keys;
         const originalByID = new Map(originalKeys.map((k) => [k.id, k]));
         const nextKeys = formData.keys ?? [];
@@ -5,9 +5,9 @@ import {
     MorphingDialogDescription,
     useMorphingDialog,
 } from '@/components/ui/morphing-dialog';
-import { useCreateChannel, ChannelType, AutoGroupType } from '@/api/endpoints/channel';
+import { useCreateChannel, ChannelType, AutoGroupType, ChannelStreamMode } from '@/api/endpoints/channel';
 import { useTranslations } from 'next-intl';
-import { ChannelForm, type ChannelFormData } from './Form';
+import { ChannelForm, parseChannelOptions, type ChannelFormData } from './Form';
 
 export function CreateDialogContent() {
     const { setIsOpen } = useMorphingDialog();
@@ -27,6 +27,12 @@ export function CreateDialogContent() {
         enabled: true,
         proxy: false,
         match_regex: '',
+        stream_mode: ChannelStreamMode.Auto,
+        connect_time_out: 0,
+        response_header_time_out: 0,
+        stream_idle_time_out: 0,
+        total_time_out: 0,
+        options: '',
     });
     const t = useTranslations('channel.create');
 
@@ -45,6 +51,7 @@ export function CreateDialogContent() {
 
         const channelProxy = formData.channel_proxy.trim();
         const paramOverride = formData.param_override.trim();
+        const options = parseChannelOptions(formData.options);
         createChannel.mutate(
             {
                 name: formData.name,
@@ -61,6 +68,12 @@ export function CreateDialogContent() {
                 channel_proxy: channelProxy ? channelProxy : null,
                 param_override: paramOverride ? paramOverride : null,
                 match_regex: formData.match_regex.trim() ? formData.match_regex.trim() : null,
+                stream_mode: formData.stream_mode,
+                connect_time_out: formData.connect_time_out,
+                response_header_time_out: formData.response_header_time_out,
+                stream_idle_time_out: formData.stream_idle_time_out,
+                total_time_out: formData.total_time_out,
+                options: options ?? undefined,
             },
             {
                 onSuccess: () => {
@@ -79,6 +92,12 @@ export function CreateDialogContent() {
                         enabled: true,
                         proxy: false,
                         match_regex: '',
+                        stream_mode: ChannelStreamMode.Auto,
+                        connect_time_out: 0,
+                        response_header_time_out: 0,
+                        stream_idle_time_out: 0,
+                        total_time_out: 0,
+                        options: '',
                     });
                     setIsOpen(false);
                 }
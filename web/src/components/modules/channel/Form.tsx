@@ -1,4 +1,4 @@
-import { AutoGroupType, ChannelType, type Channel, useFetchModel } from '@/api/endpoints/channel';
+import { AutoGroupType, ChannelStreamMode, ChannelType, type Channel, type ChannelOptions, useFetchModel } from '@/api/endpoints/channel';
 import {
     Select,
     SelectContent,
@@ -40,6 +40,12 @@ export interface ChannelFormData {
     auto_sync: boolean;
     auto_group: AutoGroupType;
     match_regex: string;
+    stream_mode: ChannelStreamMode;
+    connect_time_out: number;
+    response_header_time_out: number;
+    stream_idle_time_out: number;
+    total_time_out: number;
+    options: string;
 }
 
 export interface ChannelFormProps {
@@ -61,6 +67,34 @@ import {
     AccordionTrigger,
 } from "@/components/ui/accordion";
 
+// 渠道的超时设置(秒), 0 为不限制
+const TIME_OUT_FIELDS = [
+    { key: 'connect_time_out', label: 'connectTimeOut' },
+    { key: 'response_header_time_out', label: 'responseHeaderTimeOut' },
+    { key: 'stream_idle_time_out', label: 'streamIdleTimeOut' },
+    { key: 'total_time_out', label: 'totalTimeOut' },
+] as const;
+
+// parseChannelOptions 解析渠道附加配置的 JSON，为空时返回 null，不是 JSON 对象时抛出异常
+export function parseChannelOptions(text: string): ChannelOptions | null {
+    if (!text.trim()) return null;
+    const options = JSON.parse(text);
+    if (typeof options !== 'object' || options === null || Array.isArray(options)) {
+        throw new Error('options must be a JSON object');
+    }
+    return options as ChannelOptions;
+}
+
+// 各渠道类型附加配置的示例
+const OPTIONS_PLACEHOLDER: Partial<Record<ChannelType, string>> = {
+    [ChannelType.AzureOpenAI]: '{"api_version": "2024-10-21", "api": "chat", "deployments": {"gpt-4o": "my-gpt-4o"}}',
+    [ChannelType.Vertex]: '{"region": "us-central1", "project_id": "my-project"}',
+    [ChannelType.Ollama]: '{"keep_alive": "5m", "model_options": {"num_ctx": 8192}}',
+    [ChannelType.Custom]: '{"custom": {"url": "{{.BaseURL}}/generate", "body": "...", "response": {"content": "output.text"}}}',
+    [ChannelType.Mock]: '{"mock": {"latency_ms": 200, "tokens_per_second": 50, "rate_limit_rate": 0.1}}',
+};
+const DEFAULT_OPTIONS_PLACEHOLDER = '{"think_tags": ["*r1*"], "emulate_tools": ["gemma*"]}';
+
 export function ChannelForm({
     formData,
     onFormDataChange,
@@ -101,6 +135,17 @@ export function ChannelForm({
 
     const fetchModel = useFetchModel();
 
+    const handleSubmit = (event: React.FormEvent<HTMLFormElement>) => {
+        try {
+            parseChannelOptions(formData.options);
+        } catch (error) {
+            event.preventDefault();
+            toast.error(t('optionsInvalid'), { description: error instanceof Error ? error.message : String(error) });
+            return;
+        }
+        onSubmit(event);
+    };
+
     const effectiveKey =
         formData.keys.find((k) => k.enabled && k.channel_key.trim())?.channel_key.trim() || '';
 
@@ -113,6 +158,12 @@ export function ChannelForm({
 
     const handleRefreshModels = async () => {
         if (!formData.base_urls?.[0]?.url || !effectiveKey) return;
+        let fetchOptions: ChannelOptions | null = null;
+        try {
+            fetchOptions = parseChannelOptions(formData.options);
+        } catch {
+            // 附加配置格式错误时不影响获取模型列表
+        }
         fetchModel.mutate(
             {
                 type: formData.type,
@@ -122,6 +173,7 @@ export function ChannelForm({
                     .map((k) => ({ enabled: k.enabled, channel_key: k.channel_key.trim() })),
                 proxy: formData.proxy,
                 match_regex: formData.match_regex.trim() || null,
+                options: fetchOptions ?? undefined,
             },
             {
                 onSuccess: (data) => {
@@ -220,7 +272,7 @@ export function ChannelForm({
     };
 
     return (
-        <form onSubmit={onSubmit} className="space-y-4 px-1">
+        <form onSubmit={handleSubmit} className="space-y-4 px-1">
             <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
                 <div className="space-y-2">
                     <label htmlFor={`${idPrefix}-name`} className="text-sm font-medium text-card-foreground">
@@ -254,6 +306,13 @@ export function ChannelForm({
                             <SelectItem className='rounded-xl' value={String(ChannelType.Gemini)}>{t('typeGemini')}</SelectItem>
                             <SelectItem className='rounded-xl' value={String(ChannelType.Volcengine)}>{t('typeVolcengine')}</SelectItem>
                             <SelectItem className='rounded-xl' value={String(ChannelType.OpenAIEmbedding)}>{t('typeOpenAIEmbedding')}</SelectItem>
+                            <SelectItem className='rounded-xl' value={String(ChannelType.AzureOpenAI)}>{t('typeAzureOpenAI')}</SelectItem>
+                            <SelectItem className='rounded-xl' value={String(ChannelType.Bedrock)}>{t('typeBedrock')}</SelectItem>
+                            <SelectItem className='rounded-xl' value={String(ChannelType.Vertex)}>{t('typeVertex')}</SelectItem>
+                            <SelectItem className='rounded-xl' value={String(ChannelType.Ollama)}>{t('typeOllama')}</SelectItem>
+                            <SelectItem className='rounded-xl' value={String(ChannelType.GeminiEmbedding)}>{t('typeGeminiEmbedding')}</SelectItem>
+                            <SelectItem className='rounded-xl' value={String(ChannelType.Custom)}>{t('typeCustom')}</SelectItem>
+                            <SelectItem className='rounded-xl' value={String(ChannelType.Mock)}>{t('typeMock')}</SelectItem>
                         </SelectContent>
                     </Select>
                 </div>
@@ -483,6 +542,25 @@ export function ChannelForm({
                             </div>
 
                             <div className="space-y-2">
+                                <label htmlFor={`${idPrefix}-stream-mode`} className="text-sm font-medium text-card-foreground">
+                                    {t('streamMode')}
+                                </label>
+                                <Select
+                                    value={String(formData.stream_mode)}
+                                    onValueChange={(value) => onFormDataChange({ ...formData, stream_mode: Number(value) as ChannelStreamMode })}
+                                >
+                                    <SelectTrigger id={`${idPrefix}-stream-mode`} className="rounded-xl w-full border border-border px-4 py-2 text-foreground focus-visible:outline-none focus-visible:ring-2 focus-visible:ring-ring">
+                                        <SelectValue />
+                                    </SelectTrigger>
+                                    <SelectContent className='rounded-xl'>
+                                        <SelectItem className='rounded-xl' value={String(ChannelStreamMode.Auto)}>{t('streamModeAuto')}</SelectItem>
+                                        <SelectItem className='rounded-xl' value={String(ChannelStreamMode.ForceStream)}>{t('streamModeForceStream')}</SelectItem>
+                                        <SelectItem className='rounded-xl' value={String(ChannelStreamMode.ForceNonStream)}>{t('streamModeForceNonStream')}</SelectItem>
+                                    </SelectContent>
+                                </Select>
+                            </div>
+
+                            <div className="space-y-2 md:col-span-2">
                                 <label htmlFor={`${idPrefix}-channel-proxy`} className="text-sm font-medium text-card-foreground">
                                     {t('channelProxy')}
                                 </label>
@@ -497,6 +575,32 @@ export function ChannelForm({
                             </div>
                         </div>
 
+                        <div className="space-y-2">
+                            <div className="grid grid-cols-2 gap-4">
+                                {TIME_OUT_FIELDS.map(({ key, label }) => (
+                                    <div key={key} className="space-y-2">
+                                        <label htmlFor={`${idPrefix}-${key}`} className="text-sm font-medium text-card-foreground">
+                                            {t(label)}
+                                        </label>
+                                        <Input
+                                            id={`${idPrefix}-${key}`}
+                                            type="number"
+                                            inputMode="numeric"
+                                            min={0}
+                                            step={1}
+                                            value={String(formData[key])}
+                                            onChange={(e) => {
+                                                const n = Number.parseInt(e.target.value, 10);
+                                                onFormDataChange({ ...formData, [key]: Number.isFinite(n) && n > 0 ? n : 0 });
+                                            }}
+                                            className="rounded-xl"
+                                        />
+                                    </div>
+                                ))}
+                            </div>
+                            <p className="text-xs text-muted-foreground">{t('timeOutHint')}</p>
+                        </div>
+
                         <div className="space-y-2">
                             <div className="flex items-center justify-between">
                                 <label className="text-sm font-medium text-card-foreground">
@@ -572,6 +676,20 @@ export function ChannelForm({
                                 className="min-h-28 w-full rounded-xl border border-border bg-background px-3 py-2 text-sm text-foreground focus-visible:outline-none focus-visible:ring-2 focus-visible:ring-ring"
                             />
                         </div>
+
+                        <div className="space-y-2">
+                            <label htmlFor={`${idPrefix}-options`} className="text-sm font-medium text-card-foreground">
+                                {t('options')}
+                            </label>
+                            <textarea
+                                id={`${idPrefix}-options`}
+                                value={formData.options}
+                                onChange={(e) => onFormDataChange({ ...formData, options: e.target.value })}
+                                placeholder={OPTIONS_PLACEHOLDER[formData.type] ?? DEFAULT_OPTIONS_PLACEHOLDER}
+                                className="min-h-28 w-full rounded-xl border border-border bg-background px-3 py-2 font-mono text-sm text-foreground focus-visible:outline-none focus-visible:ring-2 focus-visible:ring-ring"
+                            />
+                            <p className="text-xs text-muted-foreground">{t('optionsHint')}</p>
+                        </div>
                     </AccordionContent>
                 </AccordionItem>
             </Accordion>